@@ -0,0 +1,241 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gomodule/redigo/redis"
+)
+
+// defaultBannerKey is the Redis key holding the banner set at runtime.
+const defaultBannerKey = "myurls:banner"
+
+// adminToken is the bearer token required by the admin endpoints.
+var adminToken string
+
+// banner is the maintenance banner configured at startup.
+var banner string
+
+// DeleteResponse is the response structure of the batch deletion endpoint.
+type DeleteResponse struct {
+	Code    int
+	Message string
+	Deleted int
+}
+
+// globEscaper escapes the SCAN pattern metacharacters of a literal prefix.
+var globEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
+
+// 管理接口鉴权
+func AdminAuth() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		if adminToken == "" {
+			context.AbortWithStatusJSON(http.StatusForbidden, Response{Code: 0, RequestID: requestID(context), Message: "管理接口未启用"})
+			return
+		}
+
+		if !adminAuthorized(context.GetHeader("Authorization")) {
+			context.AbortWithStatusJSON(http.StatusUnauthorized, Response{Code: 0, RequestID: requestID(context), Message: "未授权"})
+			return
+		}
+
+		context.Next()
+	}
+}
+
+// currentBanner returns the banner set at runtime, falling back to the -banner flag.
+func currentBanner() string {
+	redisClient := redisPool.Get()
+	defer redisClient.Close()
+
+	text, err := redis.String(redisClient.Do("get", redisKey(defaultBannerKey)))
+	if err != nil {
+		return banner
+	}
+
+	return text
+}
+
+// 设置横幅，内容为空则不显示
+func setBannerHandler(context *gin.Context) {
+	redisClient := redisPool.Get()
+	defer redisClient.Close()
+
+	text := context.PostForm("banner")
+	if _, err := redisClient.Do("set", redisKey(defaultBannerKey), text); err != nil {
+		context.JSON(http.StatusInternalServerError, Response{Code: 0, RequestID: requestID(context), Message: "存储服务不可用"})
+		return
+	}
+
+	context.JSON(http.StatusOK, Response{Code: 1, Message: text})
+}
+
+// 清除运行时横幅，恢复为启动参数配置
+func clearBannerHandler(context *gin.Context) {
+	redisClient := redisPool.Get()
+	defer redisClient.Close()
+
+	if _, err := redisClient.Do("del", redisKey(defaultBannerKey)); err != nil {
+		context.JSON(http.StatusInternalServerError, Response{Code: 0, RequestID: requestID(context), Message: "存储服务不可用"})
+		return
+	}
+
+	context.JSON(http.StatusOK, Response{Code: 1, Message: banner})
+}
+
+// 禁用短链接，保留数据与有效期以便调查
+func disableLinkHandler(context *gin.Context) {
+	setLinkDisabled(context, true)
+}
+
+// 重新启用被禁用的短链接
+func enableLinkHandler(context *gin.Context) {
+	setLinkDisabled(context, false)
+}
+
+// setLinkDisabled sets or clears the disabled flag of the short key in the request path.
+func setLinkDisabled(context *gin.Context, disabled bool) {
+	shortKey := normalizeShortKey(context.Param("shortKey"))
+
+	redisClient := redisPool.Get()
+	defer redisClient.Close()
+
+	exists, err := redis.Bool(redisClient.Do("exists", redisKey(shortKey)))
+	if err != nil {
+		respondError(context, http.StatusInternalServerError, storageError(err).Error())
+		return
+	}
+	if !exists {
+		respondError(context, http.StatusNotFound, "短链接不存在")
+		return
+	}
+
+	// 降级时缓存中不应留有已禁用的链接
+	linkCache.remove(shortKey)
+	if disabled {
+		err = setLinkMeta(shortKey, map[string]interface{}{"disabled": 1})
+	} else {
+		_, err = redisClient.Do("hdel", redisKey(defaultMetaPrefix+shortKey), "disabled")
+	}
+	if err != nil {
+		respondError(context, http.StatusInternalServerError, storageError(err).Error())
+		return
+	}
+
+	context.JSON(http.StatusOK, Response{Code: 1})
+}
+
+// 按标签或前缀批量删除短链接及其附属数据
+func deleteLinksHandler(context *gin.Context) {
+	res := &DeleteResponse{Code: 1}
+	if err := writesSuspended(); err != nil {
+		res.Code = 0
+		res.Message = err.Error()
+		context.JSON(http.StatusServiceUnavailable, *res)
+		return
+	}
+
+	tag := strings.TrimSpace(context.Query("tag"))
+	prefix := context.Query("prefix")
+	if (tag == "") == (prefix == "") {
+		res.Code = 0
+		res.Message = "需指定tag或prefix其中之一"
+		context.JSON(http.StatusBadRequest, *res)
+		return
+	}
+	if prefix != "" && !isShortKey(prefix) {
+		res.Code = 0
+		res.Message = "prefix无效"
+		context.JSON(http.StatusBadRequest, *res)
+		return
+	}
+	if tag != "" && !validTag(tag) {
+		res.Code = 0
+		res.Message = "tag无效"
+		context.JSON(http.StatusBadRequest, *res)
+		return
+	}
+
+	redisClient := redisPool.Get()
+	defer redisClient.Close()
+
+	var shortKeys []string
+	var err error
+	if tag != "" {
+		shortKeys, err = redis.Strings(redisClient.Do("smembers", redisKey(defaultTagPrefix+tag)))
+	} else {
+		namespace := redisKey("")
+		err = scanKeys(redisClient, namespace+globEscaper.Replace(prefix)+"*", func(key string) error {
+			if shortKey := strings.TrimPrefix(key, namespace); isShortKey(shortKey) {
+				shortKeys = append(shortKeys, shortKey)
+			}
+			return nil
+		})
+	}
+	if err != nil {
+		res.Code = 0
+		res.Message = storageError(err).Error()
+		context.JSON(http.StatusInternalServerError, *res)
+		return
+	}
+
+	for start := 0; start < len(shortKeys); start += gcScanCount {
+		end := start + gcScanCount
+		if end > len(shortKeys) {
+			end = len(shortKeys)
+		}
+		deleted, err := deleteLinkBatch(redisClient, shortKeys[start:end], tag)
+		res.Deleted += deleted
+		if err != nil {
+			res.Code = 0
+			res.Message = storageError(err).Error()
+			context.JSON(http.StatusInternalServerError, *res)
+			return
+		}
+	}
+
+	context.JSON(http.StatusOK, *res)
+}
+
+// deleteLinkBatch deletes the short links among shortKeys, fetching them in one pipelined round trip.
+// Keys that are not links, and with a tag links no longer carrying it, are left untouched.
+func deleteLinkBatch(conn redis.Conn, shortKeys []string, tag string) (int, error) {
+	for _, shortKey := range shortKeys {
+		_ = conn.Send("get", redisKey(shortKey))
+		_ = conn.Send("hget", redisKey(defaultMetaPrefix+shortKey), "tags")
+	}
+	if err := conn.Flush(); err != nil {
+		return 0, err
+	}
+
+	longUrls := make([]string, len(shortKeys))
+	tagged := make([]bool, len(shortKeys))
+	for i := range shortKeys {
+		// 非字符串类型的键不是短链接，GET 报错时跳过
+		longUrls[i], _ = redis.String(conn.Receive())
+		tags, _ := redis.String(conn.Receive())
+		if tag != "" {
+			for _, t := range linkTags(map[string]string{"tags": tags}) {
+				tagged[i] = tagged[i] || t == tag
+			}
+		}
+	}
+
+	deleted := 0
+	for i, shortKey := range shortKeys {
+		if tag != "" && !tagged[i] {
+			_, _ = conn.Do("srem", redisKey(defaultTagPrefix+tag), shortKey)
+			continue
+		}
+		if longUrls[i] == "" {
+			continue
+		}
+		if err := deleteLink(conn, shortKey, longUrls[i]); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+
+	return deleted, nil
+}
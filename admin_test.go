@@ -0,0 +1,149 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// bearer returns the Authorization header of a bearer token.
+func bearer(token string) http.Header {
+	return http.Header{"Authorization": {"Bearer " + token}}
+}
+
+func TestBannerOnIndex(t *testing.T) {
+	router, _ := newTestRouter(t)
+	setFlag(t, &adminToken, "secret-admin")
+
+	const text = "今晚 22:00 维护"
+	if w := serve(router, http.MethodGet, "/", nil, nil); strings.Contains(w.Body.String(), `class="banner"`) {
+		t.Fatal("banner rendered before it was set")
+	}
+
+	w := serve(router, http.MethodPost, "/admin/banner", url.Values{"banner": {text}}, bearer(adminToken))
+	if w.Code != http.StatusOK {
+		t.Fatalf("set banner status %d: %s", w.Code, w.Body)
+	}
+	if w := serve(router, http.MethodGet, "/", nil, nil); !strings.Contains(w.Body.String(), text) {
+		t.Fatal("banner missing from index after it was set")
+	}
+
+	if w := serve(router, http.MethodDelete, "/admin/banner", nil, bearer(adminToken)); w.Code != http.StatusOK {
+		t.Fatalf("clear banner status %d", w.Code)
+	}
+	if w := serve(router, http.MethodGet, "/", nil, nil); strings.Contains(w.Body.String(), text) {
+		t.Fatal("banner still rendered after it was cleared")
+	}
+}
+
+func TestBannerFlag(t *testing.T) {
+	router, _ := newTestRouter(t)
+	setFlag(t, &banner, "flag banner")
+
+	if w := serve(router, http.MethodGet, "/", nil, nil); !strings.Contains(w.Body.String(), "flag banner") {
+		t.Fatal("-banner text missing from index")
+	}
+}
+
+func TestAdminAuth(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	if w := serve(router, http.MethodGet, "/admin/top", nil, nil); w.Code != http.StatusForbidden {
+		t.Fatalf("admin without -admin-token: status %d, want 403", w.Code)
+	}
+
+	setFlag(t, &adminToken, "secret-admin")
+	if w := serve(router, http.MethodGet, "/admin/top", nil, nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("admin without token: status %d, want 401", w.Code)
+	}
+	if w := serve(router, http.MethodGet, "/admin/top", nil, bearer("wrong")); w.Code != http.StatusUnauthorized {
+		t.Fatalf("admin with wrong token: status %d, want 401", w.Code)
+	}
+	if w := serve(router, http.MethodGet, "/admin/top", nil, bearer(adminToken)); w.Code != http.StatusOK {
+		t.Fatalf("admin with token: status %d, want 200", w.Code)
+	}
+}
+
+func TestDisableLink(t *testing.T) {
+	router, _ := newTestRouter(t)
+	setFlag(t, &adminToken, "secret-admin")
+
+	key := mustShorten(t, router, "https://example.com/disable", nil)
+
+	if w := serve(router, http.MethodPost, "/admin/disable/"+key, nil, bearer(adminToken)); w.Code != http.StatusOK {
+		t.Fatalf("disable status %d", w.Code)
+	}
+	if w := serve(router, http.MethodGet, "/"+key, nil, nil); w.Code != http.StatusForbidden {
+		t.Fatalf("disabled link status %d, want 403", w.Code)
+	}
+	if code, res := linkStats(t, router, key, nil); code != http.StatusForbidden || res.LongUrl != "" {
+		t.Fatalf("disabled link stats: status %d, %+v", code, res)
+	}
+	w := serve(router, http.MethodGet, "/api/link/"+key, nil, nil)
+	var info LinkInfo
+	decode(t, w, &info)
+	if w.Code != http.StatusGone || info.LongUrl != "" {
+		t.Fatalf("disabled link info: status %d, %+v", w.Code, info)
+	}
+	w = serve(router, http.MethodGet, "/admin/link/"+key, nil, bearer(adminToken))
+	decode(t, w, &info)
+	if w.Code != http.StatusOK || !info.Disabled || info.LongUrl != "https://example.com/disable" {
+		t.Fatalf("disabled link admin info: status %d, %+v", w.Code, info)
+	}
+
+	if w := serve(router, http.MethodPost, "/admin/enable/"+key, nil, bearer(adminToken)); w.Code != http.StatusOK {
+		t.Fatalf("enable status %d", w.Code)
+	}
+	if w := serve(router, http.MethodGet, "/"+key, nil, nil); w.Code != http.StatusMovedPermanently {
+		t.Fatalf("re-enabled link status %d, want 301", w.Code)
+	}
+
+	if w := serve(router, http.MethodPost, "/admin/disable/nope42", nil, bearer(adminToken)); w.Code != http.StatusNotFound {
+		t.Fatalf("disable unknown key status %d, want 404", w.Code)
+	}
+}
+
+func TestDeleteLinksByTag(t *testing.T) {
+	router, mr := newTestRouter(t)
+	setFlag(t, &adminToken, "secret-admin")
+
+	promo := mustShorten(t, router, "https://example.com/promo", url.Values{"tags": {"promo"}})
+	other := mustShorten(t, router, "https://example.com/other", url.Values{"tags": {"other"}})
+
+	w := serve(router, http.MethodDelete, "/admin/links?tag=promo", nil, bearer(adminToken))
+	var res DeleteResponse
+	decode(t, w, &res)
+	if w.Code != http.StatusOK || res.Deleted != 1 {
+		t.Fatalf("delete by tag: status %d, %+v", w.Code, res)
+	}
+	if mr.Exists(promo) {
+		t.Fatal("tagged link not deleted")
+	}
+	if !mr.Exists(other) {
+		t.Fatal("link with another tag deleted")
+	}
+}
+
+func TestDeleteLinksByPrefix(t *testing.T) {
+	router, mr := newTestRouter(t)
+	setFlag(t, &adminToken, "secret-admin")
+
+	mustShorten(t, router, "https://example.com/1", url.Values{"shortKey": {"camp1"}})
+	mustShorten(t, router, "https://example.com/2", url.Values{"shortKey": {"camp2"}})
+	mustShorten(t, router, "https://example.com/3", url.Values{"shortKey": {"keep1"}})
+
+	w := serve(router, http.MethodDelete, "/admin/links?prefix=camp", nil, bearer(adminToken))
+	var res DeleteResponse
+	decode(t, w, &res)
+	if w.Code != http.StatusOK || res.Deleted != 2 {
+		t.Fatalf("delete by prefix: status %d, %+v", w.Code, res)
+	}
+	if mr.Exists("camp1") || mr.Exists("camp2") || !mr.Exists("keep1") {
+		t.Fatalf("unexpected keys left: %v", mr.Keys())
+	}
+
+	if w := serve(router, http.MethodDelete, "/admin/links", nil, bearer(adminToken)); w.Code != http.StatusBadRequest {
+		t.Fatalf("delete without scope status %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+)
+
+// allowedHosts restricts long URLs to these hosts when non-empty.
+// An entry like "*.example.com" also matches every subdomain of example.com.
+var allowedHosts []string
+
+// redirectOnly re-validates the destination host against allowedHosts on every redirect.
+var redirectOnly bool
+
+// hostAllowedByList reports whether host matches an entry of allowedHosts, always true when the list is empty.
+func hostAllowedByList(host string) bool {
+	if len(allowedHosts) == 0 {
+		return true
+	}
+
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, allowed := range allowedHosts {
+		if suffix, ok := strings.CutPrefix(allowed, "*."); ok {
+			if host == suffix || strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+		} else if host == allowed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// destinationAllowed reports whether the host of longUrl is allowed.
+func destinationAllowed(longUrl string) bool {
+	u, err := url.Parse(longUrl)
+	if err != nil {
+		return false
+	}
+
+	return hostAllowedByList(u.Hostname())
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestHostAllowedByList(t *testing.T) {
+	setFlag(t, &allowedHosts, []string{"example.com", "*.corp.test"})
+	for host, want := range map[string]bool{
+		"example.com":     true,
+		"EXAMPLE.com.":    true,
+		"www.example.com": false,
+		"corp.test":       true,
+		"a.b.corp.test":   true,
+		"evilcorp.test":   false,
+	} {
+		if got := hostAllowedByList(host); got != want {
+			t.Errorf("hostAllowedByList(%q) = %v, want %v", host, got, want)
+		}
+	}
+
+	setFlag(t, &allowedHosts, nil)
+	if !hostAllowedByList("anything.test") {
+		t.Fatal("empty list rejected a host")
+	}
+}
+
+func TestAllowedHosts(t *testing.T) {
+	router, _ := newTestRouter(t)
+	setFlag(t, &allowedHosts, []string{"example.com"})
+
+	key := mustShorten(t, router, "https://example.com/ok", nil)
+	if code, _ := shorten(t, router, "https://other.test/", nil); code != http.StatusBadRequest {
+		t.Fatalf("host outside the list: status %d, want 400", code)
+	}
+
+	// 允许列表变更后，仅 -redirect-only 拒绝已有链接
+	setFlag(t, &allowedHosts, []string{"other.test"})
+	if w := serve(router, http.MethodGet, "/"+key, nil, nil); w.Code != http.StatusMovedPermanently {
+		t.Fatalf("existing link without -redirect-only: status %d", w.Code)
+	}
+	setFlag(t, &redirectOnly, true)
+	if w := serve(router, http.MethodGet, "/"+key, nil, nil); w.Code != http.StatusForbidden {
+		t.Fatalf("existing link with -redirect-only: status %d, want 403", w.Code)
+	}
+	if w := serve(router, http.MethodHead, "/"+key, nil, nil); w.Code != http.StatusForbidden {
+		t.Fatalf("HEAD with -redirect-only: status %d, want 403", w.Code)
+	}
+}
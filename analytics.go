@@ -0,0 +1,248 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	_ "modernc.org/sqlite"
+)
+
+// analyticsBufferSize is the number of visit events buffered before new ones are dropped.
+const analyticsBufferSize = 4096
+
+// analyticsBatchSize is the maximum number of visit events inserted in one transaction.
+const analyticsBatchSize = 256
+
+// analyticsFlushInterval is how often buffered visit events are written.
+const analyticsFlushInterval = time.Second
+
+// defaultHistoryLimit is the default number of events returned by the history endpoint.
+const defaultHistoryLimit = 100
+
+// maxHistoryLimit is the maximum number of events returned by the history endpoint.
+const maxHistoryLimit = 1000
+
+// analyticsDB is the SQLite database recording visit events, nil when disabled.
+var analyticsDB *sql.DB
+
+// analyticsEvents feeds visit events to the background writer.
+var analyticsEvents chan VisitEvent
+
+// analyticsStop is closed to stop the background writer. The events channel itself is never closed,
+// so handlers still running after a timed out shutdown can't send on a closed channel.
+var analyticsStop chan struct{}
+
+// analyticsDone is closed once the background writer flushed all events.
+var analyticsDone chan struct{}
+
+// VisitEvent is a resolve of a short key recorded in the analytics database.
+type VisitEvent struct {
+	Timestamp int64
+	IP        string
+	UA        string
+	Referrer  string
+
+	shortKey string
+}
+
+// HistoryResponse is the response structure of the visit history endpoint.
+type HistoryResponse struct {
+	Code     int
+	Message  string
+	ShortKey string
+	Events   []VisitEvent
+}
+
+// openAnalytics opens the SQLite database at path and starts the background writer.
+func openAnalytics(path string) error {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return err
+	}
+	// SQLite 仅支持单写入者
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS visits (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		short_key TEXT NOT NULL,
+		ts INTEGER NOT NULL,
+		ip TEXT NOT NULL,
+		ua TEXT NOT NULL,
+		referrer TEXT NOT NULL
+	)`); err != nil {
+		db.Close()
+		return err
+	}
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS visits_short_key_ts ON visits (short_key, ts)`); err != nil {
+		db.Close()
+		return err
+	}
+
+	analyticsDB = db
+	analyticsEvents = make(chan VisitEvent, analyticsBufferSize)
+	analyticsStop = make(chan struct{})
+	analyticsDone = make(chan struct{})
+	go analyticsWriter()
+
+	return nil
+}
+
+// closeAnalytics stops accepting events, waits for the writer to flush and closes the database.
+func closeAnalytics() {
+	if analyticsDB == nil {
+		return
+	}
+
+	close(analyticsStop)
+	<-analyticsDone
+	analyticsDB.Close()
+}
+
+// 记录访问事件，缓冲区满时丢弃，不阻塞跳转
+func recordVisitEvent(context *gin.Context, shortKey string) {
+	if analyticsDB == nil {
+		return
+	}
+
+	event := VisitEvent{
+		Timestamp: time.Now().Unix(),
+		IP:        context.ClientIP(),
+		UA:        context.Request.UserAgent(),
+		Referrer:  context.Request.Referer(),
+		shortKey:  shortKey,
+	}
+	select {
+	case <-analyticsStop:
+		// 已停止写入，丢弃关闭后仍在处理的请求的事件
+		return
+	default:
+	}
+	select {
+	case analyticsEvents <- event:
+	default:
+		log.Println("Analytics buffer full, dropping visit event of", shortKey)
+	}
+}
+
+// analyticsWriter batches buffered visit events into SQLite until analyticsStop is closed.
+func analyticsWriter() {
+	defer close(analyticsDone)
+
+	ticker := time.NewTicker(analyticsFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]VisitEvent, 0, analyticsBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := insertVisitEvents(batch); err != nil {
+			log.Println("Analytics write failed:", err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case event := <-analyticsEvents:
+			batch = append(batch, event)
+			if len(batch) >= analyticsBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-analyticsStop:
+			// 写入停止前已缓冲的事件
+			for {
+				select {
+				case event := <-analyticsEvents:
+					batch = append(batch, event)
+					if len(batch) >= analyticsBatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// insertVisitEvents inserts events in a single transaction.
+func insertVisitEvents(events []VisitEvent) error {
+	tx, err := analyticsDB.Begin()
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(`INSERT INTO visits (short_key, ts, ip, ua, referrer) VALUES (?, ?, ?, ?, ?)`)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, event := range events {
+		if _, err := stmt.Exec(event.shortKey, event.Timestamp, event.IP, event.UA, event.Referrer); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// 短链接访问记录，按时间倒序
+func historyHandler(context *gin.Context) {
+	shortKey := normalizeShortKey(context.Param("shortKey"))
+	res := &HistoryResponse{Code: 1, ShortKey: shortKey, Events: []VisitEvent{}}
+
+	if analyticsDB == nil {
+		res.Code = 0
+		res.Message = "访问记录未启用"
+		context.JSON(http.StatusForbidden, *res)
+		return
+	}
+
+	limit := defaultHistoryLimit
+	if limitStr := context.Query("limit"); limitStr != "" {
+		_limit, err := strconv.Atoi(limitStr)
+		if err != nil || _limit < 1 || _limit > maxHistoryLimit {
+			res.Code = 0
+			res.Message = "limit必须为1到" + strconv.Itoa(maxHistoryLimit) + "之间的整数"
+			context.JSON(http.StatusBadRequest, *res)
+			return
+		}
+		limit = _limit
+	}
+
+	rows, err := analyticsDB.QueryContext(context.Request.Context(),
+		`SELECT ts, ip, ua, referrer FROM visits WHERE short_key = ? ORDER BY ts DESC, id DESC LIMIT ?`, shortKey, limit)
+	if err != nil {
+		log.Println("Analytics query failed:", err)
+		res.Code = 0
+		res.Message = "访问记录查询失败"
+		context.JSON(http.StatusInternalServerError, *res)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event VisitEvent
+		if err := rows.Scan(&event.Timestamp, &event.IP, &event.UA, &event.Referrer); err != nil {
+			log.Println("Analytics query failed:", err)
+			res.Code = 0
+			res.Message = "访问记录查询失败"
+			context.JSON(http.StatusInternalServerError, *res)
+			return
+		}
+		res.Events = append(res.Events, event)
+	}
+
+	context.JSON(http.StatusOK, *res)
+}
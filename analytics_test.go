@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// openTestAnalytics opens an analytics database in a temp dir, closed at the end of the test.
+func openTestAnalytics(t testing.TB, path string) {
+	t.Helper()
+	if err := openAnalytics(path); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		closeAnalytics()
+		analyticsDB = nil
+	})
+}
+
+func TestVisitHistory(t *testing.T) {
+	router, _ := newTestRouter(t)
+	setFlag(t, &adminToken, "secret-admin")
+	if w := serve(router, http.MethodGet, "/stats/abc/history", nil, bearer(adminToken)); w.Code != http.StatusForbidden {
+		t.Fatalf("history without -analytics-db: status %d, want 403", w.Code)
+	}
+
+	path := filepath.Join(t.TempDir(), "analytics.db")
+	openTestAnalytics(t, path)
+	key := mustShorten(t, router, "https://example.com/tracked", nil)
+	for _, referrer := range []string{"https://a.test/", "https://b.test/"} {
+		serve(router, http.MethodGet, "/"+key, nil, http.Header{"Referer": {referrer}, "User-Agent": {"tester"}})
+	}
+	serve(router, http.MethodGet, "/"+key+"+", nil, nil)
+
+	// 关闭时写入缓冲的访问记录
+	closeAnalytics()
+	openTestAnalytics(t, path)
+
+	// 访问记录包含访客信息，不对外公开
+	if w := serve(router, http.MethodGet, "/stats/"+key+"/history", nil, nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("history without the admin token: status %d, want 401", w.Code)
+	}
+	w := serve(router, http.MethodGet, "/stats/"+key+"/history", nil, bearer(adminToken))
+	var res HistoryResponse
+	decode(t, w, &res)
+	if w.Code != http.StatusOK || len(res.Events) != 2 {
+		t.Fatalf("status %d, %+v", w.Code, res)
+	}
+	if res.Events[0].Referrer != "https://b.test/" || res.Events[0].UA != "tester" || res.Events[0].Timestamp == 0 {
+		t.Fatalf("latest event %+v", res.Events[0])
+	}
+
+	w = serve(router, http.MethodGet, "/stats/"+key+"/history?limit=1", nil, bearer(adminToken))
+	decode(t, w, &res)
+	if len(res.Events) != 1 {
+		t.Fatalf("limit=1 returned %d events", len(res.Events))
+	}
+	if w := serve(router, http.MethodGet, "/stats/"+key+"/history?limit=0", nil, bearer(adminToken)); w.Code != http.StatusBadRequest {
+		t.Fatalf("limit=0: status %d, want 400", w.Code)
+	}
+}
+
+func TestVisitHistoryPrivateLink(t *testing.T) {
+	router, _ := newTestRouter(t)
+	setFlag(t, &adminToken, "secret-admin")
+	setFlag(t, &jwtSecret, "jwt-secret")
+	openTestAnalytics(t, filepath.Join(t.TempDir(), "analytics.db"))
+
+	key := mustShorten(t, router, "https://example.com/private", url.Values{"requireAuth": {"true"}})
+	token, _ := signJWT(jwtClaims{Exp: time.Now().Add(time.Hour).Unix()}, jwtSecret)
+	serve(router, http.MethodGet, "/"+key, nil, bearer(token))
+
+	// 管理令牌即可查看私有链接的访问记录
+	deadline := time.Now().Add(3 * analyticsFlushInterval)
+	var res HistoryResponse
+	for len(res.Events) == 0 && time.Now().Before(deadline) {
+		w := serve(router, http.MethodGet, "/stats/"+key+"/history", nil, bearer(adminToken))
+		if w.Code != http.StatusOK {
+			t.Fatalf("admin history of a private link: status %d, %s", w.Code, w.Body)
+		}
+		decode(t, w, &res)
+		time.Sleep(50 * time.Millisecond)
+	}
+	if len(res.Events) != 1 {
+		t.Fatalf("admin history of a private link: %+v", res)
+	}
+}
+
+func TestVisitEventAfterClose(t *testing.T) {
+	openAnalytics(filepath.Join(t.TempDir(), "analytics.db"))
+	defer func() { analyticsDB = nil }()
+	router, _ := newTestRouter(t)
+	key := mustShorten(t, router, "https://example.com/late", nil)
+	closeAnalytics()
+
+	// 关闭超时后仍在处理的请求不能因写入已关闭的 channel 而 panic
+	if w := serve(router, http.MethodGet, "/"+key, nil, nil); w.Code != http.StatusMovedPermanently {
+		t.Fatalf("redirect after the analytics writer stopped: status %d", w.Code)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// authToken is the bearer token required to create short links, empty leaves creation open.
+var authToken string
+
+// jwtSecret is the HS256 secret used to verify tokens for private links.
+var jwtSecret string
+
+// bearerMatches compares an Authorization header against "Bearer <token>" in constant time.
+func bearerMatches(authorization string, token string) bool {
+	return subtle.ConstantTimeCompare([]byte(authorization), []byte("Bearer "+token)) == 1
+}
+
+// 创建短链接鉴权，未配置 -auth-token 时不校验
+func CreateAuth() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		if authToken != "" && !bearerMatches(context.GetHeader("Authorization"), authToken) {
+			context.AbortWithStatusJSON(http.StatusUnauthorized, Response{Code: 0, RequestID: requestID(context), Message: "未授权"})
+			return
+		}
+
+		context.Next()
+	}
+}
+
+// 管理已有短链接鉴权，接受 -auth-token 或 -admin-token，均未配置时禁用
+func ManageAuth() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		if authToken == "" && adminToken == "" {
+			context.AbortWithStatusJSON(http.StatusForbidden, Response{Code: 0, RequestID: requestID(context), Message: "管理接口未启用"})
+			return
+		}
+
+		authorization := context.GetHeader("Authorization")
+		if (authToken == "" || !bearerMatches(authorization, authToken)) && !adminAuthorized(authorization) {
+			context.AbortWithStatusJSON(http.StatusUnauthorized, Response{Code: 0, RequestID: requestID(context), Message: "未授权"})
+			return
+		}
+
+		context.Next()
+	}
+}
+
+// jwtHeader is the header segment of a JWT.
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+}
+
+// jwtClaims are the registered claims checked on a JWT, plus the scope of signed admin tokens.
+type jwtClaims struct {
+	Exp   int64  `json:"exp"`
+	Nbf   int64  `json:"nbf"`
+	Scope string `json:"scope,omitempty"`
+}
+
+// adminScope is the scope of signed tokens granting access to the admin endpoints.
+const adminScope = "admin"
+
+// verifyJWT checks the HS256 signature and the exp/nbf claims of a token.
+func verifyJWT(token string, secret string) error {
+	_, err := parseJWT(token, secret)
+
+	return err
+}
+
+// signJWT encodes claims as an HS256 token signed with secret.
+func signJWT(claims jwtClaims, secret string) (string, error) {
+	header, err := json.Marshal(jwtHeader{Alg: "HS256", Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unsigned))
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// parseJWT checks the HS256 signature and the exp/nbf claims of a token and returns its claims.
+func parseJWT(token string, secret string) (jwtClaims, error) {
+	var claims jwtClaims
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return claims, errors.New("malformed token")
+	}
+
+	headerBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return claims, err
+	}
+	var header jwtHeader
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
+		return claims, err
+	}
+	if header.Alg != "HS256" {
+		return claims, errors.New("unsupported alg: " + header.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return claims, err
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return claims, errors.New("invalid signature")
+	}
+
+	claimsBytes, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return claims, err
+	}
+	if err := json.Unmarshal(claimsBytes, &claims); err != nil {
+		return claims, err
+	}
+
+	now := time.Now().Unix()
+	if claims.Exp != 0 && now >= claims.Exp {
+		return claims, errors.New("token expired")
+	}
+	if claims.Nbf != 0 && now < claims.Nbf {
+		return claims, errors.New("token not yet valid")
+	}
+
+	return claims, nil
+}
+
+// authorizePrivateLink reports whether the bearer token of a request may resolve a private link.
+func authorizePrivateLink(authorization string) bool {
+	if jwtSecret == "" || !strings.HasPrefix(authorization, "Bearer ") {
+		return false
+	}
+
+	return verifyJWT(strings.TrimPrefix(authorization, "Bearer "), jwtSecret) == nil
+}
+
+// adminAuthorized reports whether a request carries the admin token, or an unexpired token
+// with the admin scope signed with it, so access can be handed out without sharing the admin token.
+func adminAuthorized(authorization string) bool {
+	if adminToken == "" {
+		return false
+	}
+	if bearerMatches(authorization, adminToken) {
+		return true
+	}
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return false
+	}
+
+	claims, err := parseJWT(strings.TrimPrefix(authorization, "Bearer "), adminToken)
+
+	// 签名令牌必须设置有效期
+	return err == nil && claims.Scope == adminScope && claims.Exp != 0
+}
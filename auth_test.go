@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestCreateAuth(t *testing.T) {
+	router, _ := newTestRouter(t)
+	setFlag(t, &authToken, "create-token")
+
+	form := url.Values{"longUrl": {"aHR0cHM6Ly9leGFtcGxlLmNvbS8="}}
+	for _, tc := range []struct {
+		name   string
+		header http.Header
+		want   int
+	}{
+		{"missing", nil, http.StatusUnauthorized},
+		{"wrong", bearer("other"), http.StatusUnauthorized},
+		{"correct", bearer("create-token"), http.StatusOK},
+	} {
+		if w := serve(router, http.MethodPost, "/short", form, tc.header); w.Code != tc.want {
+			t.Errorf("%s token: status %d, want %d", tc.name, w.Code, tc.want)
+		}
+	}
+}
+
+func TestPrivateLinkToken(t *testing.T) {
+	router, _ := newTestRouter(t)
+	setFlag(t, &jwtSecret, "jwt-secret")
+
+	key := mustShorten(t, router, "https://example.com/private", url.Values{"requireAuth": {"true"}})
+
+	valid, err := signJWT(jwtClaims{Exp: time.Now().Add(time.Hour).Unix()}, jwtSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, _ := signJWT(jwtClaims{Exp: time.Now().Add(-time.Hour).Unix()}, jwtSecret)
+	forged, _ := signJWT(jwtClaims{Exp: time.Now().Add(time.Hour).Unix()}, "other-secret")
+
+	for _, tc := range []struct {
+		name   string
+		header http.Header
+		want   int
+	}{
+		{"missing", nil, http.StatusUnauthorized},
+		{"expired", bearer(expired), http.StatusUnauthorized},
+		{"forged", bearer(forged), http.StatusUnauthorized},
+		{"valid", bearer(valid), http.StatusMovedPermanently},
+	} {
+		if w := serve(router, http.MethodGet, "/"+key, nil, tc.header); w.Code != tc.want {
+			t.Errorf("%s token: status %d, want %d", tc.name, w.Code, tc.want)
+		}
+	}
+}
+
+func TestPrivateLinkRequiresSecret(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	code, _ := shorten(t, router, "https://example.com/private", url.Values{"requireAuth": {"true"}})
+	if code != http.StatusBadRequest {
+		t.Fatalf("private link without -jwt-secret: status %d, want 400", code)
+	}
+}
+
+func TestSignedAdminToken(t *testing.T) {
+	router, _ := newTestRouter(t)
+	setFlag(t, &adminToken, "secret-admin")
+
+	valid, _ := signJWT(jwtClaims{Exp: time.Now().Add(time.Hour).Unix(), Scope: adminScope}, adminToken)
+	expired, _ := signJWT(jwtClaims{Exp: time.Now().Add(-time.Hour).Unix(), Scope: adminScope}, adminToken)
+	unscoped, _ := signJWT(jwtClaims{Exp: time.Now().Add(time.Hour).Unix()}, adminToken)
+	tampered := valid[:len(valid)-2] + "xx"
+
+	for _, tc := range []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"valid", valid, http.StatusOK},
+		{"expired", expired, http.StatusUnauthorized},
+		{"unscoped", unscoped, http.StatusUnauthorized},
+		{"tampered", tampered, http.StatusUnauthorized},
+	} {
+		if w := serve(router, http.MethodGet, "/admin/top", nil, bearer(tc.token)); w.Code != tc.want {
+			t.Errorf("%s token: status %d, want %d", tc.name, w.Code, tc.want)
+		}
+	}
+}
+
+func TestManageAuth(t *testing.T) {
+	router, _ := newTestRouter(t)
+	key := mustShorten(t, router, "https://example.com/manage", nil)
+	form := url.Values{"longUrl": {"aHR0cHM6Ly9leGFtcGxlLmNvbS9uZXc="}}
+
+	if w := serve(router, http.MethodPut, "/"+key, form, nil); w.Code != http.StatusForbidden {
+		t.Fatalf("update without tokens configured: status %d, want 403", w.Code)
+	}
+
+	setFlag(t, &authToken, "create-token")
+	if w := serve(router, http.MethodPut, "/"+key, form, bearer("wrong")); w.Code != http.StatusUnauthorized {
+		t.Fatalf("update with wrong token: status %d, want 401", w.Code)
+	}
+	if w := serve(router, http.MethodPut, "/"+key, form, bearer("create-token")); w.Code != http.StatusOK {
+		t.Fatalf("update with -auth-token: status %d, want 200", w.Code)
+	}
+}
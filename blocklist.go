@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"strings"
+)
+
+// maxBlockedRegenerations bounds how often a key containing a blocked token is regenerated.
+const maxBlockedRegenerations = 100
+
+// errKeyBlocked is returned when every regenerated key contained a blocked token.
+var errKeyBlocked = errors.New("无法生成不含屏蔽词的短链接，请检查 -blocklist")
+
+// blockedTokens are lowercase substrings generated keys must not contain, loaded from -blocklist.
+var blockedTokens []string
+
+// loadBlocklist reads one token per line from path, ignoring blank lines and # comments.
+func loadBlocklist(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var tokens []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		token := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if token == "" || strings.HasPrefix(token, "#") {
+			continue
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, scanner.Err()
+}
+
+// keyBlocked reports whether key contains a blocked token, ignoring case.
+func keyBlocked(key string) bool {
+	if len(blockedTokens) == 0 {
+		return false
+	}
+
+	key = strings.ToLower(key)
+	for _, token := range blockedTokens {
+		if strings.Contains(key, token) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// unblockedKey draws keys from next and appends the check character until the final key contains
+// no blocked token. It gives up with errKeyBlocked after maxBlockedRegenerations retries rather
+// than looping forever on a pathological blocklist.
+func unblockedKey(next func() (string, error)) (string, error) {
+	for attempt := 0; attempt <= maxBlockedRegenerations; attempt++ {
+		key, err := next()
+		if err != nil {
+			return "", err
+		}
+		key = withChecksum(key)
+		if !keyBlocked(key) {
+			return key, nil
+		}
+	}
+
+	return "", errKeyBlocked
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// defaultCacheTTL is the default time a resolved link is served from memory.
+const defaultCacheTTL = 10 * time.Second
+
+// linkCache caches resolved links in front of Redis, nil when disabled.
+var linkCache *lruCache
+
+// lruCache is a size bounded least recently used cache with per entry expiry, safe for concurrent use.
+// All methods are no-ops on a nil cache.
+type lruCache struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+// cacheEntry is an element of lruCache.
+type cacheEntry struct {
+	key      string
+	value    resolvedLink
+	expireAt time.Time
+
+	// staleAt is when the cached link itself expires, zero for links without expiry.
+	// Entries past expireAt are kept until then for degraded reads.
+	staleAt time.Time
+}
+
+// link returns the cached link with its ttl counted down to now.
+func (entry *cacheEntry) link() resolvedLink {
+	link := entry.value
+	link.pttl = -1
+	if !entry.staleAt.IsZero() {
+		link.pttl = time.Until(entry.staleAt).Milliseconds()
+	}
+
+	return link
+}
+
+// newLRUCache returns a cache holding at most size entries for at most ttl each.
+func newLRUCache(size int, ttl time.Duration) *lruCache {
+	return &lruCache{
+		size:  size,
+		ttl:   ttl,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// get returns the cached value of key unless it is missing or expired, with its remaining ttl.
+func (c *lruCache) get(key string) (resolvedLink, bool) {
+	if c == nil {
+		return resolvedLink{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return resolvedLink{}, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if time.Now().After(entry.expireAt) {
+		if !degradeEnabled {
+			c.removeElement(elem)
+		}
+		return resolvedLink{}, false
+	}
+	c.ll.MoveToFront(elem)
+
+	return entry.link(), true
+}
+
+// getStale returns the cached value of key past the cache ttl, as long as the link itself hasn't expired.
+func (c *lruCache) getStale(key string) (resolvedLink, bool) {
+	if c == nil {
+		return resolvedLink{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return resolvedLink{}, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if !entry.staleAt.IsZero() && time.Now().After(entry.staleAt) {
+		c.removeElement(elem)
+		return resolvedLink{}, false
+	}
+	c.ll.MoveToFront(elem)
+
+	return entry.link(), true
+}
+
+// add caches value for key, expiring after the cache ttl or maxAge, whichever is shorter.
+func (c *lruCache) add(key string, value resolvedLink, maxAge time.Duration) {
+	if c == nil {
+		return
+	}
+	ttl := c.ttl
+	if maxAge > 0 && maxAge < ttl {
+		ttl = maxAge
+	}
+	now := time.Now()
+	var staleAt time.Time
+	if maxAge > 0 {
+		staleAt = now.Add(maxAge)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		entry := elem.Value.(*cacheEntry)
+		entry.value = value
+		entry.expireAt = now.Add(ttl)
+		entry.staleAt = staleAt
+		c.ll.MoveToFront(elem)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&cacheEntry{key: key, value: value, expireAt: now.Add(ttl), staleAt: staleAt})
+	if c.ll.Len() > c.size {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+// remove invalidates the cached value of key.
+func (c *lruCache) remove(key string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.removeElement(elem)
+	}
+}
+
+// removeElement drops elem from the cache, the caller holds the lock.
+func (c *lruCache) removeElement(elem *list.Element) {
+	c.ll.Remove(elem)
+	delete(c.items, elem.Value.(*cacheEntry).key)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+func TestCacheInvalidatedOnUpdate(t *testing.T) {
+	router, _ := newTestRouter(t)
+	setFlag(t, &authToken, "create-token")
+	setFlag(t, &linkCache, newLRUCache(16, time.Minute))
+
+	key := mustShortenWith(t, router, "https://example.com/v1", nil, bearer(authToken))
+	serve(router, http.MethodGet, "/"+key, nil, nil)
+	if _, ok := linkCache.get(key); !ok {
+		t.Fatal("resolved link not cached")
+	}
+
+	serve(router, http.MethodPut, "/"+key, updateForm("https://example.com/v2"), bearer(authToken))
+	if got := serve(router, http.MethodGet, "/"+key, nil, nil).Header().Get("Location"); got != "https://example.com/v2" {
+		t.Fatalf("stale cached destination %q after update", got)
+	}
+}
+
+func TestCacheHitRoundTrips(t *testing.T) {
+	router, mr := newTestRouter(t)
+	setFlag(t, &linkCache, newLRUCache(16, time.Minute))
+	var roundTrips atomic.Int64
+	redisPool = &redis.Pool{Dial: func() (redis.Conn, error) {
+		conn, err := redis.Dial("tcp", mr.Addr())
+		return roundTripConn{Conn: conn, roundTrips: &roundTrips}, err
+	}}
+
+	key := mustShorten(t, router, "https://example.com/hot", url.Values{"redirectStatus": {"302"}})
+	serve(router, http.MethodGet, "/"+key, nil, nil)
+
+	// 命中缓存时不再读取长链接与元数据，仅写入访问统计
+	roundTrips.Store(0)
+	w := serve(router, http.MethodGet, "/"+key, nil, nil)
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "https://example.com/hot" {
+		t.Fatalf("cached redirect: status %d, Location %q", w.Code, w.Header().Get("Location"))
+	}
+	if got := roundTrips.Load(); got != 1 {
+		t.Fatalf("%d round trips per cached redirect, want 1", got)
+	}
+	if hits, _ := mr.Get(defaultHitsPrefix + key); hits != "2" {
+		t.Fatalf("hits %q after a cached redirect, want 2", hits)
+	}
+}
+
+func TestLRUCache(t *testing.T) {
+	cache := newLRUCache(2, time.Minute)
+	cache.add("a", resolvedLink{longUrl: "1"}, 0)
+	cache.add("b", resolvedLink{longUrl: "2"}, 0)
+	cache.get("a")
+	cache.add("c", resolvedLink{longUrl: "3"}, 0)
+	if _, ok := cache.get("b"); ok {
+		t.Fatal("least recently used entry not evicted")
+	}
+	if v, ok := cache.get("a"); !ok || v.longUrl != "1" || v.pttl != -1 {
+		t.Fatalf("recently used entry %+v, %v", v, ok)
+	}
+
+	cache.add("hour", resolvedLink{longUrl: "h"}, time.Hour)
+	if v, _ := cache.get("hour"); v.pttl <= 0 || v.pttl > time.Hour.Milliseconds() {
+		t.Fatalf("cached link ttl %dms, want the remaining link ttl", v.pttl)
+	}
+	cache.add("short", resolvedLink{longUrl: "x"}, time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	if _, ok := cache.get("short"); ok {
+		t.Fatal("entry outlived the link ttl")
+	}
+
+	var disabled *lruCache
+	disabled.add("a", resolvedLink{longUrl: "1"}, 0)
+	if _, ok := disabled.get("a"); ok {
+		t.Fatal("nil cache returned a value")
+	}
+}
+
+func BenchmarkRedirect(b *testing.B) {
+	for _, tc := range []struct {
+		name  string
+		cache *lruCache
+	}{
+		{"nocache", nil},
+		{"cache", newLRUCache(16, time.Minute)},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			router, mr := newTestRouter(b)
+			setFlag(b, &linkCache, tc.cache)
+			key := mustShorten(b, router, "https://example.com/bench", nil)
+
+			start := mr.CommandCount()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				serve(router, http.MethodGet, "/"+key, nil, nil)
+			}
+			b.ReportMetric(float64(mr.CommandCount()-start)/float64(b.N), "redis-cmds/op")
+		})
+	}
+}
+
+func TestCacheInvalidatedOnImport(t *testing.T) {
+	router, mr := newTestRouter(t)
+	setFlag(t, &adminToken, "secret-admin")
+	setFlag(t, &linkCache, newLRUCache(16, time.Minute))
+
+	for _, key := range []string{"ndjson", "csv"} {
+		mustShorten(t, router, "https://example.com/old", url.Values{"shortKey": {key}})
+		serve(router, http.MethodGet, "/"+key, nil, nil)
+		// 链接在 Redis 中被清除后，缓存仍保留旧的目标地址
+		mr.Del(key)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/import", strings.NewReader(`{"ShortKey":"ndjson","LongUrl":"https://example.com/new","TTL":-1}`+"\n"))
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	serveRequest(router, req)
+	importCsv(t, router, "short_url,long_url\nhttps://s.test/csv,https://example.com/new\n")
+
+	for _, key := range []string{"ndjson", "csv"} {
+		if got := serve(router, http.MethodGet, "/"+key, nil, nil).Header().Get("Location"); got != "https://example.com/new" {
+			t.Errorf("%s: stale cached destination %q after import", key, got)
+		}
+	}
+}
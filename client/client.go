@@ -0,0 +1,150 @@
+// Package client is a small Go client for the MyUrls HTTP API.
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Response is the response structure of the /short endpoint.
+type Response struct {
+	Code      int
+	Message   string
+	LongUrl   string
+	ShortUrl  string
+	RequestID string
+
+	// ShortUrlInsecure is the http variant of ShortUrl, only set when the server runs with -return-both.
+	ShortUrlInsecure string
+}
+
+// Link is the subset of the /stats response returned by Resolve.
+type Link struct {
+	Code      int
+	Message   string
+	ShortKey  string
+	LongUrl   string
+	TTL       int
+	CreatedAt int64
+	Hits      int64
+}
+
+// Options are the optional parameters of Shorten.
+type Options struct {
+	// ShortKey requests a custom short key instead of a generated one.
+	ShortKey string
+	// ShortUrlLen is the length of the generated short key, 0 for the server default.
+	ShortUrlLen int
+	// RequireAuth makes the link private, resolvable only with a valid JWT.
+	RequireAuth bool
+	// MaxClicks limits the number of redirects, 0 for unlimited.
+	MaxClicks int
+}
+
+// Error is returned when the server answers with Code 0.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("myurls: %d %s", e.StatusCode, e.Message)
+}
+
+// Client talks to a MyUrls server.
+type Client struct {
+	baseURL string
+	token   string
+
+	// HTTPClient is the client used for requests, http.DefaultClient if nil.
+	HTTPClient *http.Client
+}
+
+// New returns a client for the server at baseURL, including any -basepath.
+// token is sent as a bearer token and may be empty.
+func New(baseURL, token string) *Client {
+	return &Client{baseURL: strings.TrimRight(baseURL, "/"), token: token}
+}
+
+// Shorten creates a short link for longURL, opts may be nil.
+func (c *Client) Shorten(ctx context.Context, longURL string, opts *Options) (*Response, error) {
+	form := url.Values{}
+	form.Set("longUrl", base64.StdEncoding.EncodeToString([]byte(longURL)))
+	if opts != nil {
+		if opts.ShortKey != "" {
+			form.Set("shortKey", opts.ShortKey)
+		}
+		if opts.ShortUrlLen > 0 {
+			form.Set("shortUrlLen", strconv.Itoa(opts.ShortUrlLen))
+		}
+		if opts.RequireAuth {
+			form.Set("requireAuth", "true")
+		}
+		if opts.MaxClicks > 0 {
+			form.Set("maxClicks", strconv.Itoa(opts.MaxClicks))
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/short", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res := &Response{}
+	if err := c.do(req, res, &res.Code, &res.Message); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// Resolve returns the long URL and stats of a short key without counting a click.
+func (c *Client) Resolve(ctx context.Context, key string) (*Link, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/stats/"+url.PathEscape(key), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	link := &Link{}
+	if err := c.do(req, link, &link.Code, &link.Message); err != nil {
+		return nil, err
+	}
+
+	return link, nil
+}
+
+// do sends req and decodes the JSON body into v, mapping Code 0 to an *Error.
+func (c *Client) do(req *http.Request, v interface{}, code *int, message *string) error {
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return &Error{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+	}
+
+	if *code != 1 {
+		return &Error{StatusCode: resp.StatusCode, Message: *message}
+	}
+
+	return nil
+}
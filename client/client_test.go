@@ -0,0 +1,51 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestShortenForm(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/u/short" || r.Header.Get("Authorization") != "Bearer tok" || r.Header.Get("Accept") != "application/json" {
+			t.Errorf("request %s %v", r.URL.Path, r.Header)
+		}
+		longUrl, _ := base64.StdEncoding.DecodeString(r.PostFormValue("longUrl"))
+		if string(longUrl) != "https://example.com/" || r.PostFormValue("shortUrlLen") != "8" || r.PostFormValue("requireAuth") != "true" {
+			t.Errorf("form %v", r.PostForm)
+		}
+		_, _ = w.Write([]byte(`{"Code":1,"ShortUrl":"https://s.test/abcdefgh"}`))
+	}))
+	defer server.Close()
+
+	res, err := New(server.URL+"/u", "tok").Shorten(context.Background(), "https://example.com/", &Options{ShortUrlLen: 8, RequireAuth: true})
+	if err != nil || res.ShortUrl != "https://s.test/abcdefgh" {
+		t.Fatalf("%+v, %v", res, err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/stats/html" {
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte("<html>bad gateway</html>"))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"Code":0,"Message":"短链接不存在"}`))
+	}))
+	defer server.Close()
+	c := New(server.URL, "")
+
+	var apiErr *Error
+	if _, err := c.Resolve(context.Background(), "missing"); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.Message != "短链接不存在" {
+		t.Fatalf("JSON error: %v", err)
+	}
+	if _, err := c.Resolve(context.Background(), "html"); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("non-JSON error: %v", err)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/CareyWang/MyUrls/client"
+)
+
+func TestClientAgainstServer(t *testing.T) {
+	router, _ := newTestRouter(t)
+	setFlag(t, &authToken, "create-token")
+	server := httptest.NewServer(router)
+	defer server.Close()
+	ctx := context.Background()
+
+	c := client.New(server.URL+"/", authToken)
+	res, err := c.Shorten(ctx, "https://example.com/client", &client.Options{ShortKey: "viaclient", MaxClicks: 5})
+	if err != nil || res.ShortUrl != "https://s.test/viaclient" {
+		t.Fatalf("Shorten: %+v, %v", res, err)
+	}
+	link, err := c.Resolve(ctx, "viaclient")
+	if err != nil || link.LongUrl != "https://example.com/client" || link.TTL != -1 {
+		t.Fatalf("Resolve: %+v, %v", link, err)
+	}
+
+	var apiErr *client.Error
+	if _, err := c.Shorten(ctx, "https://example.com/again", &client.Options{ShortKey: "viaclient"}); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusConflict {
+		t.Fatalf("taken key: %v", err)
+	}
+	if _, err := client.New(server.URL, "wrong").Shorten(ctx, "https://example.com/", nil); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("wrong token: %v", err)
+	}
+}
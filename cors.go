@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// corsMaxAge is how long in seconds browsers may cache a preflight response.
+const corsMaxAge = "86400"
+
+// corsOrigins are the origins allowed to fetch short links cross-origin, "*" for any; CORS is disabled when empty.
+var corsOrigins []string
+
+// allowedOrigin returns the Access-Control-Allow-Origin value for a request Origin, empty when not allowed.
+func allowedOrigin(origin string) string {
+	if origin == "" {
+		return ""
+	}
+	for _, allowed := range corsOrigins {
+		if allowed == "*" {
+			return "*"
+		}
+		if strings.EqualFold(allowed, origin) {
+			return origin
+		}
+	}
+
+	return ""
+}
+
+// 为允许的来源设置跨域响应头
+func setCorsHeaders(context *gin.Context) bool {
+	origin := allowedOrigin(context.GetHeader("Origin"))
+	if origin == "" {
+		return false
+	}
+	context.Header("Access-Control-Allow-Origin", origin)
+	if origin != "*" {
+		context.Writer.Header().Add("Vary", "Origin")
+	}
+
+	return true
+}
+
+// 短链接跳转的跨域预检请求，仅返回 CORS 响应头，不跳转也不访问 Redis
+func preflightHandler(context *gin.Context) {
+	if shadowedRoute(context) {
+		return
+	}
+	context.Header("Allow", "GET, HEAD, OPTIONS")
+	if setCorsHeaders(context) {
+		context.Header("Access-Control-Allow-Methods", "GET, HEAD")
+		if requested := context.GetHeader("Access-Control-Request-Headers"); requested != "" {
+			context.Header("Access-Control-Allow-Headers", requested)
+			context.Writer.Header().Add("Vary", "Access-Control-Request-Headers")
+		}
+		context.Header("Access-Control-Max-Age", corsMaxAge)
+	}
+	context.Status(http.StatusNoContent)
+}
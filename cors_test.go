@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCorsPreflight(t *testing.T) {
+	router, mr := newTestRouter(t)
+	setFlag(t, &corsOrigins, []string{"https://app.test"})
+	key := mustShorten(t, router, "https://example.com/cors", nil)
+	commands := mr.CommandCount()
+
+	header := http.Header{"Origin": {"https://app.test"}, "Access-Control-Request-Headers": {"X-Custom"}}
+	w := serve(router, http.MethodOptions, "/"+key, nil, header)
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "https://app.test" {
+		t.Fatalf("preflight: status %d, %v", w.Code, w.Header())
+	}
+	if w.Header().Get("Access-Control-Allow-Methods") != "GET, HEAD" || w.Header().Get("Access-Control-Allow-Headers") != "X-Custom" {
+		t.Fatalf("preflight headers %v", w.Header())
+	}
+	if mr.CommandCount() != commands {
+		t.Fatal("preflight queried Redis")
+	}
+
+	w = serve(router, http.MethodGet, "/"+key, nil, header)
+	if w.Header().Get("Access-Control-Allow-Origin") != "https://app.test" || w.Header().Get("Vary") != "Origin" {
+		t.Fatalf("redirect CORS headers %v", w.Header())
+	}
+
+	header.Set("Origin", "https://evil.test")
+	if w := serve(router, http.MethodOptions, "/"+key, nil, header); w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatal("CORS allowed for another origin")
+	}
+
+	setFlag(t, &corsOrigins, []string{"*"})
+	if got := allowedOrigin("https://any.test"); got != "*" {
+		t.Fatalf("wildcard origin %q", got)
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// errKeyTaken is returned when a custom short key already points to another long URL.
+var errKeyTaken = errors.New("短链接已存在，请更换key")
+
+// errKeyExists is returned when a custom short key is resubmitted with settings for an existing link.
+var errKeyExists = errors.New("短链接已存在，不能修改其设置")
+
+// linkRequest is a validated request to create a short link.
+type linkRequest struct {
+	longUrl        string
+	shortKey       string
+	shortUrlLen    int
+	ttl            int
+	customTTL      bool
+	requireAuth    bool
+	maxClicks      int
+	utm            string
+	languages      string
+	platforms      string
+	tags           []string
+	unlisted       bool
+	redirectStatus int
+	clientIP       string
+}
+
+// statusError is an error carrying the HTTP status it is reported with.
+type statusError struct {
+	status int
+	err    error
+}
+
+func (e *statusError) Error() string {
+	return e.err.Error()
+}
+
+func (e *statusError) Unwrap() error {
+	return e.err
+}
+
+// errorStatus returns the HTTP status of an error returned by createLink.
+func errorStatus(err error) int {
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.status
+	}
+
+	return http.StatusInternalServerError
+}
+
+// shareable reports whether a link may be handed out to every creator of the same long URL, i.e. it carries
+// none of the per-creator settings that a second creator must neither see nor overwrite. Only shareable
+// links get an md5 reverse mapping.
+func shareable(req linkRequest) bool {
+	return !req.requireAuth && !req.unlisted && req.maxClicks == 0 && req.utm == "" && len(req.tags) == 0 &&
+		!req.customTTL && req.languages == "" && req.platforms == "" && req.redirectStatus == 0
+}
+
+// configureLinkRules applies the flags createLink validates links and generates keys against: -schemes,
+// -allow-hosts, -max-url-len, -keygen and -blocklist. Shared by the server and the create subcommand,
+// so the command line can't create links the server would reject.
+func configureLinkRules(schemeList string, hostList string, blocklist string) error {
+	allowedSchemes = map[string]struct{}{}
+	for _, scheme := range splitList(schemeList) {
+		allowedSchemes[strings.ToLower(scheme)] = struct{}{}
+	}
+	if len(allowedSchemes) == 0 {
+		return errors.New("schemes 不能为空")
+	}
+	allowedHosts = nil
+	for _, host := range splitList(hostList) {
+		allowedHosts = append(allowedHosts, strings.ToLower(host))
+	}
+	if maxUrlLen < 1 {
+		return errors.New("max-url-len 必须大于0")
+	}
+	blockedTokens = nil
+	if blocklist != "" {
+		tokens, err := loadBlocklist(blocklist)
+		if err != nil {
+			return fmt.Errorf("blocklist 读取失败: %v", err)
+		}
+		blockedTokens = tokens
+	}
+	if keyMode != keyModeRandom && keyMode != keyModeCounter {
+		return errors.New("keygen 仅支持 random 或 counter")
+	}
+
+	return nil
+}
+
+// createLink stores a short link for req, under its custom key when given, and records its metadata.
+// It returns the short key and the title fetched with -fetch-title. Shared by /short and the create subcommand.
+func createLink(req linkRequest) (string, string, error) {
+	shortKey := req.shortKey
+	if shortKey != "" {
+		shortKey = normalizeShortKey(shortKey)
+		if err := validateShortKey(shortKey); err != nil {
+			return "", "", &statusError{http.StatusBadRequest, err}
+		}
+		// 开启校验位时，自定义短链接同样追加校验位，与 /available 和预留一致
+		shortKey = withChecksum(shortKey)
+
+		redisClient := redisPool.Get()
+		defer redisClient.Close()
+
+		// 仅在 key 不存在时写入，避免先查后写之间被并发请求抢占
+		args := []interface{}{redisKey(shortKey), req.longUrl, "nx"}
+		if req.customTTL {
+			args = append(args, "ex", req.ttl)
+		}
+		_, err := redis.String(redisClient.Do("set", args...))
+		if err == redis.ErrNil {
+			// 已存在的链接原样返回，不能借重复提交改写他人链接的设置或有效期
+			_exists, err := redis.String(redisClient.Do("get", redisKey(shortKey)))
+			if err != nil && err != redis.ErrNil {
+				return "", "", storageError(err)
+			}
+			if _exists != req.longUrl {
+				return "", "", &statusError{http.StatusConflict, errKeyTaken}
+			}
+			if !shareable(req) {
+				return "", "", &statusError{http.StatusConflict, errKeyExists}
+			}
+			return shortKey, "", nil
+		} else if err != nil {
+			if err := storageError(err); err == errStorageReadOnly {
+				return "", "", &statusError{http.StatusServiceUnavailable, err}
+			}
+			return "", "", storageError(err)
+		}
+	} else {
+		// 带有创建者设置的链接不复用已有短链接，避免被其他用户共享或修改
+		dedup := shareable(req) && !noDedup
+		var err error
+		shortKey, err = longToShort(req.longUrl, req.ttl, req.shortUrlLen, dedup, req.unlisted)
+		if errors.Is(err, errCounterOverflow) {
+			return "", "", &statusError{http.StatusBadRequest, err}
+		} else if errors.Is(err, errStorageReadOnly) {
+			return "", "", &statusError{http.StatusServiceUnavailable, err}
+		} else if err != nil {
+			return "", "", err
+		}
+	}
+
+	if linkSecret != "" {
+		redisClient := redisPool.Get()
+		err := signLink(redisClient, shortKey, req.longUrl)
+		redisClient.Close()
+		if err != nil {
+			return "", "", storageError(err)
+		}
+	}
+
+	_ = markLinkCreated(shortKey)
+	if req.clientIP != "" {
+		recordCreatorIP(req.clientIP, shortKey)
+		if recordIP {
+			_ = markLinkCreatedBy(shortKey, req.clientIP)
+		}
+	}
+	meta := map[string]interface{}{}
+	if req.requireAuth {
+		meta["requireAuth"] = 1
+	}
+	if req.maxClicks > 0 {
+		meta["maxClicks"] = req.maxClicks
+	}
+	if req.unlisted {
+		meta["unlisted"] = 1
+	}
+	if req.customTTL {
+		meta["customTTL"] = 1
+	}
+	if req.utm != "" {
+		meta["utm"] = req.utm
+	}
+	if req.languages != "" {
+		meta["languages"] = req.languages
+	}
+	if req.platforms != "" {
+		meta["platforms"] = req.platforms
+	}
+	// 备选目标地址与长链接一样需签名，否则改写元数据即可劫持跳转
+	if linkSecret != "" && (req.languages != "" || req.platforms != "") {
+		meta["destinationsHmac"] = destinationsHMAC(shortKey, req.platforms, req.languages)
+	}
+	if req.redirectStatus != 0 {
+		meta["redirectStatus"] = req.redirectStatus
+	}
+	if len(req.tags) > 0 {
+		meta["tags"] = encodeTags(req.tags)
+		_ = tagLink(shortKey, req.tags)
+	}
+	title := ""
+	if fetchTitles {
+		// 尽力获取，失败时标题为空，不影响创建
+		if title = fetchTitle(req.longUrl); title != "" {
+			meta["title"] = title
+		}
+	}
+	if len(meta) > 0 {
+		_ = setLinkMeta(shortKey, meta)
+	}
+
+	return shortKey, title, nil
+}
+
+// 不启动服务，直接连接 Redis 创建短链接：myurls create -domain <domain> -long <url> [-key <key>]
+func runCreateCommand(args []string) int {
+	fs := flag.NewFlagSet("create", flag.ContinueOnError)
+	conn := fs.String("conn", "127.0.0.1:6379", "Redis连接，格式: host:port 或 redis://")
+	passwd := fs.String("passwd", "", "Redis连接密码")
+	fs.StringVar(&domain, "domain", "", "短链接域名，必填项")
+	fs.StringVar(&keyPrefix, "prefix", "", "Redis 键前缀，需与服务端一致")
+	fs.StringVar(&linkSecret, "secret", "", "长链接完整性校验密钥，需与服务端一致")
+	fs.BoolVar(&enableChecksum, "checksum", false, "短链接追加校验位，需与服务端一致")
+	longUrl := fs.String("long", "", "长链接，必填项")
+	shortKey := fs.String("key", "", "自定义短链接，为空则自动生成")
+	ttlStr := fs.String("ttl", "", "短链接有效期，纯数字单位为天，也支持 30m、12h、7d 等时长，默认180天")
+	useHttps := fs.Bool("https", true, "输出 https 短链接")
+	alphabet := fs.String("alphabet", "", "短链接字符集，需与服务端一致")
+	fs.BoolVar(&caseInsensitive, "case-insensitive", false, "短链接不区分大小写，需与服务端一致")
+	fs.BoolVar(&readableKeys, "readable-keys", false, "生成不含易混淆字符且以字母开头的短链接，需与服务端一致")
+	fs.BoolVar(&subdomainMode, "subdomain-mode", false, "输出子域名形式的短链接，需与服务端一致")
+	fs.StringVar(&basePath, "basepath", "", "短链接路径前缀，需与服务端一致")
+	fs.StringVar(&keyMode, "keygen", keyModeRandom, "短链接生成方式: random 或 counter，需与服务端一致")
+	blocklist := fs.String("blocklist", "", "生成短链接时屏蔽的词表文件，需与服务端一致")
+	fs.BoolVar(&noDedup, "no-dedup", false, "不复用相同长链接的短链接")
+	schemeList := fs.String("schemes", defaultAllowedSchemes, "允许的长链接协议，逗号分隔，需与服务端一致")
+	hostList := fs.String("allow-hosts", "", "允许的长链接域名，逗号分隔，需与服务端一致")
+	fs.IntVar(&maxUrlLen, "max-url-len", defaultMaxUrlLen, "长链接最大长度，单位(字节)，需与服务端一致")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if domain == "" || *longUrl == "" {
+		fmt.Fprintln(os.Stderr, "缺少 -domain 或 -long")
+		return 2
+	}
+	if err := configureKeyAlphabet(*alphabet); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	basePath = normalizeBasePath(basePath)
+	https = 0
+	if *useHttps {
+		https = 1
+	}
+	if err := configureLinkRules(*schemeList, *hostList, *blocklist); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if err := validateLongUrl(*longUrl); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	req := linkRequest{longUrl: *longUrl, shortKey: *shortKey, shortUrlLen: currentShortUrlLen(), ttl: defaultExpire * secondsPerDay}
+	if *ttlStr != "" {
+		ttl, err := parseTTL(*ttlStr)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "ttl 无效:", err)
+			return 2
+		}
+		req.ttl = ttl
+		req.customTTL = true
+	}
+
+	endpoint, err := parseRedisConn(*conn)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if *passwd != "" {
+		endpoint.password = *passwd
+	}
+	redisPoolConfig = &redisPoolConf{
+		maxIdle:        1,
+		maxActive:      defaultRedisMaxActive,
+		maxIdleTimeout: defaultRedisIdleTimeout,
+		network:        endpoint.network,
+		host:           endpoint.address,
+		password:       endpoint.password,
+		db:             endpoint.db,
+		useTLS:         endpoint.useTLS,
+		handleTimeout:  defaultRedisHandleTimeout,
+	}
+	initRedisPool()
+	defer redisPool.Close()
+
+	linkTTL = req.ttl
+	generateRetries = defaultGenerateRetries
+	created, _, err := createLink(req)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "创建失败:", err)
+		return 1
+	}
+
+	fmt.Println(linkAddress(created))
+
+	return 0
+}
@@ -0,0 +1,563 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCreateReservedKeys(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	for key := range reservedKeys {
+		code, res := shorten(t, router, "https://example.com/", url.Values{"shortKey": {key}})
+		if code != http.StatusBadRequest || res.Message != "该短链接为保留字" {
+			t.Errorf("reserved key %q: status %d, %+v", key, code, res)
+		}
+	}
+	code, res := shorten(t, router, "https://example.com/", url.Values{"shortKey": {"Short"}})
+	if code != http.StatusBadRequest || res.Message != "该短链接为保留字" {
+		t.Errorf("reserved key in another case: status %d, %+v", code, res)
+	}
+}
+
+func TestCreateIllegalKey(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	for _, key := range []string{"a/b", "a.b", "中文", "a b"} {
+		code, res := shorten(t, router, "https://example.com/", url.Values{"shortKey": {key}})
+		if code != http.StatusBadRequest || res.Message != "短链接包含非法字符" {
+			t.Errorf("key %q: status %d, %+v", key, code, res)
+		}
+	}
+}
+
+func TestCreateCustomKey(t *testing.T) {
+	router, mr := newTestRouter(t)
+
+	if key := mustShorten(t, router, "https://example.com/custom", url.Values{"shortKey": {"mine"}}); key != "mine" {
+		t.Fatalf("custom key %q, want mine", key)
+	}
+	if mr.TTL("mine") != 0 {
+		t.Fatalf("custom key without ttl expires in %v", mr.TTL("mine"))
+	}
+
+	// 同一长链接重复提交幂等，不同长链接冲突
+	if key := mustShorten(t, router, "https://example.com/custom", url.Values{"shortKey": {"mine"}}); key != "mine" {
+		t.Fatalf("resubmitted custom key %q, want mine", key)
+	}
+	if code, _ := shorten(t, router, "https://example.com/other", url.Values{"shortKey": {"mine"}}); code != http.StatusConflict {
+		t.Fatalf("taken custom key: status %d, want 409", code)
+	}
+
+	// 重复提交不能改写已有链接的设置与有效期
+	mustShorten(t, router, "https://example.com/timed", url.Values{"shortKey": {"timed"}, "ttl": {"1h"}})
+	if key := mustShorten(t, router, "https://example.com/timed", url.Values{"shortKey": {"timed"}}); key != "timed" || mr.TTL("timed") <= 0 {
+		t.Fatalf("resubmitted key %q lost its ttl: %v", key, mr.TTL("timed"))
+	}
+	for name, extra := range map[string]url.Values{
+		"maxClicks":      {"maxClicks": {"1"}},
+		"ttl":            {"ttl": {"2h"}},
+		"redirectStatus": {"redirectStatus": {"302"}},
+		"tags":           {"tags": {"x"}},
+	} {
+		extra.Set("shortKey", "mine")
+		if code, res := shorten(t, router, "https://example.com/custom", extra); code != http.StatusConflict || res.Message != errKeyExists.Error() {
+			t.Errorf("resubmit with %s: status %d, %+v", name, code, res)
+		}
+	}
+	if meta, _ := mr.HKeys(defaultMetaPrefix + "mine"); len(meta) > 1 || mr.TTL("mine") != 0 {
+		t.Fatalf("resubmissions rewrote the link: meta %v, ttl %v", meta, mr.TTL("mine"))
+	}
+}
+
+func TestCreateShortUrlLen(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	for _, raw := range []string{"-1", "0", "21", "999999999999999999999", "  ", "+6", "6a"} {
+		if strings.TrimSpace(raw) == "" {
+			// 空白与未填写相同，使用默认长度
+			if key := mustShorten(t, router, "https://example.com/blank", url.Values{"shortUrlLen": {raw}}); len(key) != defaultShortUrlLen {
+				t.Errorf("shortUrlLen %q: key %q", raw, key)
+			}
+			continue
+		}
+		code, res := shorten(t, router, "https://example.com/"+raw, url.Values{"shortUrlLen": {raw}})
+		if code != http.StatusBadRequest || !strings.Contains(res.Message, "shortUrlLen") {
+			t.Errorf("shortUrlLen %q: status %d, %+v", raw, code, res)
+		}
+	}
+
+	if key := mustShorten(t, router, "https://example.com/len", url.Values{"shortUrlLen": {"12"}}); len(key) != 12 {
+		t.Fatalf("shortUrlLen 12 gave key %q", key)
+	}
+}
+
+func TestCreateConcurrentDistinctKeys(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	const n = 50
+	keys := make([]string, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// 使用 2 位 key 提高冲突概率，依赖 SET NX 保证不会相互覆盖
+			form := url.Values{
+				"longUrl":     {base64.StdEncoding.EncodeToString([]byte("https://example.com/" + strconv.Itoa(i)))},
+				"shortUrlLen": {"2"},
+			}
+			w := serve(router, http.MethodPost, "/short?format=text", form, nil)
+			if w.Code == http.StatusOK {
+				keys[i] = strings.TrimPrefix(w.Body.String(), "https://s.test/")
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	seen := map[string]int{}
+	for i, key := range keys {
+		if key == "" {
+			continue
+		}
+		if j, ok := seen[key]; ok {
+			t.Fatalf("urls %d and %d both got key %q", i, j, key)
+		}
+		seen[key] = i
+	}
+}
+
+func TestCreateCollisionsExhaustRetries(t *testing.T) {
+	router, mr := newTestRouter(t)
+	setFlag(t, &keyAlphabet, "a")
+	setFlag(t, &generateRetries, 2)
+
+	// 单字符集下候选 key 唯一，预先占用后重试必然失败
+	mr.Set("a", "https://example.com/taken")
+	code, res := shorten(t, router, "https://example.com/new", url.Values{"shortUrlLen": {"1"}})
+	if code != http.StatusInternalServerError || res.Message != errGenerateFailed.Error() {
+		t.Fatalf("status %d, %+v", code, res)
+	}
+
+	// 第三次重试起 key 长度加一
+	setFlag(t, &generateRetries, 3)
+	if key := mustShorten(t, router, "https://example.com/new", url.Values{"shortUrlLen": {"1"}}); key != "aa" {
+		t.Fatalf("key %q, want aa", key)
+	}
+}
+
+func TestCreateMaxUrlLen(t *testing.T) {
+	router, _ := newTestRouter(t)
+	setFlag(t, &maxUrlLen, 40)
+
+	base := "https://example.com/"
+	atLimit := base + strings.Repeat("a", 40-len(base))
+	mustShorten(t, router, atLimit, nil)
+
+	code, res := shorten(t, router, atLimit+"a", nil)
+	if code != http.StatusBadRequest || res.Message != "长链接过长" {
+		t.Fatalf("over the limit: status %d, %+v", code, res)
+	}
+}
+
+func TestCreateDangerousSchemes(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	for _, longUrl := range []string{
+		"javascript:alert(1)",
+		"data:text/html;base64,PHNjcmlwdD4=",
+		"vbscript:msgbox(1)",
+		"file:///etc/passwd",
+		"example.com/path",
+		"https:///nohost",
+	} {
+		if code, res := shorten(t, router, longUrl, nil); code != http.StatusBadRequest || res.Code != 0 {
+			t.Errorf("%q: status %d, %+v", longUrl, code, res)
+		}
+	}
+}
+
+func TestCreateBase64Variants(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	// 含 + 与 / 的编码结果，以及需要填充的长度
+	const longUrl = "https://example.com/?q=>>>???"
+	var keys []string
+	for _, encoding := range longUrlEncodings {
+		form := url.Values{"longUrl": {encoding.EncodeToString([]byte(longUrl))}}
+		w := serve(router, http.MethodPost, "/short", form, nil)
+		var res Response
+		decode(t, w, &res)
+		if w.Code != http.StatusOK || res.LongUrl != longUrl {
+			t.Fatalf("encoding %v: status %d, %+v", encoding, w.Code, res)
+		}
+		keys = append(keys, res.ShortUrl)
+	}
+	for _, key := range keys[1:] {
+		if key != keys[0] {
+			t.Fatalf("encodings gave different links: %v", keys)
+		}
+	}
+
+	if code, _ := shorten(t, router, "", nil); code != http.StatusBadRequest {
+		t.Fatalf("empty longUrl: status %d, want 400", code)
+	}
+	if w := serve(router, http.MethodPost, "/short", url.Values{"longUrl": {"not base64!"}}, nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid base64: status %d, want 400", w.Code)
+	}
+}
+
+func TestCreateTTL(t *testing.T) {
+	router, mr := newTestRouter(t)
+
+	for _, tc := range []struct {
+		ttl  string
+		want time.Duration
+	}{
+		{"90m", 90 * time.Minute},
+		{"2d", 48 * time.Hour},
+		{"7", 7 * 24 * time.Hour},
+	} {
+		key := mustShorten(t, router, "https://example.com/ttl/"+tc.ttl, url.Values{"ttl": {tc.ttl}})
+		if got := mr.TTL(key); got != tc.want {
+			t.Errorf("ttl %q: %v, want %v", tc.ttl, got, tc.want)
+		}
+	}
+
+	key := mustShorten(t, router, "https://example.com/ttl/default", nil)
+	if got, want := mr.TTL(key), time.Duration(linkTTL)*time.Second; got != want {
+		t.Errorf("default ttl %v, want %v", got, want)
+	}
+
+	for _, ttl := range []string{"0", "-1", "1s5", "soon", "40000d"} {
+		if code, _ := shorten(t, router, "https://example.com/ttl/bad", url.Values{"ttl": {ttl}}); code != http.StatusBadRequest {
+			t.Errorf("ttl %q: status %d, want 400", ttl, code)
+		}
+	}
+}
+
+func TestCreateNoDedup(t *testing.T) {
+	router, _ := newTestRouter(t)
+	setFlag(t, &noDedup, true)
+
+	first := mustShorten(t, router, "https://example.com/same", nil)
+	second := mustShorten(t, router, "https://example.com/same", nil)
+	if first == second {
+		t.Fatalf("-no-dedup returned the same key %q twice", first)
+	}
+}
+
+func TestCreateDedupSkipsCreatorSettings(t *testing.T) {
+	router, mr := newTestRouter(t)
+
+	for name, fields := range map[string]url.Values{
+		"languages":      {"languages": {`{"de":"https://example.com/de"}`}},
+		"platforms":      {"platforms": {`{"ios":"https://apps.example.com/ios"}`}},
+		"redirectStatus": {"redirectStatus": {"302"}},
+		"maxClicks":      {"maxClicks": {"3"}},
+		"utm":            {"utm": {`{"utm_source":"mail"}`}},
+		"tags":           {"tags": {"promo"}},
+		"ttl":            {"ttl": {"1h"}},
+		"private":        {"private": {"1"}},
+	} {
+		longUrl := "https://example.com/shared/" + name
+
+		// 第二个创建者的设置不能写入已有的共享链接
+		first := mustShorten(t, router, longUrl, nil)
+		second := mustShorten(t, router, longUrl, fields)
+		if second == first {
+			t.Errorf("%s: second creator got the shared key %q", name, first)
+		}
+		if meta, _ := mr.HKeys(defaultMetaPrefix + first); len(meta) != 1 || meta[0] != "createdAt" {
+			t.Errorf("%s: second creator changed the shared link's meta to %v", name, meta)
+		}
+		w := serve(router, http.MethodGet, "/"+first, nil, http.Header{"Accept-Language": {"de"}, "User-Agent": {"iPhone"}})
+		if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != longUrl {
+			t.Errorf("%s: shared link redirects with %d to %q", name, w.Code, w.Header().Get("Location"))
+		}
+
+		// 带有设置的链接不会被其他创建者复用
+		if third := mustShorten(t, router, longUrl, nil); third != first {
+			t.Errorf("%s: plain creator got %q instead of the shared key %q", name, third, first)
+		}
+		mr.Del(defaultMd5Prefix + longUrlHash(longUrl))
+		if fourth := mustShorten(t, router, longUrl, nil); fourth == second {
+			t.Errorf("%s: plain creator got the key %q of a link with settings", name, second)
+		}
+	}
+}
+
+func TestCreateDedupKeepsTTL(t *testing.T) {
+	router, mr := newTestRouter(t)
+
+	// 复用已有短链接时仅延长有效期
+	short := mustShorten(t, router, "https://example.com/short-lived", nil)
+	mr.SetTTL(short, time.Hour)
+	if again := mustShorten(t, router, "https://example.com/short-lived", nil); again != short || mr.TTL(short) != time.Duration(linkTTL)*time.Second {
+		t.Fatalf("dedup hit: key %q, ttl %v", again, mr.TTL(short))
+	}
+
+	renewed := mustShorten(t, router, "https://example.com/renewed", nil)
+	mr.SetTTL(renewed, 400*24*time.Hour)
+	if mustShorten(t, router, "https://example.com/renewed", nil); mr.TTL(renewed) != 400*24*time.Hour {
+		t.Fatalf("dedup hit shortened a renewed link to %v", mr.TTL(renewed))
+	}
+
+	persistent := mustShorten(t, router, "https://example.com/persistent", nil)
+	mr.SetTTL(persistent, 0)
+	if mustShorten(t, router, "https://example.com/persistent", nil); mr.TTL(persistent) != 0 {
+		t.Fatalf("dedup hit expired a persistent link in %v", mr.TTL(persistent))
+	}
+
+	// 短链接已过期时不返回残留映射中的 key
+	gone := mustShorten(t, router, "https://example.com/gone", nil)
+	mr.Del(gone)
+	if again := mustShorten(t, router, "https://example.com/gone", nil); again == gone {
+		t.Fatalf("dedup returned the expired key %q", gone)
+	}
+}
+
+func TestCreateDedupKeyIsNotMd5(t *testing.T) {
+	router, mr := newTestRouter(t)
+	setFlag(t, &linkSecret, "salt")
+
+	key := mustShorten(t, router, "https://example.com/salted", nil)
+	if again := mustShorten(t, router, "https://example.com/salted", nil); again != key {
+		t.Fatalf("dedup broken: %q then %q", key, again)
+	}
+
+	mapping := ""
+	for _, k := range mr.Keys() {
+		if strings.HasPrefix(k, defaultMd5Prefix) {
+			mapping = strings.TrimPrefix(k, defaultMd5Prefix)
+		}
+	}
+	if mapping == "" || len(mapping) == 32 || mapping == longUrlHashUnsalted("https://example.com/salted") {
+		t.Fatalf("dedup key %q is a bare digest", mapping)
+	}
+}
+
+// longUrlHashUnsalted is the digest longUrlHash returns without -secret.
+func longUrlHashUnsalted(longUrl string) string {
+	old := linkSecret
+	linkSecret = ""
+	defer func() { linkSecret = old }()
+
+	return longUrlHash(longUrl)
+}
+
+func TestCreateCandidates(t *testing.T) {
+	router, mr := newTestRouter(t)
+
+	code, res := shorten(t, router, "https://example.com/pick", url.Values{"count": {"5"}})
+	if code != http.StatusOK || len(res.Candidates) != 5 {
+		t.Fatalf("status %d, %+v", code, res)
+	}
+	seen := map[string]struct{}{}
+	for _, candidate := range res.Candidates {
+		if _, ok := seen[candidate]; ok {
+			t.Fatalf("duplicate candidate %q", candidate)
+		}
+		seen[candidate] = struct{}{}
+		if mr.Exists(candidate) {
+			t.Fatalf("candidate %q stored before it was chosen", candidate)
+		}
+	}
+
+	if key := mustShorten(t, router, "https://example.com/pick", url.Values{"shortKey": {res.Candidates[2]}}); key != res.Candidates[2] {
+		t.Fatalf("chosen candidate stored as %q", key)
+	}
+
+	for _, count := range []string{"0", "6", "x"} {
+		if code, _ := shorten(t, router, "https://example.com/pick", url.Values{"count": {count}}); code != http.StatusBadRequest {
+			t.Errorf("count %q: status %d, want 400", count, code)
+		}
+	}
+}
+
+func TestCreatedAt(t *testing.T) {
+	router, mr := newTestRouter(t)
+
+	before := time.Now().Unix()
+	key := mustShorten(t, router, "https://example.com/created", nil)
+	createdAt, err := strconv.ParseInt(mr.HGet(defaultMetaPrefix+key, "createdAt"), 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if createdAt < before || createdAt > time.Now().Unix()+1 {
+		t.Fatalf("createdAt %d not within a second of %d", createdAt, before)
+	}
+}
+
+func TestCreatePlainText(t *testing.T) {
+	router, _ := newTestRouter(t)
+	form := url.Values{"longUrl": {base64.StdEncoding.EncodeToString([]byte("https://example.com/text"))}}
+
+	w := serve(router, http.MethodPost, "/short", form, http.Header{"Accept": {"text/plain"}})
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Body.String(), "https://s.test/") {
+		t.Fatalf("text/plain: status %d, %q", w.Code, w.Body)
+	}
+	w = serve(router, http.MethodPost, "/short?format=text", form, nil)
+	if !strings.HasPrefix(w.Body.String(), "https://s.test/") {
+		t.Fatalf("format=text: %q", w.Body)
+	}
+	w = serve(router, http.MethodPost, "/short", form, http.Header{"Accept": {"application/json"}})
+	var res Response
+	decode(t, w, &res)
+	if res.Code != 1 {
+		t.Fatalf("json: %+v", res)
+	}
+}
+
+func TestCreateReturnBoth(t *testing.T) {
+	router, _ := newTestRouter(t)
+	setFlag(t, &returnBoth, true)
+
+	_, res := shorten(t, router, "https://example.com/both", nil)
+	if !strings.HasPrefix(res.ShortUrl, "https://s.test/") || res.ShortUrlInsecure != "http"+strings.TrimPrefix(res.ShortUrl, "https") {
+		t.Fatalf("%+v", res)
+	}
+}
+
+func TestCreateHttpsAuto(t *testing.T) {
+	router, _ := newTestRouter(t)
+	setFlag(t, &httpsAuto, true)
+	setFlag(t, &https, 0)
+	form := url.Values{"longUrl": {base64.StdEncoding.EncodeToString([]byte("https://example.com/proto"))}}
+
+	for _, tc := range []struct {
+		proto string
+		want  string
+	}{
+		{"", "http://"},
+		{"https", "https://"},
+		{"https, http", "https://"},
+		{"http", "http://"},
+	} {
+		header := http.Header{}
+		if tc.proto != "" {
+			header.Set("X-Forwarded-Proto", tc.proto)
+		}
+		w := serve(router, http.MethodPost, "/short?format=text", form, header)
+		if !strings.HasPrefix(w.Body.String(), tc.want) {
+			t.Errorf("X-Forwarded-Proto %q: %q", tc.proto, w.Body)
+		}
+	}
+}
+
+func TestParseTTL(t *testing.T) {
+	for _, tc := range []struct {
+		raw  string
+		want int
+	}{
+		{"90m", 5400},
+		{"12h", 43200},
+		{"2d", 2 * secondsPerDay},
+		{"1.5d", 36 * 3600},
+		{"30", 30 * secondsPerDay},
+	} {
+		if got, err := parseTTL(tc.raw); err != nil || got != tc.want {
+			t.Errorf("parseTTL(%q) = %d, %v, want %d", tc.raw, got, err, tc.want)
+		}
+	}
+	for _, raw := range []string{"", "0", "500ms", "-2h", "NaNd", "36501"} {
+		if _, err := parseTTL(raw); err == nil {
+			t.Errorf("parseTTL(%q) accepted", raw)
+		}
+	}
+}
+
+func TestCreateCommand(t *testing.T) {
+	_, mr := newTestRouter(t)
+	for _, p := range []*string{&keyPrefix, &linkSecret} {
+		setFlag(t, p, "")
+	}
+	setFlag(t, &enableChecksum, false)
+
+	code, out := runCommand(t, runCreateCommand, "-conn", mr.Addr(), "-domain", "cli.test", "-long", "https://example.com/cli", "-key", "fromcli", "-ttl", "2h")
+	if code != 0 || out != "https://cli.test/fromcli\n" {
+		t.Fatalf("exit code %d, output %q", code, out)
+	}
+	if got, _ := mr.Get("fromcli"); got != "https://example.com/cli" {
+		t.Fatalf("stored %q", got)
+	}
+	if ttl := mr.TTL("fromcli"); ttl <= 0 || ttl > 2*time.Hour {
+		t.Fatalf("ttl %v", ttl)
+	}
+
+	code, out = runCommand(t, runCreateCommand, "-conn", mr.Addr(), "-domain", "cli.test", "-long", "https://example.com/generated", "-https=false")
+	if code != 0 || !strings.HasPrefix(out, "http://cli.test/") {
+		t.Fatalf("generated key: exit code %d, output %q", code, out)
+	}
+
+	// 字符集、路径前缀与子域名参数与服务端一致
+	for _, p := range []*bool{&caseInsensitive, &readableKeys, &subdomainMode} {
+		setFlag(t, p, false)
+	}
+	for _, p := range []*string{&keyAlphabet, &readableAlphabet, &readableFirstAlphabet, &basePath} {
+		setFlag(t, p, *p)
+	}
+	code, out = runCommand(t, runCreateCommand, "-conn", mr.Addr(), "-domain", "cli.test", "-long", "https://example.com/readable", "-readable-keys", "-basepath", "/u/")
+	key := strings.TrimSuffix(strings.TrimPrefix(out, "https://cli.test/u/"), "\n")
+	if code != 0 || key == out || strings.ContainsAny(key, ambiguousChars) || !strings.ContainsAny(key[:1], readableFirstAlphabet) {
+		t.Fatalf("readable key under a base path: exit code %d, output %q", code, out)
+	}
+	code, out = runCommand(t, runCreateCommand, "-conn", mr.Addr(), "-domain", "cli.test", "-long", "https://example.com/sub", "-key", "Promo", "-case-insensitive", "-subdomain-mode")
+	if code != 0 || out != "https://promo.cli.test\n" {
+		t.Fatalf("subdomain mode: exit code %d, output %q", code, out)
+	}
+	code, out = runCommand(t, runCreateCommand, "-conn", mr.Addr(), "-domain", "cli.test", "-long", "https://example.com/abc", "-alphabet", "ab")
+	if key := strings.TrimPrefix(out, "https://cli.test/"); code != 0 || strings.Trim(key, "ab\n") != "" {
+		t.Fatalf("custom alphabet: exit code %d, output %q", code, out)
+	}
+
+	for _, args := range [][]string{
+		{"-conn", mr.Addr(), "-long", "https://example.com/"},
+		{"-conn", mr.Addr(), "-domain", "cli.test", "-long", "ftp://example.com/"},
+		{"-conn", "redis://x:99999", "-domain", "cli.test", "-long", "https://example.com/"},
+		{"-conn", mr.Addr(), "-domain", "cli.test", "-long", "https://example.com/", "-subdomain-mode"},
+		{"-conn", mr.Addr(), "-domain", "cli.test", "-long", "https://example.com/", "-alphabet", "a"},
+		{"-conn", mr.Addr(), "-domain", "cli.test", "-long", "http://example.com/", "-schemes", "https"},
+		{"-conn", mr.Addr(), "-domain", "cli.test", "-long", "https://other.test/", "-allow-hosts", "example.com"},
+		{"-conn", mr.Addr(), "-domain", "cli.test", "-long", "https://example.com/long/path", "-max-url-len", "20"},
+		{"-conn", mr.Addr(), "-domain", "cli.test", "-long", "https://example.com/", "-keygen", "sequential"},
+		{"-conn", mr.Addr(), "-domain", "cli.test", "-long", "https://example.com/", "-blocklist", filepath.Join(t.TempDir(), "missing.txt")},
+	} {
+		if code, _ := runCommand(t, runCreateCommand, args...); code != 2 {
+			t.Errorf("args %v: exit code %d, want 2", args, code)
+		}
+	}
+	if code, _ := runCommand(t, runCreateCommand, "-conn", mr.Addr(), "-domain", "cli.test", "-long", "https://example.com/x", "-key", "fromcli"); code != 1 {
+		t.Fatalf("taken key: exit code %d, want 1", code)
+	}
+
+	// 与服务端相同的生成方式、屏蔽词与去重设置
+	counterMode := []string{"-conn", mr.Addr(), "-domain", "cli.test", "-keygen", "counter", "-alphabet", "ab", "-long"}
+	setFlag(t, &keyMode, keyMode)
+	setFlag(t, &blockedTokens, nil)
+	setFlag(t, &allowedHosts, nil)
+	setFlag(t, &noDedup, false)
+	if _, out := runCommand(t, runCreateCommand, append(counterMode, "https://example.com/c1")...); out != "https://cli.test/aaaaab\n" {
+		t.Fatalf("counter mode: output %q", out)
+	}
+	blocklist := filepath.Join(t.TempDir(), "blocklist.txt")
+	if err := os.WriteFile(blocklist, []byte("aaaaba\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, out := runCommand(t, runCreateCommand, append([]string{"-blocklist", blocklist}, append(counterMode, "https://example.com/c2")...)...); out != "https://cli.test/aaaabb\n" {
+		t.Fatalf("counter mode with a blocklist: output %q", out)
+	}
+	_, first := runCommand(t, runCreateCommand, "-conn", mr.Addr(), "-domain", "cli.test", "-long", "https://example.com/dup", "-no-dedup")
+	_, second := runCommand(t, runCreateCommand, "-conn", mr.Addr(), "-domain", "cli.test", "-long", "https://example.com/dup", "-no-dedup")
+	if first == second {
+		t.Fatalf("-no-dedup reused %q", first)
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxCsvImportErrors is the maximum number of row errors reported by the CSV import.
+const maxCsvImportErrors = 100
+
+// csvShortColumns and csvLongColumns are the header names of the short and long URL columns
+// in the CSV exports of common shorteners, compared case-insensitively.
+var (
+	csvShortColumns = []string{"short_url", "shorturl", "short url", "short_link", "short link", "link"}
+	csvLongColumns  = []string{"long_url", "longurl", "long url", "original_url", "destination", "url"}
+)
+
+// RenamedLink is an imported link that could not keep the key of its original short URL.
+type RenamedLink struct {
+	Line     int
+	ShortKey string
+	ShortUrl string
+}
+
+// CsvImportResponse is the response structure of the CSV import endpoint.
+type CsvImportResponse struct {
+	Code     int
+	Message  string
+	Imported int
+	Failed   int
+	Renamed  []RenamedLink
+	Errors   []string
+}
+
+// csvColumns locates the short and long URL columns of a CSV header row.
+// A row without recognized column names is data in short_url,long_url order.
+func csvColumns(header []string) (shortColumn int, longColumn int, isHeader bool) {
+	shortColumn, longColumn = -1, -1
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		for _, candidate := range csvShortColumns {
+			if name == candidate && shortColumn == -1 {
+				shortColumn = i
+			}
+		}
+		for _, candidate := range csvLongColumns {
+			if name == candidate && longColumn == -1 {
+				longColumn = i
+			}
+		}
+	}
+	if shortColumn == -1 || longColumn == -1 {
+		return 0, 1, false
+	}
+
+	return shortColumn, longColumn, true
+}
+
+// keyFromShortUrl extracts the short key, the last path segment, of another shortener's short URL.
+// A bare key is returned as is.
+func keyFromShortUrl(shortUrl string) string {
+	shortUrl = strings.TrimSpace(shortUrl)
+	if !strings.Contains(shortUrl, "/") {
+		return shortUrl
+	}
+	if !strings.Contains(shortUrl, "://") {
+		shortUrl = "https://" + shortUrl
+	}
+
+	u, err := url.Parse(shortUrl)
+	if err != nil {
+		return ""
+	}
+	path := strings.Trim(u.Path, "/")
+
+	return path[strings.LastIndex(path, "/")+1:]
+}
+
+// 从其他短链接服务导出的 CSV 导入短链接，尽量保留原短链接 key
+func importCsvHandler(context *gin.Context) {
+	res := &CsvImportResponse{Code: 1, Renamed: []RenamedLink{}, Errors: []string{}}
+
+	if err := writesSuspended(); err != nil {
+		res.Code = 0
+		res.Message = err.Error()
+		context.JSON(http.StatusServiceUnavailable, *res)
+		return
+	}
+	_ = http.NewResponseController(context.Writer).SetReadDeadline(time.Time{})
+
+	fail := func(line int, reason string) {
+		res.Failed++
+		if len(res.Errors) < maxCsvImportErrors {
+			res.Errors = append(res.Errors, fmt.Sprintf("第%d行: %s", line, reason))
+		}
+	}
+
+	reader := csv.NewReader(context.Request.Body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	shortColumn, longColumn := 0, 1
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			fail(parseErr.StartLine, "CSV 格式错误")
+			continue
+		} else if err != nil {
+			res.Code = 0
+			res.Message = "导入数据读取失败: " + err.Error()
+			context.JSON(http.StatusBadRequest, *res)
+			return
+		}
+		line, _ := reader.FieldPos(0)
+
+		if first {
+			var isHeader bool
+			if shortColumn, longColumn, isHeader = csvColumns(record); isHeader {
+				continue
+			}
+		}
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		if shortColumn >= len(record) || longColumn >= len(record) {
+			fail(line, "缺少 short_url 或 long_url 列")
+			continue
+		}
+
+		longUrl := strings.TrimSpace(record[longColumn])
+		if longUrl == "" {
+			fail(line, "longUrl为空")
+			continue
+		}
+		if err := validateLongUrl(longUrl); err != nil {
+			fail(line, err.Error())
+			continue
+		}
+
+		// 原 key 不合法或已被占用时改为生成新 key
+		original := keyFromShortUrl(record[shortColumn])
+		shortKey, err := importCsvLink(original, longUrl)
+		if err != nil {
+			if errorStatus(err) == http.StatusInternalServerError {
+				res.Code = 0
+				res.Message = err.Error()
+				context.JSON(http.StatusInternalServerError, *res)
+				return
+			}
+			fail(line, err.Error())
+			continue
+		}
+		linkCache.remove(shortKey)
+		res.Imported++
+		if shortKey != original {
+			res.Renamed = append(res.Renamed, RenamedLink{Line: line, ShortKey: original, ShortUrl: buildShortUrl(context, shortKey)})
+		}
+	}
+
+	context.JSON(http.StatusOK, *res)
+}
+
+// importCsvLink stores an imported link under its original key, or under a generated key
+// when the original is missing, invalid or already points elsewhere.
+func importCsvLink(original string, longUrl string) (string, error) {
+	req := linkRequest{longUrl: longUrl, shortKey: original, shortUrlLen: currentShortUrlLen(), ttl: linkTTL}
+	// 导入的短链接已带校验位，去掉后由 createLink 重新追加，保持原短链接不变
+	if original != "" && validChecksum(normalizeShortKey(original)) {
+		req.shortKey = withoutChecksum(normalizeShortKey(original))
+	}
+	if req.shortKey != "" && validateShortKey(normalizeShortKey(req.shortKey)) == nil && len(original) <= maxShortUrlLen {
+		shortKey, _, err := createLink(req)
+		if !errors.Is(err, errKeyTaken) {
+			return shortKey, err
+		}
+	}
+
+	req.shortKey = ""
+	shortKey, _, err := createLink(req)
+
+	return shortKey, err
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// importCsv posts a CSV export to the admin import endpoint.
+func importCsv(t testing.TB, router http.Handler, body string) (int, CsvImportResponse) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/admin/import/csv", strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/csv")
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	w := serveRequest(router, req)
+	var res CsvImportResponse
+	decode(t, w, &res)
+	return w.Code, res
+}
+
+func TestImportCsv(t *testing.T) {
+	router, mr := newTestRouter(t)
+	setFlag(t, &adminToken, "secret-admin")
+	mustShorten(t, router, "https://example.com/existing", map[string][]string{"shortKey": {"taken"}})
+
+	// bit.ly 导出格式，带 BOM 与额外的列
+	code, res := importCsv(t, router, "\ufeffCreated,Short URL,Long URL\n"+
+		"2023-01-01,https://bit.ly/abc123,https://example.com/a\n"+
+		"2023-01-02,bit.ly/taken,https://example.com/b\n"+
+		"2023-01-03,https://bit.ly/bad!key,https://example.com/c\n"+
+		"2023-01-04,https://bit.ly/nourl,\n"+
+		"2023-01-05,https://bit.ly/js,javascript:alert(1)\n")
+	if code != http.StatusOK || res.Imported != 3 || res.Failed != 2 || len(res.Errors) != 2 {
+		t.Fatalf("status %d, %+v", code, res)
+	}
+	if got, _ := mr.Get("abc123"); got != "https://example.com/a" {
+		t.Fatalf("original key imported as %q", got)
+	}
+	if len(res.Renamed) != 2 || res.Renamed[0].ShortKey != "taken" || res.Renamed[0].Line != 3 {
+		t.Fatalf("renamed %+v", res.Renamed)
+	}
+	renamed := strings.TrimPrefix(res.Renamed[0].ShortUrl, "https://s.test/")
+	if got, _ := mr.Get(renamed); got != "https://example.com/b" {
+		t.Fatalf("renamed link points at %q", got)
+	}
+	if got, _ := mr.Get("taken"); got != "https://example.com/existing" {
+		t.Fatal("import overwrote an existing key")
+	}
+
+	// 无表头时按 short_url,long_url 顺序解析
+	if code, res := importCsv(t, router, "xyz789,https://example.com/headerless\n"); code != http.StatusOK || res.Imported != 1 {
+		t.Fatalf("headerless: status %d, %+v", code, res)
+	}
+	if got, _ := mr.Get("xyz789"); got != "https://example.com/headerless" {
+		t.Fatalf("headerless link points at %q", got)
+	}
+}
+
+func TestKeyFromShortUrl(t *testing.T) {
+	for raw, want := range map[string]string{
+		"https://bit.ly/abc":     "abc",
+		"bit.ly/abc/":            "abc",
+		"https://t.co/x/y?utm=1": "y",
+		"abc":                    "abc",
+		" https://bit.ly/abc ":   "abc",
+	} {
+		if got := keyFromShortUrl(raw); got != want {
+			t.Errorf("keyFromShortUrl(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
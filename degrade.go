@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// degradedProbeInterval is how often an unavailable Redis is probed for recovery.
+const degradedProbeInterval = 2 * time.Second
+
+// maxQueuedLinks is the maximum number of links created while degraded and waiting to be written to Redis.
+const maxQueuedLinks = 1000
+
+// errQueueFull is returned when too many links were created while Redis is unavailable.
+var errQueueFull = errors.New("存储服务不可用，待写入队列已满")
+
+// degradeEnabled keeps serving cached redirects and queueing new links while Redis is unavailable.
+var degradeEnabled bool
+
+// storageDegraded is set while Redis is unreachable in degraded mode.
+var storageDegraded atomic.Bool
+
+// degradedGauge reports whether the service degraded to the in-process cache.
+var degradedGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "myurls_storage_degraded",
+	Help: "Whether Redis is unreachable and redirects are served from the in-process cache.",
+})
+
+// queuedLinkCollisions counts queued links whose key was taken before they could be written to Redis.
+var queuedLinkCollisions = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "myurls_queued_link_collisions_total",
+	Help: "Links created while degraded that were not written because their key was taken in the meantime.",
+})
+
+// queuedLink is a link created while degraded, written to Redis once it recovers.
+type queuedLink struct {
+	shortKey  string
+	longUrl   string
+	ttl       int
+	createdAt int64
+}
+
+// linkQueue is the bounded write-ahead buffer of links created while degraded.
+var linkQueue struct {
+	sync.Mutex
+	links []queuedLink
+}
+
+func init() {
+	prometheus.MustRegister(degradedGauge, queuedLinkCollisions)
+}
+
+// degradedRead reports whether a storage error may be answered from the in-process cache.
+func degradedRead(err error) bool {
+	return degradeEnabled && errors.Is(err, errStorageUnavailable)
+}
+
+// noteUnavailable switches to degraded mode if err means Redis is unreachable.
+// Error replies come from a reachable server and leave the mode unchanged.
+func noteUnavailable(err error) {
+	var redisErr redis.Error
+	if !degradeEnabled || errors.As(err, &redisErr) {
+		return
+	}
+
+	if storageDegraded.CompareAndSwap(false, true) {
+		log.Println("Redis unavailable, serving cached redirects and queueing new links:", err)
+		degradedGauge.Set(1)
+		go watchDegradedRecovery()
+	}
+}
+
+// queueLink buffers a link created while degraded and serves it from the cache until it is written.
+func queueLink(shortKey string, longUrl string, ttl int) error {
+	linkQueue.Lock()
+	defer linkQueue.Unlock()
+
+	if len(linkQueue.links) >= maxQueuedLinks {
+		return errQueueFull
+	}
+	linkQueue.links = append(linkQueue.links, queuedLink{shortKey: shortKey, longUrl: longUrl, ttl: ttl, createdAt: time.Now().Unix()})
+	linkCache.add(shortKey, resolvedLink{longUrl: longUrl, meta: map[string]string{}}, time.Duration(ttl)*time.Second)
+
+	return nil
+}
+
+// watchDegradedRecovery probes Redis until it answers, then writes the queued links and leaves degraded mode.
+func watchDegradedRecovery() {
+	ticker := time.NewTicker(degradedProbeInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		redisClient := redisPool.Get()
+		_, err := redisClient.Do("ping")
+		if err == nil {
+			err = flushQueuedLinks(redisClient)
+		}
+		redisClient.Close()
+
+		if err == nil {
+			storageDegraded.Store(false)
+			degradedGauge.Set(0)
+			log.Println("Redis available again, leaving degraded mode")
+			return
+		}
+	}
+}
+
+// flushQueuedLinks writes the queued links to Redis, keeping the unwritten ones on failure.
+// A key taken in the meantime can't be written; the collision is logged and counted.
+func flushQueuedLinks(conn redis.Conn) error {
+	linkQueue.Lock()
+	defer linkQueue.Unlock()
+
+	for len(linkQueue.links) > 0 {
+		link := linkQueue.links[0]
+		reply, err := redis.String(conn.Do("set", redisKey(link.shortKey), link.longUrl, "nx", "ex", link.ttl))
+		if err != nil && err != redis.ErrNil {
+			return err
+		}
+		if reply == "OK" {
+			if err := syncLinkExpiry(conn, link.shortKey); err != nil {
+				return err
+			}
+			if _, err := conn.Do("hsetnx", redisKey(defaultMetaPrefix+link.shortKey), "createdAt", link.createdAt); err != nil {
+				return err
+			}
+			if err := signLink(conn, link.shortKey, link.longUrl); err != nil {
+				return err
+			}
+		} else {
+			log.Printf("Queued link %s -> %s not written: key was taken while Redis was unavailable", link.shortKey, link.longUrl)
+			queuedLinkCollisions.Inc()
+			linkCache.remove(link.shortKey)
+		}
+		linkQueue.links = linkQueue.links[1:]
+	}
+	linkQueue.links = nil
+
+	return nil
+}
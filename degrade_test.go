@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestDegradedMode(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits for the recovery probe")
+	}
+	router, mr := newTestRouter(t)
+	setFlag(t, &degradeEnabled, true)
+	setFlag(t, &linkCache, newLRUCache(16, time.Millisecond))
+	t.Cleanup(func() {
+		linkQueue.Lock()
+		linkQueue.links = nil
+		linkQueue.Unlock()
+	})
+
+	key := mustShorten(t, router, "https://example.com/cached", nil)
+	serve(router, http.MethodGet, "/"+key, nil, nil)
+	time.Sleep(2 * time.Millisecond)
+
+	mr.Close()
+	if w := serve(router, http.MethodGet, "/"+key, nil, nil); w.Code != http.StatusMovedPermanently {
+		t.Fatalf("cached redirect while Redis is down: status %d, %s", w.Code, w.Body)
+	}
+	if !storageDegraded.Load() {
+		t.Fatal("not in degraded mode after Redis went down")
+	}
+
+	queued := mustShorten(t, router, "https://example.com/queued", nil)
+	if w := serve(router, http.MethodGet, "/"+queued, nil, nil); w.Code != http.StatusMovedPermanently {
+		t.Fatalf("queued link while Redis is down: status %d", w.Code)
+	}
+	if code, _ := shorten(t, router, "https://example.com/custom", url.Values{"shortKey": {"custom"}}); code != http.StatusServiceUnavailable {
+		t.Fatalf("custom key while degraded: status %d, want 503", code)
+	}
+
+	if err := mr.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(3 * degradedProbeInterval)
+	for storageDegraded.Load() && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if storageDegraded.Load() {
+		t.Fatal("still degraded after Redis came back")
+	}
+	if got, _ := mr.Get(queued); got != "https://example.com/queued" {
+		t.Fatalf("queued link flushed as %q", got)
+	}
+	if mr.TTL(queued) <= 0 {
+		t.Fatal("queued link flushed without ttl")
+	}
+}
+
+func TestFlushQueuedLinks(t *testing.T) {
+	_, mr := newTestRouter(t)
+	setFlag(t, &linkCache, newLRUCache(16, time.Minute))
+	t.Cleanup(func() {
+		linkQueue.Lock()
+		linkQueue.links = nil
+		linkQueue.Unlock()
+	})
+
+	if err := queueLink("queued", "https://example.com/queued", 3600); err != nil {
+		t.Fatal(err)
+	}
+	if err := queueLink("taken", "https://example.com/lost", 3600); err != nil {
+		t.Fatal(err)
+	}
+	mr.Set("taken", "https://example.com/other")
+	createdAt := time.Now().Add(-time.Hour).Unix()
+	linkQueue.links[0].createdAt = createdAt
+	collisions := testutil.ToFloat64(queuedLinkCollisions)
+
+	redisClient := redisPool.Get()
+	defer redisClient.Close()
+	if err := flushQueuedLinks(redisClient); err != nil {
+		t.Fatal(err)
+	}
+
+	// 创建时间取入队时刻，而非写入 Redis 的时刻
+	if got := mr.HGet(defaultMetaPrefix+"queued", "createdAt"); got != strconv.FormatInt(createdAt, 10) {
+		t.Fatalf("createdAt %q, want %d", got, createdAt)
+	}
+	if got, _ := mr.Get("taken"); got != "https://example.com/other" {
+		t.Fatalf("taken key overwritten with %q", got)
+	}
+	if got := testutil.ToFloat64(queuedLinkCollisions) - collisions; got != 1 {
+		t.Fatalf("counted %v collisions, want 1", got)
+	}
+	if _, ok := linkCache.get("taken"); ok {
+		t.Fatal("colliding queued link still served from the cache")
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// subdomainMode carries short keys as the first DNS label, key.domain instead of domain/key.
+var subdomainMode bool
+
+// domains are the additional domains served by this instance, set by -domains.
+var domains []string
+
+// normalizeDomain cleans a -domain value into a bare host[:port], stripping any
+// scheme and trailing slashes, e.g. "https://x.com/" becomes "x.com".
+func normalizeDomain(raw string) (string, error) {
+	d := strings.TrimSpace(raw)
+	if i := strings.Index(d, "://"); i >= 0 {
+		d = d[i+3:]
+	}
+	d = strings.TrimRight(d, "/")
+	if d == "" {
+		return "", errors.New("域名不能为空")
+	}
+	if strings.Contains(d, "/") {
+		return "", errors.New("域名不能包含路径，子路径部署请使用 -basepath: " + raw)
+	}
+
+	host := d
+	if h, port, err := net.SplitHostPort(d); err == nil {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return "", errors.New("域名端口无效: " + raw)
+		}
+		host = h
+	} else if strings.Count(d, ":") == 1 {
+		return "", errors.New("域名端口无效: " + raw)
+	}
+	if ip := net.ParseIP(strings.Trim(host, "[]")); ip != nil {
+		// 裸 IPv6 地址需加方括号才能拼接为 URL
+		if ip.To4() == nil && !strings.HasPrefix(d, "[") {
+			return "[" + d + "]", nil
+		}
+		return d, nil
+	}
+	if !validHostname(host) {
+		return "", errors.New("域名格式错误: " + raw)
+	}
+
+	return strings.ToLower(d), nil
+}
+
+// validHostname reports whether host is a plausible DNS name made of letters, digits, hyphens and dots.
+func validHostname(host string) bool {
+	if host == "" || len(host) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(host, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for i := 0; i < len(label); i++ {
+			c := label[i]
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// domainAllowed reports whether d is the -domain or one of the -domains.
+func domainAllowed(d string) bool {
+	if strings.EqualFold(d, domain) {
+		return true
+	}
+	for _, allowed := range domains {
+		if strings.EqualFold(d, allowed) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hostname strips the port and IPv6 brackets from a request Host or a configured domain.
+func hostname(d string) string {
+	if h, _, err := net.SplitHostPort(d); err == nil {
+		d = h
+	}
+
+	return strings.Trim(d, "[]")
+}
+
+// hostnameAllowed reports whether host names the -domain or one of the -domains.
+// Ports are stripped from both sides, so a Host with or without port matches a domain with or without port.
+func hostnameAllowed(host string) bool {
+	host = hostname(host)
+	if strings.EqualFold(host, hostname(domain)) {
+		return true
+	}
+	for _, allowed := range domains {
+		if strings.EqualFold(host, hostname(allowed)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// subdomainKey returns the short key carried as the first DNS label of a request Host
+// on a configured domain in -subdomain-mode, empty otherwise.
+func subdomainKey(host string) string {
+	if !subdomainMode {
+		return ""
+	}
+
+	label, rest, ok := strings.Cut(hostname(host), ".")
+	if !ok || label == "" || !hostnameAllowed(rest) {
+		return ""
+	}
+
+	return label
+}
+
+// hostAllowed reports whether the request Host is a configured domain.
+// Any host is accepted when -domains is not set.
+func hostAllowed(host string) bool {
+	return len(domains) == 0 || hostnameAllowed(host) || subdomainKey(host) != ""
+}
+
+// requestDomain returns the domain requested via the domain parameter,
+// falling back to the default domain when it is missing or not allowed.
+func requestDomain(context *gin.Context) string {
+	d := context.PostForm("domain")
+	if d == "" {
+		d = context.Query("domain")
+	}
+	if d == "" || !domainAllowed(d) {
+		return domain
+	}
+
+	return d
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeDomain(t *testing.T) {
+	for _, tc := range []struct {
+		raw  string
+		want string
+	}{
+		{"https://x.com/", "x.com"},
+		{"x.com", "x.com"},
+		{"X.Com", "x.com"},
+		{"x.com:8080", "x.com:8080"},
+		{"http://127.0.0.1:8002", "127.0.0.1:8002"},
+		{"::1", "[::1]"},
+		{"[::1]:8080", "[::1]:8080"},
+	} {
+		if got, err := normalizeDomain(tc.raw); err != nil || got != tc.want {
+			t.Errorf("normalizeDomain(%q) = %q, %v, want %q", tc.raw, got, err, tc.want)
+		}
+	}
+	for _, raw := range []string{"", "   ", "https://", "x.com/u", "x.com:0", "x.com:http", "bad_host.com", "-x.com", "x..com"} {
+		if got, err := normalizeDomain(raw); err == nil {
+			t.Errorf("normalizeDomain(%q) = %q, want an error", raw, got)
+		}
+	}
+}
+
+func TestMultipleDomains(t *testing.T) {
+	router, _ := newTestRouter(t)
+	setFlag(t, &domains, []string{"s.test", "brand.test"})
+
+	code, res := shorten(t, router, "https://example.com/brand", url.Values{"domain": {"brand.test"}})
+	if code != http.StatusOK || !strings.HasPrefix(res.ShortUrl, "https://brand.test/") {
+		t.Fatalf("allowed domain: status %d, %+v", code, res)
+	}
+	if code, _ := shorten(t, router, "https://example.com/brand", url.Values{"domain": {"evil.test"}}); code != http.StatusBadRequest {
+		t.Fatalf("unknown domain: status %d, want 400", code)
+	}
+
+	key := strings.TrimPrefix(res.ShortUrl, "https://brand.test/")
+	req := httptest.NewRequest(http.MethodGet, "/"+key, nil)
+	req.Host = "brand.test"
+	if w := serveRequest(router, req); w.Code != http.StatusMovedPermanently {
+		t.Fatalf("redirect on configured domain: status %d", w.Code)
+	}
+	req.Host = "evil.test"
+	if w := serveRequest(router, req); w.Code != http.StatusNotFound {
+		t.Fatalf("redirect on unknown domain: status %d, want 404", w.Code)
+	}
+}
+
+func TestSubdomainMode(t *testing.T) {
+	router, _ := newTestRouter(t)
+	setFlag(t, &subdomainMode, true)
+	setFlag(t, &caseInsensitive, true)
+	setFlag(t, &keyAlphabet, letterBytes[:36])
+
+	code, res := shorten(t, router, "https://example.com/sub", url.Values{"shortKey": {"promo"}})
+	if code != http.StatusOK || res.ShortUrl != "https://promo.s.test" {
+		t.Fatalf("status %d, %+v", code, res)
+	}
+
+	for _, tc := range []struct {
+		host string
+		want string
+	}{
+		{"promo.s.test", "promo"},
+		{"PROMO.s.test", "PROMO"},
+		{"s.test", ""},
+		{"promo.other.test", ""},
+		{"a.b.s.test", ""},
+	} {
+		if got := subdomainKey(tc.host); got != tc.want {
+			t.Errorf("subdomainKey(%q) = %q, want %q", tc.host, got, tc.want)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Host = "promo.s.test"
+	if w := serveRequest(router, req); w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "https://example.com/sub" {
+		t.Fatalf("subdomain redirect: status %d, Location %q", w.Code, w.Header().Get("Location"))
+	}
+	req.Host = "promo.s.test"
+	req.URL.Path = "/short"
+	if w := serveRequest(router, req); w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("GET /short on a subdomain: status %d, want 405", w.Code)
+	}
+	req.URL.Path = "/"
+	req.Host = "s.test"
+	if w := serveRequest(router, req); w.Code != http.StatusOK {
+		t.Fatalf("index on the bare domain: status %d", w.Code)
+	}
+}
+
+func TestDomainPorts(t *testing.T) {
+	newTestRouter(t)
+	setFlag(t, &subdomainMode, true)
+
+	// Host 与配置的域名以相同方式去掉端口后比较
+	for _, configured := range []string{"s.test", "s.test:8080"} {
+		setFlag(t, &domain, configured)
+		setFlag(t, &domains, []string{configured, "brand.test"})
+		for _, tc := range []struct {
+			host    string
+			key     string
+			allowed bool
+		}{
+			{"promo.s.test", "promo", true},
+			{"promo.s.test:8080", "promo", true},
+			{"s.test:8080", "", true},
+			{"s.test", "", true},
+			{"promo.brand.test:8443", "promo", true},
+			{"promo.evil.test:8080", "", false},
+			{"evil.test", "", false},
+		} {
+			if got := subdomainKey(tc.host); got != tc.key {
+				t.Errorf("domain %s: subdomainKey(%q) = %q, want %q", configured, tc.host, got, tc.key)
+			}
+			if got := hostAllowed(tc.host); got != tc.allowed {
+				t.Errorf("domain %s: hostAllowed(%q) = %v, want %v", configured, tc.host, got, tc.allowed)
+			}
+		}
+	}
+}
+
+func TestBasePath(t *testing.T) {
+	setFlag(t, &basePath, "")
+	for raw, want := range map[string]string{"": "", "/": "", "u": "/u", "/u/": "/u", " /a/b/ ": "/a/b"} {
+		if got := normalizeBasePath(raw); got != want {
+			t.Errorf("normalizeBasePath(%q) = %q, want %q", raw, got, want)
+		}
+	}
+
+	// 路由在创建时挂载到 basePath 下
+	setFlag(t, &basePath, "/u")
+	router, _ := newTestRouter(t)
+
+	form := updateForm("https://example.com/based")
+	w := serve(router, http.MethodPost, "/u/short", form, nil)
+	var res Response
+	decode(t, w, &res)
+	if w.Code != http.StatusOK || !strings.HasPrefix(res.ShortUrl, "https://s.test/u/") {
+		t.Fatalf("create under the base path: status %d, %+v", w.Code, res)
+	}
+	key := strings.TrimPrefix(res.ShortUrl, "https://s.test/u/")
+	if w := serve(router, http.MethodGet, "/u/"+key, nil, nil); w.Code != http.StatusMovedPermanently {
+		t.Fatalf("redirect under the base path: status %d", w.Code)
+	}
+	if w := serve(router, http.MethodGet, "/"+key, nil, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("redirect outside the base path: status %d, want 404", w.Code)
+	}
+	if w := serve(router, http.MethodGet, "/metrics", nil, nil); w.Code != http.StatusOK {
+		t.Fatalf("/metrics with a base path: status %d", w.Code)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gomodule/redigo/redis"
+)
+
+// defaultExpiryKey is the Redis sorted set indexing short keys by their expiry time.
+const defaultExpiryKey = "myurls:expiry"
+
+// defaultTombPrefix is the default prefix for Redis tombstones outliving expired short keys.
+const defaultTombPrefix = "myurls:tomb:"
+
+// tombRetention is how long a tombstone outlives its short key.
+const tombRetention = 365 * secondsPerDay * 1000
+
+// defaultExpiringWithin is the default look-ahead window in hours for expiring links.
+const defaultExpiringWithin = 24
+
+// fallbackUrl is where unknown short keys are redirected to, with the key as query parameter; empty for 404.
+var fallbackUrl string
+
+// notfoundUrl is where never created short keys are redirected to, taking precedence over fallbackUrl.
+var notfoundUrl string
+
+// expiredUrl is where expired short keys are redirected to, taking precedence over fallbackUrl.
+var expiredUrl string
+
+// fallbackExpired also redirects expired short keys to fallbackUrl instead of answering 410.
+var fallbackExpired bool
+
+// ExpiringLink is a short key whose ttl is below the requested threshold.
+type ExpiringLink struct {
+	ShortKey  string
+	LongUrl   string
+	TTL       int
+	CreatedAt int64
+}
+
+// ExpiringResponse is the response structure of the expiring links endpoint.
+type ExpiringResponse struct {
+	Code    int
+	Message string
+	Links   []ExpiringLink
+}
+
+// syncLinkExpiry propagates the remaining ttl of a short key to its metadata hash and the expiry index.
+func syncLinkExpiry(conn redis.Conn, shortKey string) error {
+	ttl, err := redis.Int64(conn.Do("pttl", redisKey(shortKey)))
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case ttl > 0:
+		expireAt := time.Now().Add(time.Duration(ttl) * time.Millisecond).Unix()
+		if _, err = conn.Do("pexpire", redisKey(defaultMetaPrefix+shortKey), ttl); err != nil {
+			return err
+		}
+		if _, err = conn.Do("pexpire", redisKey(defaultHitsPrefix+shortKey), ttl); err != nil {
+			return err
+		}
+		if _, err = conn.Do("pexpire", redisKey(defaultRefPrefix+shortKey), ttl); err != nil {
+			return err
+		}
+		if _, err = conn.Do("set", redisKey(defaultTombPrefix+shortKey), 1, "px", ttl+tombRetention); err != nil {
+			return err
+		}
+		_, err = conn.Do("zadd", redisKey(defaultExpiryKey), expireAt, shortKey)
+	case ttl == -1:
+		if _, err = conn.Do("persist", redisKey(defaultMetaPrefix+shortKey)); err != nil {
+			return err
+		}
+		if _, err = conn.Do("persist", redisKey(defaultHitsPrefix+shortKey)); err != nil {
+			return err
+		}
+		if _, err = conn.Do("persist", redisKey(defaultRefPrefix+shortKey)); err != nil {
+			return err
+		}
+		if _, err = conn.Do("set", redisKey(defaultTombPrefix+shortKey), 1); err != nil {
+			return err
+		}
+		_, err = conn.Do("zrem", redisKey(defaultExpiryKey), shortKey)
+	default:
+		_, err = conn.Do("zrem", redisKey(defaultExpiryKey), shortKey)
+	}
+
+	return err
+}
+
+// linkExpired reports whether a missing short key existed before, i.e. it expired rather than never being created.
+func linkExpired(shortKey string) bool {
+	redisClient := redisPool.Get()
+	defer redisClient.Close()
+
+	exists, _ := redis.Bool(redisClient.Do("exists", redisKey(defaultTombPrefix+shortKey)))
+
+	return exists
+}
+
+// 短链接不存在时，区分已过期 (410) 与从未创建 (404)，配置跳转地址时改为跳转
+func respondMissing(context *gin.Context, shortKey string) {
+	expired := linkExpired(shortKey)
+	if expired && expiredUrl != "" {
+		context.Redirect(http.StatusFound, expiredUrl)
+		return
+	}
+	if !expired && notfoundUrl != "" {
+		context.Redirect(http.StatusFound, notfoundUrl)
+		return
+	}
+	if fallbackUrl != "" && (!expired || fallbackExpired) {
+		context.Redirect(http.StatusFound, fallbackTarget(shortKey))
+		return
+	}
+	if expired {
+		respondNotFound(context, http.StatusGone, "短链接已过期")
+		return
+	}
+
+	respondNotFound(context, http.StatusNotFound, "短链接不存在")
+}
+
+// fallbackTarget returns fallbackUrl with the missing short key added as the key query parameter.
+func fallbackTarget(shortKey string) string {
+	u, err := url.Parse(fallbackUrl)
+	if err != nil {
+		return fallbackUrl
+	}
+	query := u.Query()
+	query.Set("key", shortKey)
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+// 浏览器访问时渲染 notfound.html，API 客户端按 Accept 返回 JSON 或纯文本
+func respondNotFound(context *gin.Context, httpStatus int, message string) {
+	switch context.NegotiateFormat(gin.MIMEPlain, gin.MIMEHTML, gin.MIMEJSON) {
+	case gin.MIMEHTML:
+		context.HTML(httpStatus, "notfound.html", gin.H{
+			"title":   "MyUrls",
+			"banner":  currentBanner(),
+			"status":  httpStatus,
+			"message": message,
+			"homeUrl": basePath + "/",
+		})
+	case gin.MIMEJSON:
+		context.JSON(httpStatus, Response{Code: 0, Message: message, RequestID: requestID(context)})
+	default:
+		context.String(httpStatus, message)
+	}
+}
+
+// 即将过期的短链接列表
+func expiringHandler(context *gin.Context) {
+	res := &ExpiringResponse{Code: 1, Links: []ExpiringLink{}}
+
+	within := defaultExpiringWithin
+	if withinStr := context.Query("within"); withinStr != "" {
+		_within, err := strconv.Atoi(withinStr)
+		if err != nil || _within < 1 {
+			res.Code = 0
+			res.Message = "within必须为正整数"
+			context.JSON(http.StatusBadRequest, *res)
+			return
+		}
+		within = _within
+	}
+
+	redisClient := redisPool.Get()
+	defer redisClient.Close()
+
+	// 清理已过期的索引项
+	now := time.Now()
+	_, _ = redisClient.Do("zremrangebyscore", redisKey(defaultExpiryKey), "-inf", now.Unix())
+
+	keys, err := redis.Strings(redisClient.Do("zrangebyscore", redisKey(defaultExpiryKey), now.Unix(), now.Add(time.Duration(within)*time.Hour).Unix()))
+	if err != nil {
+		res.Code = 0
+		res.Message = "存储服务不可用"
+		context.JSON(http.StatusInternalServerError, *res)
+		return
+	}
+
+	for _, shortKey := range keys {
+		longUrl, _ := redis.String(redisClient.Do("get", redisKey(shortKey)))
+		ttl, _ := redis.Int(redisClient.Do("ttl", redisKey(shortKey)))
+		if longUrl == "" || ttl < 0 {
+			_, _ = redisClient.Do("zrem", redisKey(defaultExpiryKey), shortKey)
+			continue
+		}
+		if ttl > within*3600 {
+			continue
+		}
+
+		meta, _ := redis.StringMap(redisClient.Do("hgetall", redisKey(defaultMetaPrefix+shortKey)))
+		if meta["unlisted"] == "1" {
+			continue
+		}
+		res.Links = append(res.Links, ExpiringLink{ShortKey: shortKey, LongUrl: longUrl, TTL: ttl, CreatedAt: linkCreatedAt(meta)})
+	}
+
+	context.JSON(http.StatusOK, *res)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpiredLinkGone(t *testing.T) {
+	router, mr := newTestRouter(t)
+
+	key := mustShorten(t, router, "https://example.com/expiring", url.Values{"ttl": {"1h"}})
+	mr.FastForward(time.Hour + time.Second)
+
+	if w := serve(router, http.MethodGet, "/"+key, nil, nil); w.Code != http.StatusGone {
+		t.Fatalf("expired link: status %d, want 410", w.Code)
+	}
+	if w := serve(router, http.MethodGet, "/never1", nil, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("never created: status %d, want 404", w.Code)
+	}
+}
+
+func TestNotFoundNegotiation(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	w := serve(router, http.MethodGet, "/never1", nil, http.Header{"Accept": {"text/html"}})
+	if w.Code != http.StatusNotFound || !strings.Contains(w.Header().Get("Content-Type"), "text/html") || !strings.Contains(w.Body.String(), "短链接不存在") {
+		t.Fatalf("html: status %d, %s", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	w = serve(router, http.MethodGet, "/never1", nil, http.Header{"Accept": {"application/json"}})
+	var res Response
+	decode(t, w, &res)
+	if w.Code != http.StatusNotFound || res.Message != "短链接不存在" {
+		t.Fatalf("json: status %d, %+v", w.Code, res)
+	}
+
+	w = serve(router, http.MethodGet, "/never1", nil, nil)
+	if w.Code != http.StatusNotFound || w.Body.String() != "短链接不存在" {
+		t.Fatalf("plain: status %d, %q", w.Code, w.Body)
+	}
+}
+
+func TestMissingLinkRedirects(t *testing.T) {
+	router, mr := newTestRouter(t)
+
+	expired := mustShorten(t, router, "https://example.com/old", url.Values{"ttl": {"1h"}})
+	mr.FastForward(time.Hour + time.Second)
+
+	setFlag(t, &fallbackUrl, "https://fallback.test/search?q=1")
+	serveLocation := func(key string) string {
+		w := serve(router, http.MethodGet, "/"+key, nil, nil)
+		if w.Code != http.StatusFound && w.Code != http.StatusGone {
+			t.Fatalf("%s: status %d", key, w.Code)
+		}
+		return w.Header().Get("Location")
+	}
+
+	if got := serveLocation("never1"); got != "https://fallback.test/search?key=never1&q=1" {
+		t.Fatalf("fallback: %q", got)
+	}
+	if got := serveLocation(expired); got != "" {
+		t.Fatalf("expired link redirected to %q without -fallback-expired", got)
+	}
+	setFlag(t, &fallbackExpired, true)
+	if got := serveLocation(expired); !strings.HasPrefix(got, "https://fallback.test/") {
+		t.Fatalf("-fallback-expired: %q", got)
+	}
+
+	setFlag(t, &notfoundUrl, "https://notfound.test/")
+	setFlag(t, &expiredUrl, "https://expired.test/")
+	if got := serveLocation("never1"); got != notfoundUrl {
+		t.Fatalf("-notfound-url: %q", got)
+	}
+	if got := serveLocation(expired); got != expiredUrl {
+		t.Fatalf("-expired-url: %q", got)
+	}
+}
+
+func TestExpiringLinks(t *testing.T) {
+	router, _ := newTestRouter(t)
+	setFlag(t, &adminToken, "secret-admin")
+
+	soon := mustShorten(t, router, "https://example.com/soon", url.Values{"ttl": {"2h"}})
+	mustShorten(t, router, "https://example.com/later", url.Values{"ttl": {"3d"}})
+	mustShorten(t, router, "https://example.com/forever", url.Values{"shortKey": {"forever"}})
+	mustShorten(t, router, "https://example.com/hidden", url.Values{"ttl": {"1h"}, "private": {"true"}})
+
+	w := serve(router, http.MethodGet, "/api/expiring?within=24", nil, bearer(adminToken))
+	var res ExpiringResponse
+	decode(t, w, &res)
+	if w.Code != http.StatusOK || len(res.Links) != 1 || res.Links[0].ShortKey != soon {
+		t.Fatalf("status %d, %+v", w.Code, res)
+	}
+	if res.Links[0].TTL <= 3600 || res.Links[0].TTL > 7200 {
+		t.Fatalf("ttl %d", res.Links[0].TTL)
+	}
+
+	if w := serve(router, http.MethodGet, "/api/expiring?within=0", nil, bearer(adminToken)); w.Code != http.StatusBadRequest {
+		t.Fatalf("within=0: status %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// defaultNotifiedPrefix is the default prefix for Redis markers of short keys already notified about their expiry.
+const defaultNotifiedPrefix = "myurls:notified:"
+
+// expiryScanInterval is the interval between lookups of short keys approaching expiry.
+const expiryScanInterval = time.Minute
+
+// expiryWebhookURL receives a POST for every short link approaching expiry, falling back to webhookURL.
+var expiryWebhookURL string
+
+// expiryNotifyWindow is how long before expiry a short link is notified about, 0 to disable.
+var expiryNotifyWindow time.Duration
+
+// ExpiryEvent is the webhook payload sent when a short link approaches expiry.
+type ExpiryEvent struct {
+	Event    string `json:"event"`
+	ShortUrl string `json:"shortUrl"`
+	LongUrl  string `json:"longUrl"`
+	ShortKey string `json:"shortKey"`
+	TTL      int    `json:"ttl"`
+	ExpireAt int64  `json:"expireAt"`
+}
+
+// watchExpiringLinks periodically notifies the short keys approaching expiry.
+func watchExpiringLinks() {
+	for {
+		if err := notifyExpiringLinks(); err != nil {
+			log.Println("Expiry scan failed:", err)
+		}
+		time.Sleep(expiryScanInterval)
+	}
+}
+
+// notifyExpiringLinks fires the expiry webhook once for every short key expiring within the window,
+// looking them up in the expiry index instead of scanning the keyspace.
+// The marker of a notified key expires together with it, so a renewed link is notified again.
+func notifyExpiringLinks() error {
+	redisClient := redisPool.Get()
+	defer redisClient.Close()
+
+	window := expiryNotifyWindow.Milliseconds()
+	// 索引按秒记录过期时间，取整后多取一秒，实际剩余时间以 pttl 为准
+	now := time.Now()
+	shortKeys, err := redis.Strings(redisClient.Do("zrangebyscore", redisKey(defaultExpiryKey), now.Unix(), now.Add(expiryNotifyWindow).Unix()+1))
+	if err != nil {
+		return err
+	}
+
+	for _, shortKey := range shortKeys {
+		if err := notifyExpiringLink(redisClient, shortKey, window); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// notifyExpiringLink fires the expiry webhook for a short key from the expiry index if it still
+// expires within window milliseconds and was not notified yet.
+func notifyExpiringLink(conn redis.Conn, shortKey string, window int64) error {
+	key := redisKey(shortKey)
+	pttl, err := redis.Int64(conn.Do("pttl", key))
+	if err != nil || pttl <= 0 || pttl > window {
+		return err
+	}
+
+	// 已通知过的短链接不重复通知
+	_, err = redis.String(conn.Do("set", redisKey(defaultNotifiedPrefix+shortKey), 1, "nx", "px", pttl))
+	if err == redis.ErrNil {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	longUrl, err := redis.String(conn.Do("get", key))
+	if err == redis.ErrNil {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if isPlaceholder(longUrl) {
+		return nil
+	}
+
+	notifyLinkExpiring(ExpiryEvent{
+		Event:    "expiring",
+		ShortUrl: linkAddress(shortKey),
+		LongUrl:  longUrl,
+		ShortKey: shortKey,
+		TTL:      int(pttl / 1000),
+		ExpireAt: time.Now().Add(time.Duration(pttl) * time.Millisecond).Unix(),
+	})
+
+	return nil
+}
+
+// notifyLinkExpiring delivers event to the expiry webhook in the background.
+func notifyLinkExpiring(event ExpiryEvent) {
+	target := expiryWebhookURL
+	if target == "" {
+		target = webhookURL
+	}
+
+	go func() {
+		if err := deliverWebhook(target, event); err != nil {
+			log.Println("Expiry webhook delivery failed:", err)
+		}
+	}()
+}
+
+// linkAddress returns the short URL of a short key on the default domain, outside of any request.
+func linkAddress(shortKey string) string {
+	scheme := defaultScheme()
+	if subdomainMode {
+		return scheme + "://" + shortKey + "." + domain + basePath
+	}
+
+	return scheme + "://" + domain + basePath + "/" + shortKey
+}
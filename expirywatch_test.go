@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNotifyExpiringLinks(t *testing.T) {
+	router, mr := newTestRouter(t)
+	events := make(chan ExpiryEvent, 4)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event ExpiryEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Error(err)
+		}
+		events <- event
+	}))
+	defer receiver.Close()
+	setFlag(t, &expiryWebhookURL, receiver.URL)
+	setFlag(t, &expiryNotifyWindow, 2*time.Hour)
+
+	soon := mustShorten(t, router, "https://example.com/soon", url.Values{"ttl": {"1h"}})
+	mustShorten(t, router, "https://example.com/later", url.Values{"ttl": {"30d"}})
+	mustShorten(t, router, "", url.Values{"shortKey": {"held"}, "ttl": {"1h"}})
+
+	for i := 0; i < 2; i++ {
+		if err := notifyExpiringLinks(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case event := <-events:
+		if event.Event != "expiring" || event.ShortKey != soon || event.ShortUrl != "https://s.test/"+soon || event.LongUrl != "https://example.com/soon" || event.TTL <= 0 || event.TTL > 3600 {
+			t.Fatalf("event %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expiry webhook not delivered")
+	}
+	select {
+	case event := <-events:
+		t.Fatalf("unexpected event %+v", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// 通知标记随链接过期
+	if ttl := mr.TTL(defaultNotifiedPrefix + soon); ttl <= 0 || ttl > time.Hour {
+		t.Fatalf("notified marker ttl %v", ttl)
+	}
+
+	// 从过期索引中查找，不随短链接总数扫描整个键空间
+	for i := 0; i < 50; i++ {
+		mustShorten(t, router, "https://example.com/later/"+strconv.Itoa(i), url.Values{"ttl": {"30d"}})
+	}
+	start := mr.CommandCount()
+	if err := notifyExpiringLinks(); err != nil {
+		t.Fatal(err)
+	}
+	if got := mr.CommandCount() - start; got > 5 {
+		t.Fatalf("%d Redis commands for one expiring link among many", got)
+	}
+}
+
+func TestLinkAddress(t *testing.T) {
+	newTestRouter(t)
+	if got := linkAddress("abc"); got != "https://s.test/abc" {
+		t.Fatalf("linkAddress = %q", got)
+	}
+	// 与请求中生成的短链接一致，任何非 0 的 -https 都使用 https
+	setFlag(t, &https, 2)
+	if got := linkAddress("abc"); got != "https://s.test/abc" {
+		t.Fatalf("linkAddress with -https 2 = %q", got)
+	}
+	setFlag(t, &https, 0)
+	setFlag(t, &basePath, "/u")
+	if got := linkAddress("abc"); got != "http://s.test/u/abc" {
+		t.Fatalf("linkAddress with a base path = %q", got)
+	}
+	setFlag(t, &basePath, "")
+	setFlag(t, &subdomainMode, true)
+	if got := linkAddress("abc"); got != "http://abc.s.test" {
+		t.Fatalf("linkAddress in subdomain mode = %q", got)
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gomodule/redigo/redis"
+)
+
+// exportFlushEvery is how many exported lines are written between flushes.
+const exportFlushEvery = 100
+
+// auxKeyPrefix is the prefix shared by all auxiliary keys, which are never short keys.
+const auxKeyPrefix = "myurls:"
+
+// ExportedLink is one NDJSON line of the export and import endpoints, ttl is -1 for persistent links.
+// Meta carries the meta hash without its HMACs, which are signed again with the -secret of the importing instance.
+type ExportedLink struct {
+	ShortKey string            `json:"shortKey"`
+	LongUrl  string            `json:"longUrl"`
+	TTL      int               `json:"ttl"`
+	Meta     map[string]string `json:"meta,omitempty"`
+}
+
+// signatureFields are the meta hash fields derived from -secret, left out of exports.
+var signatureFields = []string{"hmac", "destinationsHmac"}
+
+// ImportResponse is the response structure of the import endpoint.
+type ImportResponse struct {
+	Code     int
+	Message  string
+	Imported int
+	Skipped  int
+	Failed   int
+}
+
+// isShortKey reports whether an unprefixed Redis key may hold a short link.
+func isShortKey(key string) bool {
+	return key != "" && !strings.HasPrefix(key, auxKeyPrefix) && !strings.ContainsAny(key, "/ \t\r\n")
+}
+
+// 导出全部短链接为 NDJSON，边扫描边输出
+func exportHandler(context *gin.Context) {
+	// 导出耗时与数据量相关，不受写超时限制
+	_ = http.NewResponseController(context.Writer).SetWriteDeadline(time.Time{})
+
+	redisClient := redisPool.Get()
+	defer redisClient.Close()
+
+	context.Header("Content-Type", "application/x-ndjson")
+	context.Status(http.StatusOK)
+
+	encoder := json.NewEncoder(context.Writer)
+	prefix := redisKey("")
+	written := 0
+	err := scanKeys(redisClient, prefix+"*", func(key string) error {
+		shortKey := strings.TrimPrefix(key, prefix)
+		if !isShortKey(shortKey) {
+			return nil
+		}
+
+		longUrl, err := redis.String(redisClient.Do("get", key))
+		if err != nil {
+			// 已过期或非字符串类型的键
+			return nil
+		}
+		ttl, err := redis.Int(redisClient.Do("ttl", key))
+		if err != nil || ttl == -2 {
+			return err
+		}
+		// 私有、限次等设置随链接导出，导入后保持不变
+		meta, err := redis.StringMap(redisClient.Do("hgetall", redisKey(defaultMetaPrefix+shortKey)))
+		if err != nil {
+			return err
+		}
+		for _, field := range signatureFields {
+			delete(meta, field)
+		}
+
+		if err := encoder.Encode(ExportedLink{ShortKey: shortKey, LongUrl: longUrl, TTL: ttl, Meta: meta}); err != nil {
+			return err
+		}
+		if written++; written%exportFlushEvery == 0 {
+			context.Writer.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		// 响应已开始输出，只能记录日志并中断
+		log.Println("Export failed:", err)
+	}
+	context.Writer.Flush()
+}
+
+// 从 NDJSON 导入短链接，已存在的短链接不覆盖
+func importHandler(context *gin.Context) {
+	res := &ImportResponse{Code: 1}
+
+	if err := writesSuspended(); err != nil {
+		res.Code = 0
+		res.Message = err.Error()
+		context.JSON(http.StatusServiceUnavailable, *res)
+		return
+	}
+	_ = http.NewResponseController(context.Writer).SetReadDeadline(time.Time{})
+
+	redisClient := redisPool.Get()
+	defer redisClient.Close()
+
+	scanner := bufio.NewScanner(context.Request.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxUrlLen+64*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var link ExportedLink
+		if err := json.Unmarshal([]byte(line), &link); err != nil || !isShortKey(link.ShortKey) || link.LongUrl == "" || link.TTL == 0 || link.TTL < -1 {
+			res.Failed++
+			continue
+		}
+		// 与创建短链接一样校验目标地址与设置，拒绝无法通过 /short 创建的数据
+		meta, err := importedMeta(link.Meta)
+		if err == nil {
+			err = validateLongUrl(link.LongUrl)
+		}
+		if err != nil {
+			res.Failed++
+			continue
+		}
+		link.Meta = meta
+
+		args := redis.Args{}.Add(redisKey(link.ShortKey), link.LongUrl, "nx")
+		if link.TTL > 0 {
+			args = args.Add("ex", link.TTL)
+		}
+		_, err = redis.String(redisClient.Do("set", args...))
+		if err == redis.ErrNil {
+			res.Skipped++
+			continue
+		} else if err != nil {
+			res.Code = 0
+			res.Message = storageError(err).Error()
+			context.JSON(http.StatusInternalServerError, *res)
+			return
+		}
+		if err := importLinkMeta(redisClient, link); err != nil {
+			res.Code = 0
+			res.Message = storageError(err).Error()
+			context.JSON(http.StatusInternalServerError, *res)
+			return
+		}
+		if err := syncLinkExpiry(redisClient, link.ShortKey); err != nil {
+			res.Code = 0
+			res.Message = storageError(err).Error()
+			context.JSON(http.StatusInternalServerError, *res)
+			return
+		}
+		// 缓存中可能仍有同名 key 此前的目标地址
+		linkCache.remove(link.ShortKey)
+		res.Imported++
+	}
+	if err := scanner.Err(); err != nil {
+		res.Code = 0
+		res.Message = "导入数据读取失败: " + err.Error()
+		context.JSON(http.StatusBadRequest, *res)
+		return
+	}
+
+	context.JSON(http.StatusOK, *res)
+}
+
+// importedMeta validates the meta hash of an imported link like the parameters of /short and returns
+// it in its stored encoding. Unknown fields are rejected and HMACs dropped, as they are signed again.
+func importedMeta(fields map[string]string) (map[string]string, error) {
+	meta := map[string]string{}
+	for field, value := range fields {
+		var err error
+		switch field {
+		case "hmac", "destinationsHmac":
+			continue
+		case "requireAuth", "unlisted", "customTTL", "disabled":
+			if value != "1" {
+				err = fmt.Errorf("%s必须为1", field)
+			} else if field == "requireAuth" && jwtSecret == "" {
+				err = errors.New("未配置JWT密钥，无法导入私有链接")
+			}
+		case "maxClicks":
+			if n, convErr := strconv.Atoi(value); convErr != nil || n <= 0 {
+				err = errors.New("maxClicks必须为正整数")
+			}
+		case "redirectStatus":
+			if status, convErr := strconv.Atoi(value); convErr != nil || !validRedirectStatus(status) {
+				err = errors.New("redirectStatus必须为301或302")
+			}
+		case "createdAt":
+			if createdAt, convErr := strconv.ParseInt(value, 10, 64); convErr != nil || createdAt <= 0 {
+				err = errors.New("createdAt必须为Unix时间戳")
+			}
+		case "createdBy":
+			if net.ParseIP(value) == nil {
+				err = errors.New("createdBy必须为IP地址")
+			}
+		case "title":
+			if utf8.RuneCountInString(value) > maxTitleLen {
+				err = errors.New("title过长")
+			}
+		case "utm":
+			value, err = parseUtm(value)
+		case "languages":
+			value, err = parseLanguages(value)
+		case "platforms":
+			value, err = parsePlatforms(value)
+		case "tags":
+			var tags []string
+			if tags, err = parseTags([]string{value}); err == nil {
+				value = encodeTags(tags)
+			}
+		default:
+			err = fmt.Errorf("未知的元数据字段: %s", field)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if value != "" {
+			meta[field] = value
+		}
+	}
+
+	return meta, nil
+}
+
+// importLinkMeta restores the meta hash and tags of an imported link, then signs its long URL and
+// alternative destinations with the -secret of this instance. HMACs found in the import are ignored.
+func importLinkMeta(conn redis.Conn, link ExportedLink) error {
+	meta := link.Meta
+	if len(meta) > 0 {
+		args := redis.Args{}.Add(redisKey(defaultMetaPrefix + link.ShortKey)).AddFlat(meta)
+		if _, err := conn.Do("hset", args...); err != nil {
+			return err
+		}
+	}
+	if err := tagLink(link.ShortKey, linkTags(meta)); err != nil {
+		return err
+	}
+	if err := signLink(conn, link.ShortKey, link.LongUrl); err != nil {
+		return err
+	}
+
+	return signDestinations(conn, link.ShortKey, meta["platforms"], meta["languages"])
+}
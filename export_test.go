@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// exportLinks fetches the NDJSON export and decodes its lines.
+func exportLinks(t *testing.T, router http.Handler) []ExportedLink {
+	t.Helper()
+	w := serve(router, http.MethodGet, "/admin/export", nil, bearer(adminToken))
+	if w.Code != http.StatusOK {
+		t.Fatalf("export status %d", w.Code)
+	}
+	return decodeExport(t, w.Body)
+}
+
+// decodeExport decodes NDJSON export lines sorted by short key.
+func decodeExport(t *testing.T, r io.Reader) []ExportedLink {
+	t.Helper()
+	var links []ExportedLink
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var link ExportedLink
+		if err := json.Unmarshal(scanner.Bytes(), &link); err != nil {
+			t.Fatalf("export line %q: %v", scanner.Text(), err)
+		}
+		links = append(links, link)
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].ShortKey < links[j].ShortKey })
+	return links
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	router, _ := newTestRouter(t)
+	setFlag(t, &adminToken, "secret-admin")
+
+	mustShorten(t, router, "https://example.com/a", url.Values{"shortKey": {"alpha"}})
+	mustShorten(t, router, "https://example.com/b", url.Values{"shortKey": {"beta"}, "ttl": {"2d"}})
+	exported := exportLinks(t, router)
+	if len(exported) != 2 || exported[0].ShortKey != "alpha" || exported[0].TTL != -1 || exported[1].TTL <= 0 {
+		t.Fatalf("export %+v", exported)
+	}
+
+	var body bytes.Buffer
+	for _, link := range exported {
+		line, _ := json.Marshal(link)
+		body.Write(append(line, '\n'))
+	}
+	body.WriteString("not json\n")
+
+	// 导入到新的实例
+	restored, mr := newTestRouter(t)
+	mr.Set("alpha", "https://example.com/existing")
+	req := httptest.NewRequest(http.MethodPost, "/admin/import", &body)
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	w := serveRequest(restored, req)
+	var res ImportResponse
+	decode(t, w, &res)
+	if w.Code != http.StatusOK || res.Imported != 1 || res.Skipped != 1 || res.Failed != 1 {
+		t.Fatalf("import: status %d, %+v", w.Code, res)
+	}
+	if got := serve(restored, http.MethodGet, "/beta", nil, nil).Header().Get("Location"); got != "https://example.com/b" {
+		t.Fatalf("imported link redirects to %q", got)
+	}
+	if got, _ := mr.Get("alpha"); got != "https://example.com/existing" {
+		t.Fatalf("import overwrote an existing key with %q", got)
+	}
+	if mr.TTL("beta") <= 0 {
+		t.Fatal("import dropped the ttl")
+	}
+}
+
+func TestExportGzip(t *testing.T) {
+	router, _ := newTestRouter(t)
+	setFlag(t, &adminToken, "secret-admin")
+	setFlag(t, &enableGzip, true)
+
+	mustShorten(t, router, "https://example.com/gz", url.Values{"shortKey": {"gz"}})
+	header := bearer(adminToken)
+	header.Set("Accept-Encoding", "gzip")
+	w := serve(router, http.MethodGet, "/admin/export", nil, header)
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Content-Encoding %q", w.Header().Get("Content-Encoding"))
+	}
+	reader, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if links := decodeExport(t, reader); len(links) != 1 || links[0].LongUrl != "https://example.com/gz" {
+		t.Fatalf("decoded export %+v", links)
+	}
+
+	plain := serve(router, http.MethodGet, "/admin/export", nil, bearer(adminToken))
+	if plain.Header().Get("Content-Encoding") != "" || !strings.Contains(plain.Body.String(), `"gz"`) {
+		t.Fatal("export compressed without Accept-Encoding")
+	}
+}
+
+func TestExportImportMeta(t *testing.T) {
+	router, _ := newTestRouter(t)
+	setFlag(t, &adminToken, "secret-admin")
+	setFlag(t, &jwtSecret, "jwt-secret")
+	setFlag(t, &linkSecret, "source-secret")
+
+	mustShorten(t, router, "https://example.com/private", url.Values{"shortKey": {"private"}, "requireAuth": {"true"}})
+	mustShorten(t, router, "https://example.com/app", url.Values{
+		"shortKey":  {"app"},
+		"platforms": {`{"ios":"https://apps.example.com/ios"}`},
+		"tags":      {"launch"},
+		"maxClicks": {"3"},
+	})
+	exported := exportLinks(t, router)
+	if len(exported) != 2 || exported[1].Meta["requireAuth"] != "1" || exported[0].Meta["maxClicks"] != "3" {
+		t.Fatalf("export %+v", exported)
+	}
+	for _, link := range exported {
+		if link.Meta["hmac"] != "" || link.Meta["destinationsHmac"] != "" {
+			t.Fatalf("export of %s carries the HMACs of the source instance", link.ShortKey)
+		}
+	}
+
+	var body bytes.Buffer
+	for _, link := range exported {
+		line, _ := json.Marshal(link)
+		body.Write(append(line, '\n'))
+	}
+
+	// 目标实例使用不同的 -secret，导入时重新签名
+	restored, _ := newTestRouter(t)
+	setFlag(t, &linkSecret, "target-secret")
+	req := httptest.NewRequest(http.MethodPost, "/admin/import", &body)
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	if w := serveRequest(restored, req); w.Code != http.StatusOK {
+		t.Fatalf("import: status %d, %s", w.Code, w.Body)
+	}
+
+	if w := serve(restored, http.MethodGet, "/private", nil, nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("imported private link without a token: status %d, want 401", w.Code)
+	}
+	w := serve(restored, http.MethodGet, "/app", nil, http.Header{"User-Agent": {iphoneUA}})
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "https://apps.example.com/ios" {
+		t.Fatalf("imported platform link: status %d, Location %q", w.Code, w.Header().Get("Location"))
+	}
+	var tagged TagResponse
+	decode(t, serve(restored, http.MethodGet, "/admin/links?tag=launch", nil, bearer(adminToken)), &tagged)
+	if len(tagged.Links) != 1 || tagged.Links[0].ShortKey != "app" {
+		t.Fatalf("imported tags %+v", tagged)
+	}
+	for i := 0; i < 2; i++ {
+		serve(restored, http.MethodGet, "/app", nil, nil)
+	}
+	if w := serve(restored, http.MethodGet, "/app", nil, nil); w.Code != http.StatusGone {
+		t.Fatalf("imported maxClicks: status %d after 3 clicks, want 410", w.Code)
+	}
+}
+
+func TestImportValidation(t *testing.T) {
+	router, mr := newTestRouter(t)
+	setFlag(t, &adminToken, "secret-admin")
+
+	lines := []ExportedLink{
+		{ShortKey: "script", LongUrl: "javascript:alert(1)", TTL: -1},
+		{ShortKey: "nohost", LongUrl: "https://", TTL: -1},
+		{ShortKey: "unknown", LongUrl: "https://example.com/u", TTL: -1, Meta: map[string]string{"owner": "x"}},
+		{ShortKey: "forged", LongUrl: "https://example.com/f", TTL: -1, Meta: map[string]string{"disabled": "yes"}},
+		{ShortKey: "private", LongUrl: "https://example.com/p", TTL: -1, Meta: map[string]string{"requireAuth": "1"}},
+		{ShortKey: "clicks", LongUrl: "https://example.com/c", TTL: -1, Meta: map[string]string{"maxClicks": "-1"}},
+		{ShortKey: "lang", LongUrl: "https://example.com/l", TTL: -1, Meta: map[string]string{"languages": `{"en":"javascript:alert(1)"}`}},
+		{ShortKey: "valid", LongUrl: "https://example.com/v", TTL: -1, Meta: map[string]string{"redirectStatus": "302", "tags": `["launch"]`}},
+	}
+	var body bytes.Buffer
+	for _, link := range lines {
+		line, _ := json.Marshal(link)
+		body.Write(append(line, '\n'))
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/import", &body)
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	w := serveRequest(router, req)
+	var res ImportResponse
+	decode(t, w, &res)
+	if w.Code != http.StatusOK || res.Imported != 1 || res.Failed != len(lines)-1 {
+		t.Fatalf("import: status %d, %+v", w.Code, res)
+	}
+	for _, link := range lines[:len(lines)-1] {
+		if mr.Exists(link.ShortKey) {
+			t.Fatalf("invalid link %s was imported", link.ShortKey)
+		}
+	}
+	if w := serve(router, http.MethodGet, "/valid", nil, nil); w.Code != http.StatusFound {
+		t.Fatalf("imported redirectStatus: status %d, want 302", w.Code)
+	}
+}
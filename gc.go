@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// gcScanCount is the COUNT hint passed to SCAN during garbage collection.
+const gcScanCount = 100
+
+// scanKeys calls fn for every key matching pattern, iterating with SCAN so Redis isn't blocked.
+func scanKeys(conn redis.Conn, pattern string, fn func(key string) error) error {
+	cursor := 0
+	for {
+		reply, err := redis.Values(conn.Do("scan", cursor, "match", pattern, "count", gcScanCount))
+		if err != nil {
+			return err
+		}
+
+		keys, err := redis.Strings(reply[1], nil)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := fn(key); err != nil {
+				return err
+			}
+		}
+
+		cursor, err = redis.Int(reply[0], nil)
+		if err != nil {
+			return err
+		}
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
+
+// collectGarbage deletes md5 reverse mappings and renewal locks whose short key no longer exists.
+func collectGarbage() (md5Removed int, locksRemoved int, err error) {
+	redisClient := redisPool.Get()
+	defer redisClient.Close()
+
+	err = scanKeys(redisClient, redisKey(defaultMd5Prefix)+"*", func(key string) error {
+		shortKey, err := redis.String(redisClient.Do("get", key))
+		if err == redis.ErrNil {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		exists, err := redis.Bool(redisClient.Do("exists", redisKey(shortKey)))
+		if err != nil || exists {
+			return err
+		}
+		md5Removed++
+		_, err = redisClient.Do("del", key)
+		return err
+	})
+	if err != nil {
+		return md5Removed, locksRemoved, err
+	}
+
+	lockPrefix := redisKey(defaultLockPrefix)
+	err = scanKeys(redisClient, lockPrefix+"*", func(key string) error {
+		exists, err := redis.Bool(redisClient.Do("exists", redisKey(strings.TrimPrefix(key, lockPrefix))))
+		if err != nil || exists {
+			return err
+		}
+		locksRemoved++
+		_, err = redisClient.Do("del", key)
+		return err
+	})
+
+	return md5Removed, locksRemoved, err
+}
@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestCollectGarbage(t *testing.T) {
+	router, mr := newTestRouter(t)
+	setFlag(t, &keyPrefix, "app")
+
+	live := mustShorten(t, router, "https://example.com/live", nil)
+	dead := mustShorten(t, router, "https://example.com/dead", nil)
+	mr.Set("app:"+defaultLockPrefix+live, "1")
+	mr.Set("app:"+defaultLockPrefix+dead, "1")
+	mr.Set("app:"+defaultMd5Prefix+"dangling", "missing")
+	mr.Del("app:" + dead)
+
+	md5Removed, locksRemoved, err := collectGarbage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if md5Removed != 2 || locksRemoved != 1 {
+		t.Fatalf("removed %d md5 mappings and %d locks, want 2 and 1", md5Removed, locksRemoved)
+	}
+	if !mr.Exists("app:"+defaultMd5Prefix+longUrlHash("https://example.com/live")) || !mr.Exists("app:"+defaultLockPrefix+live) {
+		t.Fatal("live link data collected")
+	}
+	if mr.Exists("app:" + defaultLockPrefix + dead) {
+		t.Fatal("orphaned lock kept")
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"compress/gzip"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// enableGzip compresses admin responses for clients accepting gzip.
+var enableGzip bool
+
+// gzipWriterPool reuses gzip writers across responses.
+var gzipWriterPool = sync.Pool{
+	New: func() interface{} {
+		return gzip.NewWriter(nil)
+	},
+}
+
+// gzipResponseWriter compresses everything written to the wrapped gin.ResponseWriter.
+type gzipResponseWriter struct {
+	gin.ResponseWriter
+	writer *gzip.Writer
+}
+
+func (w *gzipResponseWriter) Write(data []byte) (int, error) {
+	return w.writer.Write(data)
+}
+
+func (w *gzipResponseWriter) WriteString(s string) (int, error) {
+	return w.writer.Write([]byte(s))
+}
+
+// Flush flushes the compressed data written so far, so streamed responses keep streaming.
+func (w *gzipResponseWriter) Flush() {
+	_ = w.writer.Flush()
+	w.ResponseWriter.Flush()
+}
+
+// 响应压缩，仅在 -gzip 开启且客户端支持时生效
+func Gzip() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		if !enableGzip || !strings.Contains(context.GetHeader("Accept-Encoding"), "gzip") {
+			context.Next()
+			return
+		}
+
+		writer := gzipWriterPool.Get().(*gzip.Writer)
+		writer.Reset(context.Writer)
+		defer func() {
+			_ = writer.Close()
+			gzipWriterPool.Put(writer)
+		}()
+
+		context.Header("Content-Encoding", "gzip")
+		context.Header("Vary", "Accept-Encoding")
+		context.Writer = &gzipResponseWriter{ResponseWriter: context.Writer, writer: writer}
+		context.Next()
+	}
+}
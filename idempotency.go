@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gomodule/redigo/redis"
+)
+
+// idempotencyHeader is the header carrying the client's idempotency key.
+const idempotencyHeader = "Idempotency-Key"
+
+// defaultIdemPrefix is the default prefix for Redis cached idempotent responses.
+const defaultIdemPrefix = "myurls:idem:"
+
+// idempotencyTTL is how long in seconds a response is replayed for the same idempotency key.
+const idempotencyTTL = secondsPerDay
+
+// idempotencyLockTTL bounds in seconds how long an in-flight request holds its idempotency key.
+const idempotencyLockTTL = 60
+
+// idempotentResponse is a successful response cached for an idempotency key.
+type idempotentResponse struct {
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// recordingWriter keeps a copy of the response body written through it.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// 幂等键：相同 Idempotency-Key 的重试直接返回首次成功的响应
+func Idempotency() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		key := context.GetHeader(idempotencyHeader)
+		if key == "" {
+			context.Next()
+			return
+		}
+		if !validRequestID(key) {
+			respondError(context, http.StatusBadRequest, "Idempotency-Key格式错误")
+			context.Abort()
+			return
+		}
+
+		// 按调用方凭证隔离，避免不同客户端的相同键互相命中
+		sum := sha256.Sum256([]byte(context.GetHeader("Authorization") + "\n" + key))
+		idemKey := redisKey(defaultIdemPrefix + hex.EncodeToString(sum[:]))
+
+		redisClient := redisPool.Get()
+		defer redisClient.Close()
+
+		locked, err := redis.String(redisClient.Do("set", idemKey, "", "nx", "ex", idempotencyLockTTL))
+		if err != nil && err != redis.ErrNil {
+			// 存储不可用时按普通请求处理
+			context.Next()
+			return
+		}
+		if locked != "OK" {
+			cached, _ := redis.Bytes(redisClient.Do("get", idemKey))
+			var res idempotentResponse
+			if len(cached) == 0 || json.Unmarshal(cached, &res) != nil {
+				respondError(context, http.StatusConflict, "相同Idempotency-Key的请求正在处理中")
+				context.Abort()
+				return
+			}
+			context.Data(res.Status, res.ContentType, res.Body)
+			context.Abort()
+			return
+		}
+
+		writer := &recordingWriter{ResponseWriter: context.Writer}
+		context.Writer = writer
+		context.Next()
+
+		// 仅缓存成功的响应，失败的请求允许重试
+		status := writer.Status()
+		if status < 200 || status > 299 {
+			_, _ = redisClient.Do("del", idemKey)
+			return
+		}
+		cached, _ := json.Marshal(idempotentResponse{Status: status, ContentType: writer.Header().Get("Content-Type"), Body: writer.body.Bytes()})
+		_, _ = redisClient.Do("set", idemKey, cached, "ex", idempotencyTTL)
+	}
+}
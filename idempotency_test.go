@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	router, _ := newTestRouter(t)
+	setFlag(t, &noDedup, true)
+
+	form := url.Values{"longUrl": {base64.StdEncoding.EncodeToString([]byte("https://example.com/retry"))}}
+	header := http.Header{idempotencyHeader: {"order-42"}}
+
+	first := serve(router, http.MethodPost, "/short", form, header)
+	second := serve(router, http.MethodPost, "/short", form, header)
+	if first.Code != http.StatusOK || second.Code != http.StatusOK {
+		t.Fatalf("status %d, %d", first.Code, second.Code)
+	}
+	if first.Body.String() != second.Body.String() || first.Header().Get("Content-Type") != second.Header().Get("Content-Type") {
+		t.Fatalf("replayed response differs:\n%s\n%s", first.Body, second.Body)
+	}
+
+	other := serve(router, http.MethodPost, "/short", form, http.Header{idempotencyHeader: {"order-43"}})
+	if other.Body.String() == first.Body.String() {
+		t.Fatal("another idempotency key replayed the first response")
+	}
+
+	if w := serve(router, http.MethodPost, "/short", form, http.Header{idempotencyHeader: {"bad key!"}}); w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid idempotency key: status %d, want 400", w.Code)
+	}
+}
+
+func TestIdempotencyKeyRetriesFailures(t *testing.T) {
+	router, _ := newTestRouter(t)
+	header := http.Header{idempotencyHeader: {"order-44"}}
+
+	if w := serve(router, http.MethodPost, "/short", url.Values{"longUrl": {"!!"}}, header); w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid request: status %d", w.Code)
+	}
+	form := url.Values{"longUrl": {base64.StdEncoding.EncodeToString([]byte("https://example.com/fixed"))}}
+	if w := serve(router, http.MethodPost, "/short", form, header); w.Code != http.StatusOK {
+		t.Fatalf("retry after failure: status %d, %s", w.Code, w.Body)
+	}
+}
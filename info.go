@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// version is the build version, set with -ldflags "-X main.version=...".
+var version = "dev"
+
+// buildTime is the build time, set with -ldflags "-X main.buildTime=...".
+var buildTime = ""
+
+// linkTTL is the default lifetime in seconds of generated short links.
+var linkTTL int
+
+// InfoResponse is the response structure of the API info endpoint.
+type InfoResponse struct {
+	Code               int
+	Message            string
+	Version            string
+	BuildTime          string
+	TTL                int
+	TTLSeconds         int
+	DefaultShortUrlLen int
+	MinShortUrlLen     int
+	MaxShortUrlLen     int
+	MaxUrlLen          int
+	Features           []string
+}
+
+// enabledFeatures lists the optional features enabled by the current configuration.
+func enabledFeatures() []string {
+	features := []string{"custom-key", "max-clicks", "preview", "renew", "stats", "utm"}
+	if keyMode == keyModeCounter {
+		features = append(features, "counter-keygen")
+	}
+	if enableChecksum {
+		features = append(features, "checksum")
+	}
+	if caseInsensitive {
+		features = append(features, "case-insensitive")
+	}
+	if jwtSecret != "" {
+		features = append(features, "private-links")
+	}
+	if authToken != "" || adminToken != "" {
+		features = append(features, "update")
+	}
+	if len(domains) > 0 {
+		features = append(features, "domains")
+	}
+	if passthroughQuery {
+		features = append(features, "passthrough-query")
+	}
+	if linkSecret != "" {
+		features = append(features, "integrity")
+	}
+	if fetchTitles {
+		features = append(features, "title")
+	}
+	if webhookURL != "" {
+		features = append(features, "webhook")
+	}
+
+	return features
+}
+
+// 服务信息，供客户端在运行时发现能力
+func infoHandler(context *gin.Context) {
+	context.JSON(http.StatusOK, InfoResponse{
+		Code:               1,
+		Version:            version,
+		BuildTime:          buildTime,
+		TTL:                linkTTL / secondsPerDay,
+		TTLSeconds:         linkTTL,
+		DefaultShortUrlLen: currentShortUrlLen(),
+		MinShortUrlLen:     minShortUrlLen,
+		MaxShortUrlLen:     maxShortUrlLen,
+		MaxUrlLen:          maxUrlLen,
+		Features:           enabledFeatures(),
+	})
+}
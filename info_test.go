@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestInfo(t *testing.T) {
+	router, _ := newTestRouter(t)
+	setFlag(t, &maxUrlLen, 4096)
+	setFlag(t, &linkTTL, 7*secondsPerDay)
+	setFlag(t, &enableChecksum, true)
+
+	w := serve(router, http.MethodGet, "/api", nil, nil)
+	var res InfoResponse
+	decode(t, w, &res)
+	if w.Code != http.StatusOK || res.Code != 1 || res.Version != version {
+		t.Fatalf("status %d, %+v", w.Code, res)
+	}
+	if res.TTL != 7 || res.TTLSeconds != 7*secondsPerDay || res.MaxUrlLen != 4096 {
+		t.Fatalf("limits %+v", res)
+	}
+	if res.DefaultShortUrlLen != defaultShortUrlLen || res.MinShortUrlLen != minShortUrlLen || res.MaxShortUrlLen != maxShortUrlLen {
+		t.Fatalf("key lengths %+v", res)
+	}
+
+	features := map[string]bool{}
+	for _, f := range res.Features {
+		features[f] = true
+	}
+	if !features["checksum"] || !features["custom-key"] || features["private-links"] {
+		t.Fatalf("features %v", res.Features)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestLinkIntegrity(t *testing.T) {
+	router, mr := newTestRouter(t)
+	setFlag(t, &linkSecret, "integrity-secret")
+
+	key := mustShorten(t, router, "https://example.com/signed", nil)
+	if w := serve(router, http.MethodGet, "/"+key, nil, nil); w.Code != http.StatusMovedPermanently {
+		t.Fatalf("signed link: status %d", w.Code)
+	}
+
+	// 直接改写 Redis 中的目标地址
+	mr.Set(key, "https://evil.test/")
+	w := serve(router, http.MethodGet, "/"+key, nil, nil)
+	if w.Code != http.StatusInternalServerError || w.Body.String() != errLinkTampered.Error() {
+		t.Fatalf("tampered link: status %d, %s", w.Code, w.Body)
+	}
+	if w := serve(router, http.MethodGet, "/"+key+"+", nil, nil); w.Code != http.StatusInternalServerError {
+		t.Fatalf("tampered link preview: status %d", w.Code)
+	}
+	if code, res := linkStats(t, router, key, nil); code != http.StatusInternalServerError || res.LongUrl != "" {
+		t.Fatalf("tampered link stats: status %d, %+v", code, res)
+	}
+
+	// 删除 HMAC 同样无法绕过校验
+	mr.Set(key, "https://example.com/signed")
+	mr.HDel(defaultMetaPrefix+key, "hmac")
+	if w := serve(router, http.MethodGet, "/"+key, nil, nil); w.Code != http.StatusInternalServerError {
+		t.Fatalf("unsigned link: status %d", w.Code)
+	}
+}
+
+func TestLinkIntegrityFollowsUpdate(t *testing.T) {
+	router, _ := newTestRouter(t)
+	setFlag(t, &linkSecret, "integrity-secret")
+	setFlag(t, &adminToken, "secret-admin")
+
+	key := mustShorten(t, router, "https://example.com/v1", nil)
+	serve(router, http.MethodPut, "/"+key, updateForm("https://example.com/v2"), bearer(adminToken))
+	if w := serve(router, http.MethodGet, "/"+key, nil, nil); w.Code != http.StatusMovedPermanently {
+		t.Fatalf("updated link: status %d, %s", w.Code, w.Body)
+	}
+}
+
+func TestDestinationsIntegrity(t *testing.T) {
+	router, mr := newTestRouter(t)
+	setFlag(t, &linkSecret, "integrity-secret")
+	setFlag(t, &adminToken, "secret-admin")
+	header := http.Header{"Accept-Language": {"de"}, "User-Agent": {iphoneUA}}
+
+	key := mustShorten(t, router, "https://example.com/default", url.Values{
+		"languages": {`{"de":"https://example.com/de"}`},
+		"platforms": {`{"ios":"https://apps.example.com/ios"}`},
+	})
+	if got := serve(router, http.MethodGet, "/"+key, nil, header).Header().Get("Location"); got != "https://apps.example.com/ios" {
+		t.Fatalf("signed destinations: Location %q", got)
+	}
+
+	// 改写备选目标地址后回退到已校验的长链接
+	mr.HSet(defaultMetaPrefix+key, "platforms", `{"ios":"https://evil.test/"}`)
+	if got := serve(router, http.MethodGet, "/"+key, nil, header).Header().Get("Location"); got != "https://example.com/default" {
+		t.Fatalf("tampered platforms: Location %q", got)
+	}
+	if got := serve(router, http.MethodHead, "/"+key, nil, header).Header().Get("Location"); got != "https://example.com/default" {
+		t.Fatalf("tampered platforms on HEAD: Location %q", got)
+	}
+	w := serve(router, http.MethodGet, "/api/link/"+key, nil, nil)
+	var info LinkInfo
+	decode(t, w, &info)
+	if w.Code != http.StatusInternalServerError || len(info.Platforms) != 0 {
+		t.Fatalf("tampered platforms info: status %d, %+v", w.Code, info)
+	}
+	mr.HDel(defaultMetaPrefix+key, "platforms")
+	mr.HSet(defaultMetaPrefix+key, "languages", `{"de":"https://evil.test/"}`)
+	if got := serve(router, http.MethodGet, "/"+key, nil, header).Header().Get("Location"); got != "https://example.com/default" {
+		t.Fatalf("tampered languages: Location %q", got)
+	}
+
+	// 重新生成 key 后签名随之更新
+	signed := mustShorten(t, router, "https://example.com/rekeyed", url.Values{"languages": {`{"de":"https://example.com/de"}`}})
+	w = serve(router, http.MethodPost, "/admin/rekey/"+signed, nil, bearer(adminToken))
+	var res Response
+	decode(t, w, &res)
+	if got := serve(router, http.MethodGet, strings.TrimPrefix(res.ShortUrl, "https://s.test"), nil, header).Header().Get("Location"); got != "https://example.com/de" {
+		t.Fatalf("rekeyed destinations: Location %q", got)
+	}
+}
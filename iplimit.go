@@ -0,0 +1,102 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// defaultIPCountPrefix is the default prefix for Redis sorted sets of the active links created per client IP.
+const defaultIPCountPrefix = "myurls:ipcount:"
+
+// recordIP stores the IP of the creating client with each link for abuse auditing.
+var recordIP bool
+
+// maxPerIP is the maximum number of active links a client IP may have created, zero for no limit.
+var maxPerIP int
+
+// ipCountKey returns the sorted set of links created by a client IP, scored by their expiry in milliseconds.
+func ipCountKey(clientIP string) string {
+	return redisKey(defaultIPCountPrefix + clientIP)
+}
+
+// ipLimitReached reports whether a client IP already has maxPerIP active links.
+// Expired members are pruned by score, deleted ones only once the limit is reached.
+func ipLimitReached(clientIP string) (bool, error) {
+	if maxPerIP <= 0 {
+		return false, nil
+	}
+
+	redisClient := redisPool.Get()
+	defer redisClient.Close()
+
+	key := ipCountKey(clientIP)
+	if _, err := redisClient.Do("zremrangebyscore", key, "-inf", time.Now().UnixMilli()); err != nil {
+		return false, err
+	}
+	members, err := redis.Strings(redisClient.Do("zrange", key, 0, -1))
+	if err != nil || len(members) < maxPerIP {
+		return false, err
+	}
+
+	// 提前删除或访问次数用完的链接不再计数
+	for _, shortKey := range members {
+		_ = redisClient.Send("exists", redisKey(shortKey))
+	}
+	if err := redisClient.Flush(); err != nil {
+		return false, err
+	}
+	var missing []string
+	for _, shortKey := range members {
+		exists, err := redis.Bool(redisClient.Receive())
+		if err != nil {
+			return false, err
+		}
+		if !exists {
+			missing = append(missing, shortKey)
+		}
+	}
+	if len(missing) > 0 {
+		_, _ = redisClient.Do("zrem", redis.Args{}.Add(key).AddFlat(missing)...)
+	}
+	active := len(members) - len(missing)
+
+	return active >= maxPerIP, nil
+}
+
+// 记录客户端 IP 创建的短链接，随短链接过期
+func recordCreatorIP(clientIP string, shortKey string) {
+	if maxPerIP <= 0 {
+		return
+	}
+
+	redisClient := redisPool.Get()
+	defer redisClient.Close()
+
+	ttl, err := redis.Int64(redisClient.Do("pttl", redisKey(shortKey)))
+	if err != nil || ttl == -2 {
+		return
+	}
+
+	// 不过期的链接一直计数
+	score := "+inf"
+	if ttl > 0 {
+		score = strconv.FormatInt(time.Now().UnixMilli()+ttl, 10)
+	}
+	key := ipCountKey(clientIP)
+	if _, err := redisClient.Do("zadd", key, score, shortKey); err != nil {
+		return
+	}
+
+	// 集合保留到其中最晚过期的链接
+	latest, err := redis.Strings(redisClient.Do("zrange", key, -1, -1, "withscores"))
+	if err != nil || len(latest) != 2 {
+		return
+	}
+	if expireAt, err := strconv.ParseInt(latest[1], 10, 64); err == nil {
+		_, _ = redisClient.Do("pexpireat", key, expireAt)
+	} else {
+		_, _ = redisClient.Do("persist", key)
+	}
+}
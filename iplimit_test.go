@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// shortenFrom creates a short link as a client connecting from ip and returns the response status.
+func shortenFrom(router http.Handler, ip string, longUrl string) int {
+	form := url.Values{"longUrl": {base64.StdEncoding.EncodeToString([]byte(longUrl))}}
+	req := httptest.NewRequest(http.MethodPost, "/short", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.RemoteAddr = ip + ":40000"
+	return serveRequest(router, req).Code
+}
+
+func TestMaxPerIP(t *testing.T) {
+	router, mr := newTestRouter(t)
+	setFlag(t, &maxPerIP, 3)
+
+	for i := 0; i < 3; i++ {
+		if code := shortenFrom(router, "198.51.100.7", "https://example.com/"+strconv.Itoa(i)); code != http.StatusOK {
+			t.Fatalf("link %d: status %d", i, code)
+		}
+	}
+	if code := shortenFrom(router, "198.51.100.7", "https://example.com/over"); code != http.StatusTooManyRequests {
+		t.Fatalf("beyond the limit: status %d, want 429", code)
+	}
+	if code := shortenFrom(router, "198.51.100.8", "https://example.com/over"); code != http.StatusOK {
+		t.Fatalf("another IP: status %d", code)
+	}
+
+	// 删除的链接不再计数
+	members, _ := mr.ZMembers(ipCountKey("198.51.100.7"))
+	mr.Del(members[0])
+	if code := shortenFrom(router, "198.51.100.7", "https://example.com/again"); code != http.StatusOK {
+		t.Fatalf("after a link was deleted: status %d", code)
+	}
+}
+
+func TestRecordCreatorIP(t *testing.T) {
+	router, mr := newTestRouter(t)
+	setFlag(t, &adminToken, "secret-admin")
+
+	shortenFrom(router, "198.51.100.9", "https://example.com/anon")
+	if keys := mr.Keys(); strings.Contains(strings.Join(keys, ","), "198.51.100.9") {
+		t.Fatalf("IP stored without -record-ip: %v", keys)
+	}
+
+	setFlag(t, &recordIP, true)
+	shortenFrom(router, "198.51.100.9", "https://example.com/audited")
+	key := mustShorten(t, router, "https://example.com/audited", nil)
+	w := serve(router, http.MethodGet, "/admin/link/"+key, nil, bearer(adminToken))
+	var res LinkInfo
+	decode(t, w, &res)
+	if res.CreatedBy != "198.51.100.9" {
+		t.Fatalf("admin CreatedBy %q, want the original submitter", res.CreatedBy)
+	}
+}
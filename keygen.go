@@ -0,0 +1,251 @@
+package main
+
+import (
+	cryptorand "crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// keyModeRandom generates short keys randomly.
+const keyModeRandom = "random"
+
+// keyModeCounter generates short keys by encoding an incrementing Redis counter.
+const keyModeCounter = "counter"
+
+// defaultCounterKey is the Redis key of the counter used in counter mode.
+const defaultCounterKey = "myurls:counter"
+
+// errCounterOverflow is returned when the counter no longer fits in the requested key length.
+var errCounterOverflow = errors.New("计数器已超出shortUrlLen可表示范围，请增大shortUrlLen")
+
+// keyMode is the configured short key generation mode.
+var keyMode string
+
+// caseInsensitive generates lowercase short keys and lowercases incoming keys.
+var caseInsensitive bool
+
+// keyAlphabet is the alphabet short keys are generated from, letterBytes unless -alphabet or -case-insensitive is set.
+var keyAlphabet = letterBytes
+
+// ambiguousChars are the characters easily mistaken for one another, dropped by -readable-keys.
+const ambiguousChars = "0Oo1lI"
+
+// readableKeys generates keys without ambiguous characters and starting with a letter.
+var readableKeys bool
+
+// readableAlphabet is the alphabet of random keys with -readable-keys, keyAlphabet when empty.
+// Custom keys and counter mode keep using keyAlphabet.
+var readableAlphabet string
+
+// readableFirstAlphabet is the alphabet of the first character of random keys with -readable-keys.
+var readableFirstAlphabet string
+
+// enableChecksum appends a check character to short keys and verifies it on lookup.
+var enableChecksum bool
+
+// unlistedKeyLen is the length of the high-entropy keys generated for unlisted links.
+const unlistedKeyLen = maxShortUrlLen
+
+// generateSecure returns a key of length n drawn uniformly from keyAlphabet with crypto/rand,
+// regardless of the key mode, so it can't be guessed from other keys.
+func generateSecure(n int) (string, error) {
+	b := make([]byte, n)
+	for i := range b {
+		alphabet := keyPositionAlphabet(i)
+		idx, err := cryptorand.Int(cryptorand.Reader, big.NewInt(int64(len(alphabet))))
+		if err != nil {
+			return "", err
+		}
+		b[i] = alphabet[idx.Int64()]
+	}
+
+	return string(b), nil
+}
+
+// keyPositionAlphabet returns the alphabet of the i-th character of a random key.
+func keyPositionAlphabet(i int) string {
+	switch {
+	case readableAlphabet == "":
+		return keyAlphabet
+	case i == 0:
+		return readableFirstAlphabet
+	default:
+		return readableAlphabet
+	}
+}
+
+// readableAlphabets derives the -readable-keys alphabets from alphabet: it drops the
+// ambiguous characters and keeps only letters for the first character.
+func readableAlphabets(alphabet string) (string, string, error) {
+	var readable, letters strings.Builder
+	for _, c := range alphabet {
+		if strings.ContainsRune(ambiguousChars, c) {
+			continue
+		}
+		readable.WriteRune(c)
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') {
+			letters.WriteRune(c)
+		}
+	}
+	if readable.Len() < 2 || letters.Len() == 0 {
+		return "", "", errors.New("readable-keys 需要 alphabet 在去除易混淆字符后仍包含至少2个字符与1个字母")
+	}
+
+	return readable.String(), letters.String(), nil
+}
+
+// configureKeyAlphabet applies the -alphabet, -case-insensitive, -readable-keys and -subdomain-mode
+// flags to the key alphabets, shared by the server and the create subcommand.
+func configureKeyAlphabet(alphabet string) error {
+	keyAlphabet, readableAlphabet, readableFirstAlphabet = letterBytes, "", ""
+	switch {
+	case alphabet != "":
+		if err := validateAlphabet(alphabet); err != nil {
+			return err
+		}
+		keyAlphabet = alphabet
+	case caseInsensitive:
+		keyAlphabet = letterBytes[:36]
+	}
+	if readableKeys {
+		var err error
+		if readableAlphabet, readableFirstAlphabet, err = readableAlphabets(keyAlphabet); err != nil {
+			return err
+		}
+	}
+	// 域名不区分大小写，且标签仅允许字母与数字
+	if subdomainMode {
+		if !caseInsensitive || strings.Trim(keyAlphabet, "abcdefghijklmnopqrstuvwxyz0123456789") != "" {
+			return errors.New("subdomain-mode 需开启 -case-insensitive，且 alphabet 仅包含小写字母与数字")
+		}
+	}
+
+	return nil
+}
+
+// validateAlphabet checks a -alphabet value: at least two distinct characters, all URL unreserved ASCII
+// so keys never need escaping, and no uppercase letters in case-insensitive mode.
+func validateAlphabet(alphabet string) error {
+	if len(alphabet) < 2 {
+		return errors.New("alphabet 至少需要2个字符")
+	}
+
+	seen := map[byte]bool{}
+	for i := 0; i < len(alphabet); i++ {
+		c := alphabet[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '~') {
+			return fmt.Errorf("alphabet 仅支持字母、数字及 -_~，非法字符: %q", alphabet[i:i+1])
+		}
+		if caseInsensitive && c >= 'A' && c <= 'Z' {
+			return errors.New("case-insensitive 模式下 alphabet 不能包含大写字母")
+		}
+		if seen[c] {
+			return fmt.Errorf("alphabet 包含重复字符: %q", c)
+		}
+		seen[c] = true
+	}
+
+	return nil
+}
+
+// normalizeShortKey lowercases key in case-insensitive mode.
+func normalizeShortKey(key string) string {
+	if !caseInsensitive {
+		return key
+	}
+
+	return strings.ToLower(key)
+}
+
+// encodeCounter encodes n with the keyAlphabet alphabet, left padded to length.
+// It fails rather than returning a key longer than requested.
+func encodeCounter(n int64, length int) (string, error) {
+	base := int64(len(keyAlphabet))
+
+	var b []byte
+	for n > 0 {
+		b = append(b, keyAlphabet[n%base])
+		n /= base
+	}
+	if len(b) > length {
+		return "", errCounterOverflow
+	}
+
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+
+	return strings.Repeat(keyAlphabet[:1], length-len(b)) + string(b), nil
+}
+
+// nextCandidate returns the next short key to try according to the key mode.
+func nextCandidate(conn redis.Conn, shortUrlLen int) (string, error) {
+	if keyMode != keyModeCounter {
+		return generate(shortUrlLen)
+	}
+
+	// 含屏蔽词的计数值直接跳过
+	return unblockedKey(func() (string, error) {
+		n, err := redis.Int64(conn.Do("incr", redisKey(defaultCounterKey)))
+		if err != nil {
+			return "", err
+		}
+
+		return encodeCounter(n, shortUrlLen)
+	})
+}
+
+// checkCharacter computes the Luhn mod N check character of key over the keyAlphabet alphabet,
+// which catches any single character substitution and most adjacent transpositions.
+func checkCharacter(key string) byte {
+	n := len(keyAlphabet)
+	factor := 2
+	sum := 0
+	for i := len(key) - 1; i >= 0; i-- {
+		addend := factor * strings.IndexByte(keyAlphabet, key[i])
+		factor = 3 - factor
+		sum += addend/n + addend%n
+	}
+
+	return keyAlphabet[(n-sum%n)%n]
+}
+
+// withChecksum appends the check character to key when checksums are enabled.
+func withChecksum(key string) string {
+	if !enableChecksum {
+		return key
+	}
+
+	return key + string(checkCharacter(key))
+}
+
+// withoutChecksum strips the check character from key when checksums are enabled.
+func withoutChecksum(key string) string {
+	if !enableChecksum || key == "" {
+		return key
+	}
+
+	return key[:len(key)-1]
+}
+
+// validChecksum reports whether the last character of key is its check character.
+// It always succeeds when checksums are disabled.
+func validChecksum(key string) bool {
+	if !enableChecksum {
+		return true
+	}
+	if len(key) < 2 {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if strings.IndexByte(keyAlphabet, key[i]) < 0 {
+			return false
+		}
+	}
+
+	return checkCharacter(key[:len(key)-1]) == key[len(key)-1]
+}
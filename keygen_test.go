@@ -0,0 +1,282 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+func TestChecksum(t *testing.T) {
+	router, _ := newTestRouter(t)
+	setFlag(t, &enableChecksum, true)
+
+	key := mustShorten(t, router, "https://example.com/checked", nil)
+	if len(key) != defaultShortUrlLen+1 || !validChecksum(key) {
+		t.Fatalf("generated key %q without a valid check character", key)
+	}
+	if w := serve(router, http.MethodGet, "/"+key, nil, nil); w.Code != http.StatusMovedPermanently {
+		t.Fatalf("valid key: status %d", w.Code)
+	}
+
+	// 任意单字符替换都能被发现
+	for i := 0; i < len(key); i++ {
+		for j := 0; j < len(keyAlphabet); j++ {
+			if keyAlphabet[j] == key[i] {
+				continue
+			}
+			typo := key[:i] + keyAlphabet[j:j+1] + key[i+1:]
+			if validChecksum(typo) {
+				t.Fatalf("typo %q of %q passed the checksum", typo, key)
+			}
+		}
+	}
+	for i := 0; i+1 < len(key); i++ {
+		if key[i] == key[i+1] {
+			continue
+		}
+		swapped := key[:i] + key[i+1:i+2] + key[i:i+1] + key[i+2:]
+		if w := serve(router, http.MethodGet, "/"+swapped, nil, nil); validChecksum(swapped) && w.Code == http.StatusMovedPermanently {
+			t.Fatalf("transposition %q resolved", swapped)
+		}
+	}
+
+	custom := mustShorten(t, router, "https://example.com/custom", url.Values{"shortKey": {"promo"}})
+	if custom != withChecksum("promo") {
+		t.Fatalf("custom key %q, want %q", custom, withChecksum("promo"))
+	}
+
+	// 恰好以有效校验位结尾的自定义短链接同样追加校验位，与 /available 返回的一致
+	requested := withChecksum("sale")
+	w := serve(router, http.MethodGet, "/available/"+requested, nil, nil)
+	var available AvailableResponse
+	decode(t, w, &available)
+	custom = mustShorten(t, router, "https://example.com/sale", url.Values{"shortKey": {requested}})
+	if custom != withChecksum(requested) || available.ShortKey != custom {
+		t.Fatalf("custom key %q stored as %q, /available reported %q", requested, custom, available.ShortKey)
+	}
+
+	// 导入时保留带校验位的原短链接
+	setFlag(t, &adminToken, "secret-admin")
+	exported := withChecksum("moved")
+	if code, res := importCsv(t, router, exported+",https://example.com/moved\n"); code != http.StatusOK || res.Imported != 1 || len(res.Renamed) != 0 {
+		t.Fatalf("import: status %d, %+v", code, res)
+	}
+	if w := serve(router, http.MethodGet, "/"+exported, nil, nil); w.Header().Get("Location") != "https://example.com/moved" {
+		t.Fatalf("imported key %q: status %d", exported, w.Code)
+	}
+}
+
+func TestCaseInsensitive(t *testing.T) {
+	router, _ := newTestRouter(t)
+	setFlag(t, &caseInsensitive, true)
+	setFlag(t, &keyAlphabet, letterBytes[:36])
+
+	key := mustShorten(t, router, "https://example.com/case", url.Values{"shortKey": {"ABC"}})
+	if key != "abc" {
+		t.Fatalf("custom key stored as %q", key)
+	}
+	for _, target := range []string{"/ABC", "/abc", "/AbC"} {
+		if got := serve(router, http.MethodGet, target, nil, nil).Header().Get("Location"); got != "https://example.com/case" {
+			t.Errorf("%s: Location %q", target, got)
+		}
+	}
+
+	generated := mustShorten(t, router, "https://example.com/generated", nil)
+	if generated != strings.ToLower(generated) {
+		t.Fatalf("generated key %q has uppercase letters", generated)
+	}
+}
+
+func TestCustomAlphabet(t *testing.T) {
+	router, _ := newTestRouter(t)
+	const alphabet = "23456789abcdefghjkmnpqrstuvwxyz"
+	if err := validateAlphabet(alphabet); err != nil {
+		t.Fatal(err)
+	}
+	setFlag(t, &keyAlphabet, alphabet)
+
+	for i := 0; i < 20; i++ {
+		key := mustShorten(t, router, "https://example.com/"+strconv.Itoa(i), nil)
+		if strings.Trim(key, alphabet) != "" {
+			t.Fatalf("key %q uses characters outside the alphabet", key)
+		}
+	}
+	if code, res := shorten(t, router, "https://example.com/x", url.Values{"shortKey": {"abc1"}}); code != http.StatusBadRequest {
+		t.Fatalf("custom key outside the alphabet: status %d, %+v", code, res)
+	}
+
+	for _, bad := range []string{"", "a", "aab", "ab/", "ab😀"} {
+		if err := validateAlphabet(bad); err == nil {
+			t.Errorf("alphabet %q accepted", bad)
+		}
+	}
+	setFlag(t, &caseInsensitive, true)
+	if err := validateAlphabet("abC"); err == nil {
+		t.Error("uppercase alphabet accepted in case-insensitive mode")
+	}
+}
+
+func TestCounterMode(t *testing.T) {
+	router, mr := newTestRouter(t)
+	setFlag(t, &keyMode, keyModeCounter)
+	setFlag(t, &keyAlphabet, "ab")
+
+	if key := mustShorten(t, router, "https://example.com/1", url.Values{"shortUrlLen": {"1"}}); key != "b" {
+		t.Fatalf("first counter key %q, want b", key)
+	}
+	code, res := shorten(t, router, "https://example.com/2", url.Values{"shortUrlLen": {"1"}})
+	if code != http.StatusBadRequest || res.Message != errCounterOverflow.Error() {
+		t.Fatalf("counter past the length boundary: status %d, %+v", code, res)
+	}
+	if got, _ := mr.Get(defaultCounterKey); got != "2" {
+		t.Fatalf("counter %q", got)
+	}
+	if key := mustShorten(t, router, "https://example.com/3", url.Values{"shortUrlLen": {"2"}}); key != "bb" {
+		t.Fatalf("counter key %q, want bb", key)
+	}
+}
+
+func TestReadableKeys(t *testing.T) {
+	router, _ := newTestRouter(t)
+	readable, first, err := readableAlphabets(letterBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setFlag(t, &readableAlphabet, readable)
+	setFlag(t, &readableFirstAlphabet, first)
+
+	for i := 0; i < 50; i++ {
+		key := mustShorten(t, router, "https://example.com/"+strconv.Itoa(i), nil)
+		if !unicode.IsLetter(rune(key[0])) {
+			t.Fatalf("key %q starts with %q", key, key[0])
+		}
+		if strings.ContainsAny(key, ambiguousChars) {
+			t.Fatalf("key %q contains ambiguous characters", key)
+		}
+	}
+
+	// 自定义短链接不受影响
+	if key := mustShorten(t, router, "https://example.com/custom", url.Values{"shortKey": {"0day"}}); key != "0day" {
+		t.Fatalf("custom key %q", key)
+	}
+	if _, _, err := readableAlphabets("01lI"); err == nil {
+		t.Fatal("alphabet with only ambiguous characters accepted")
+	}
+}
+
+func TestBlocklist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	if err := os.WriteFile(path, []byte("# offensive\nAB\n\n ba \n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := loadBlocklist(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(tokens, ",") != "ab,ba" {
+		t.Fatalf("tokens %v", tokens)
+	}
+	setFlag(t, &blockedTokens, tokens)
+	setFlag(t, &keyAlphabet, "abc")
+
+	setFlag(t, &enableChecksum, true)
+
+	for i := 0; i < 1000; i++ {
+		if key, err := generate(4); err != nil || keyBlocked(key) || !validChecksum(key) {
+			t.Fatalf("generated key %q, %v", key, err)
+		}
+	}
+
+	// 屏蔽词检查作用于追加校验位后的最终短链接
+	if keyBlocked("bb") || !keyBlocked(withChecksum("bb")) {
+		t.Fatalf("precondition: check character of bb should form a blocked token, got %q", withChecksum("bb"))
+	}
+	drawn := []string{"bb", "cc"}
+	key, err := unblockedKey(func() (string, error) {
+		next := drawn[0]
+		drawn = drawn[1:]
+		return next, nil
+	})
+	if err != nil || key != withChecksum("cc") {
+		t.Fatalf("unblockedKey = %q, %v, want %q", key, err, withChecksum("cc"))
+	}
+}
+
+func TestBlocklistExhausted(t *testing.T) {
+	router, mr := newTestRouter(t)
+	setFlag(t, &adminToken, "secret-admin")
+	setFlag(t, &keyAlphabet, "abc")
+	existing := mustShorten(t, router, "https://example.com/existing", nil)
+	setFlag(t, &blockedTokens, []string{"a", "b", "c"})
+
+	if key, err := generate(4); !errors.Is(err, errKeyBlocked) {
+		t.Fatalf("generate with every key blocked = %q, %v", key, err)
+	}
+
+	// 随机、私密、计数模式与重新生成均放弃而不是死循环或返回屏蔽词
+	check := func(name string, w *httptest.ResponseRecorder) {
+		t.Helper()
+		var res Response
+		decode(t, w, &res)
+		if w.Code != http.StatusInternalServerError || res.Message != errKeyBlocked.Error() {
+			t.Fatalf("%s: status %d, %+v", name, w.Code, res)
+		}
+	}
+	check("random", serve(router, http.MethodPost, "/short", updateForm("https://example.com/random"), nil))
+	private := updateForm("https://example.com/private")
+	private.Set("private", "1")
+	check("private", serve(router, http.MethodPost, "/short", private, nil))
+	check("rekey", serve(router, http.MethodPost, "/admin/rekey/"+existing, nil, bearer(adminToken)))
+
+	setFlag(t, &keyMode, keyModeCounter)
+	check("counter", serve(router, http.MethodPost, "/short", updateForm("https://example.com/counter"), nil))
+	if got, _ := mr.Get(defaultCounterKey); got != strconv.Itoa(maxBlockedRegenerations+1) {
+		t.Fatalf("counter %q after skipping blocked values", got)
+	}
+}
+
+func TestCounterModeSkipsBlockedKeys(t *testing.T) {
+	router, _ := newTestRouter(t)
+	setFlag(t, &keyMode, keyModeCounter)
+	setFlag(t, &keyAlphabet, "abc")
+	setFlag(t, &blockedTokens, []string{"ab"})
+
+	if key := mustShorten(t, router, "https://example.com/1", url.Values{"shortUrlLen": {"2"}}); key != "ac" {
+		t.Fatalf("first counter key %q, want ac", key)
+	}
+}
+
+func TestShortUrlLenFor(t *testing.T) {
+	setFlag(t, &saturationThreshold, 0.5)
+	setFlag(t, &keyAlphabet, "ab")
+
+	for _, tc := range []struct {
+		count int64
+		want  int
+	}{
+		{0, defaultShortUrlLen},
+		{32, defaultShortUrlLen},
+		{33, defaultShortUrlLen + 1},
+		{1 << 40, maxShortUrlLen},
+	} {
+		if got := shortUrlLenFor(tc.count); got != tc.want {
+			t.Errorf("shortUrlLenFor(%d) = %d, want %d", tc.count, got, tc.want)
+		}
+	}
+}
+
+func TestSaturatedKeyspaceGeneratesLongerKeys(t *testing.T) {
+	router, _ := newTestRouter(t)
+	scaledShortUrlLen.Store(defaultShortUrlLen + 2)
+
+	if key := mustShorten(t, router, "https://example.com/saturated", nil); len(key) != defaultShortUrlLen+2 {
+		t.Fatalf("key %q, want length %d", key, defaultShortUrlLen+2)
+	}
+}
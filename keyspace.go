@@ -0,0 +1,64 @@
+package main
+
+import (
+	"log"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// keyspaceSampleInterval is how often the number of stored keys is sampled.
+const keyspaceSampleInterval = time.Minute
+
+// saturationThreshold is the fraction of the keyspace of the default length that may be used, zero to disable scaling.
+// Scaling is opt-in: the key count is sampled with DBSIZE, which also counts the meta, counter, referrer,
+// visitor and index keys stored next to every link, so the threshold has to leave room for them.
+var saturationThreshold float64
+
+// scaledShortUrlLen is the default length of generated keys adjusted to the keyspace saturation.
+var scaledShortUrlLen atomic.Int64
+
+func init() {
+	scaledShortUrlLen.Store(defaultShortUrlLen)
+}
+
+// currentShortUrlLen returns the length used for generated keys when shortUrlLen isn't given.
+func currentShortUrlLen() int {
+	return int(scaledShortUrlLen.Load())
+}
+
+// shortUrlLenFor returns the smallest key length from defaultShortUrlLen on at which count stored keys
+// stay below the saturation threshold of the keyspace.
+func shortUrlLenFor(count int64) int {
+	length := defaultShortUrlLen
+	for length < maxShortUrlLen && float64(count) > saturationThreshold*math.Pow(float64(len(keyAlphabet)), float64(length)) {
+		length++
+	}
+
+	return length
+}
+
+// 定期采样键数量，键空间接近饱和时增大默认 key 长度，降低冲突重试
+func watchKeyspace() {
+	sample := func() {
+		redisClient := redisPool.Get()
+		defer redisClient.Close()
+
+		// DBSIZE 含每个链接的多个辅助键，高估链接数量
+		count, err := redis.Int64(redisClient.Do("dbsize"))
+		if err != nil {
+			return
+		}
+		length := shortUrlLenFor(count)
+		if previous := scaledShortUrlLen.Swap(int64(length)); previous != int64(length) {
+			log.Printf("Keyspace holds %d keys, default short key length is now %d", count, length)
+		}
+	}
+
+	sample()
+	for range time.Tick(keyspaceSampleInterval) {
+		sample()
+	}
+}
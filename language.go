@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxLanguages is the maximum number of language specific destinations of a short link.
+const maxLanguages = 20
+
+// errInvalidLanguages is returned when the languages parameter is not a JSON object of language tags to URLs.
+var errInvalidLanguages = errors.New("languages格式错误，应为语言标签到长链接的JSON对象")
+
+// languageTagPattern matches the language tags accepted as destination keys, such as en, zh or zh-tw.
+var languageTagPattern = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{1,8})*$`)
+
+// parseLanguages parses the languages parameter of /short into its canonical JSON encoding,
+// validating every destination like the default long URL. Tags are stored lowercase.
+func parseLanguages(raw string) (string, error) {
+	params := map[string]string{}
+	if err := json.Unmarshal([]byte(raw), &params); err != nil {
+		return "", errInvalidLanguages
+	}
+	if len(params) > maxLanguages {
+		return "", fmt.Errorf("languages最多包含%d种语言", maxLanguages)
+	}
+
+	destinations := make(map[string]string, len(params))
+	for tag, longUrl := range params {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if !languageTagPattern.MatchString(tag) {
+			return "", fmt.Errorf("languages语言标签无效: %q", tag)
+		}
+		if err := validateLongUrl(longUrl); err != nil {
+			return "", fmt.Errorf("languages中 %s 的长链接无效: %v", tag, err)
+		}
+		destinations[tag] = longUrl
+	}
+	if len(destinations) == 0 {
+		return "", nil
+	}
+
+	// map 按键排序编码，相同参数得到相同的存储值
+	encoded, _ := json.Marshal(destinations)
+
+	return string(encoded), nil
+}
+
+// linkLanguages decodes the languages metadata field, nil when the link has none.
+func linkLanguages(meta map[string]string) map[string]string {
+	if meta["languages"] == "" {
+		return nil
+	}
+
+	destinations := map[string]string{}
+	if err := json.Unmarshal([]byte(meta["languages"]), &destinations); err != nil {
+		return nil
+	}
+
+	return destinations
+}
+
+// matchLanguage picks the destination of the most preferred language of an Accept-Language header.
+// A language matches its exact tag first, then its primary subtag, so zh-CN falls back to zh.
+func matchLanguage(destinations map[string]string, acceptLanguage string) (string, bool) {
+	for _, tag := range acceptedLanguages(acceptLanguage) {
+		if destination, ok := destinations[tag]; ok {
+			return destination, true
+		}
+		if primary, _, found := strings.Cut(tag, "-"); found {
+			if destination, ok := destinations[primary]; ok {
+				return destination, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// acceptedLanguages returns the lowercase tags of an Accept-Language header by descending quality,
+// keeping the header order between equal qualities and dropping wildcards and q=0.
+func acceptedLanguages(header string) []string {
+	type language struct {
+		tag     string
+		quality float64
+	}
+
+	var languages []language
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if value, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if q, err := strconv.ParseFloat(value, 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		if quality <= 0 {
+			continue
+		}
+		languages = append(languages, language{tag: tag, quality: quality})
+	}
+	sort.SliceStable(languages, func(i, j int) bool {
+		return languages[i].quality > languages[j].quality
+	})
+
+	tags := make([]string, len(languages))
+	for i, l := range languages {
+		tags[i] = l.tag
+	}
+
+	return tags
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestLanguageDestinations(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	key := mustShorten(t, router, "https://example.com/default", url.Values{
+		"languages": {`{"zh":"https://example.com/zh","EN-gb":"https://example.com/en-gb"}`},
+	})
+	for _, tc := range []struct {
+		acceptLanguage string
+		want           string
+	}{
+		{"zh-CN,zh;q=0.9", "https://example.com/zh"},
+		{"en-GB", "https://example.com/en-gb"},
+		{"fr;q=1, zh;q=0.5", "https://example.com/zh"},
+		{"fr", "https://example.com/default"},
+		{"", "https://example.com/default"},
+	} {
+		w := serve(router, http.MethodGet, "/"+key, nil, http.Header{"Accept-Language": {tc.acceptLanguage}})
+		if got := w.Header().Get("Location"); got != tc.want {
+			t.Errorf("Accept-Language %q: Location %q, want %q", tc.acceptLanguage, got, tc.want)
+		}
+		if w.Header().Get("Vary") != "Accept-Language" {
+			t.Errorf("Accept-Language %q: Vary %q", tc.acceptLanguage, w.Header().Get("Vary"))
+		}
+	}
+
+	plain := mustShorten(t, router, "https://example.com/plain", nil)
+	if w := serve(router, http.MethodGet, "/"+plain, nil, nil); w.Header().Get("Vary") != "" {
+		t.Fatalf("Vary %q on a link without languages", w.Header().Get("Vary"))
+	}
+
+	for _, raw := range []string{"[]", `{"english":"https://example.com/"}`, `{"en":"javascript:alert(1)"}`} {
+		if code, _ := shorten(t, router, "https://example.com/bad", url.Values{"languages": {raw}}); code != http.StatusBadRequest {
+			t.Errorf("languages %s: status %d, want 400", raw, code)
+		}
+	}
+}
+
+func TestAcceptedLanguages(t *testing.T) {
+	got := acceptedLanguages("en;q=0.5, *, zh-TW, de;q=0, fr;q=0.5")
+	if want := []string{"zh-tw", "en", "fr"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("acceptedLanguages = %v, want %v", got, want)
+	}
+}
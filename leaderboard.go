@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gomodule/redigo/redis"
+)
+
+// defaultLeaderboardKey is the Redis sorted set of short keys scored by their hits.
+const defaultLeaderboardKey = "myurls:leaderboard"
+
+// defaultTopN is the default number of links returned by the top endpoint.
+const defaultTopN = 10
+
+// maxTopN is the maximum number of links returned by the top endpoint.
+const maxTopN = 100
+
+// TopLink is a short key ranked by its hits.
+type TopLink struct {
+	ShortKey string
+	LongUrl  string
+	Hits     int64
+}
+
+// TopResponse is the response structure of the top links endpoint.
+type TopResponse struct {
+	Code    int
+	Message string
+	Links   []TopLink
+}
+
+// 访问次数最多的短链接
+func topHandler(context *gin.Context) {
+	res := &TopResponse{Code: 1, Links: []TopLink{}}
+
+	n := defaultTopN
+	if nStr := context.Query("n"); nStr != "" {
+		_n, err := strconv.Atoi(nStr)
+		if err != nil || _n < 1 || _n > maxTopN {
+			res.Code = 0
+			res.Message = "n必须为1到" + strconv.Itoa(maxTopN) + "之间的整数"
+			context.JSON(http.StatusBadRequest, *res)
+			return
+		}
+		n = _n
+	}
+
+	redisClient := redisPool.Get()
+	defer redisClient.Close()
+
+	// 已删除或过期的链接在读取时清理，清理后继续向后读取补足 n 个
+	for start := 0; len(res.Links) < n; {
+		entries, err := redis.Strings(redisClient.Do("zrevrange", redisKey(defaultLeaderboardKey), start, start+n-1, "withscores"))
+		if err != nil {
+			res.Code = 0
+			res.Message = storageError(err).Error()
+			context.JSON(http.StatusInternalServerError, *res)
+			return
+		}
+		if len(entries) == 0 {
+			break
+		}
+
+		for i := 0; i+1 < len(entries); i += 2 {
+			shortKey := entries[i]
+			longUrl, _ := redis.String(redisClient.Do("get", redisKey(shortKey)))
+			if longUrl == "" {
+				_, _ = redisClient.Do("zrem", redisKey(defaultLeaderboardKey), shortKey)
+				continue
+			}
+			start++
+			if len(res.Links) < n && !linkUnlisted(redisClient, shortKey) {
+				hits, _ := strconv.ParseInt(entries[i+1], 10, 64)
+				res.Links = append(res.Links, TopLink{ShortKey: shortKey, LongUrl: longUrl, Hits: hits})
+			}
+		}
+	}
+
+	context.JSON(http.StatusOK, *res)
+}
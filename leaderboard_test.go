@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestTopLinks(t *testing.T) {
+	router, _ := newTestRouter(t)
+	setFlag(t, &adminToken, "secret-admin")
+
+	hits := map[string]int{"one": 1, "three": 3, "two": 2}
+	for key, n := range hits {
+		mustShorten(t, router, "https://example.com/"+key, url.Values{"shortKey": {key}})
+		for i := 0; i < n; i++ {
+			serve(router, http.MethodGet, "/"+key, nil, nil)
+		}
+	}
+	private := mustShorten(t, router, "https://example.com/private", url.Values{"private": {"true"}})
+	for i := 0; i < 5; i++ {
+		serve(router, http.MethodGet, "/"+private, nil, nil)
+	}
+
+	w := serve(router, http.MethodGet, "/admin/top?n=2", nil, bearer(adminToken))
+	var res TopResponse
+	decode(t, w, &res)
+	if w.Code != http.StatusOK || len(res.Links) != 2 {
+		t.Fatalf("status %d, %+v", w.Code, res)
+	}
+	if res.Links[0].ShortKey != "three" || res.Links[0].Hits != 3 || res.Links[1].ShortKey != "two" {
+		t.Fatalf("ranking %+v", res.Links)
+	}
+
+	if w := serve(router, http.MethodGet, "/admin/top?n=0", nil, bearer(adminToken)); w.Code != http.StatusBadRequest {
+		t.Fatalf("n=0: status %d, want 400", w.Code)
+	}
+}
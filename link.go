@@ -0,0 +1,234 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// defaultHitsPrefix is the default prefix for Redis click counters.
+const defaultHitsPrefix = "myurls:hits:"
+
+// errLinkDisabled is returned when an operator disabled a link.
+var errLinkDisabled = errors.New("该链接已被禁用")
+
+// errLinkExhausted is returned when a link already reached its maxClicks.
+var errLinkExhausted = errors.New("短链接访问次数已用完")
+
+// errClicksUncounted is returned when the click counter of a link can't be incremented.
+var errClicksUncounted = errors.New("暂时无法记录访问次数，请稍后重试")
+
+// redirectMetaFields are the meta hash fields read by resolveLink, everything the redirect path needs.
+var redirectMetaFields = []string{"maxClicks", "disabled", "hmac", "requireAuth", "utm", "platforms", "languages", "destinationsHmac", "redirectStatus"}
+
+// resolvedLink is a short link as read by resolveLink: its long URL, remaining ttl in milliseconds,
+// maxClicks and the redirectMetaFields of its meta hash.
+type resolvedLink struct {
+	longUrl   string
+	pttl      int64
+	maxClicks int64
+	meta      map[string]string
+}
+
+// requireAuth reports whether the link is private.
+func (link resolvedLink) requireAuth() bool {
+	return link.meta["requireAuth"] == "1"
+}
+
+// resolveLink fetches the long URL, ttl and redirectMetaFields of a short key in a single pipelined round trip,
+// or serves the whole link from the in-process cache without touching Redis. A missing key yields an empty
+// long URL, a disabled one errLinkDisabled and one failing the integrity check errLinkTampered; both still
+// return the metadata read, so callers can check requireAuth before revealing the link state.
+func resolveLink(conn redis.Conn, shortKey string) (resolvedLink, error) {
+	if link, ok := linkCache.get(shortKey); ok {
+		return link, nil
+	}
+	link := resolvedLink{meta: map[string]string{}}
+	// Redis 不可用时以缓存中未过期的链接降级跳转
+	fail := func(err error) (resolvedLink, error) {
+		if err = storageError(err); degradedRead(err) {
+			if stale, ok := linkCache.getStale(shortKey); ok {
+				return stale, nil
+			}
+		}
+		return link, err
+	}
+
+	_ = conn.Send("get", redisKey(shortKey))
+	_ = conn.Send("pttl", redisKey(shortKey))
+	args := redis.Args{}.Add(redisKey(defaultMetaPrefix + shortKey)).AddFlat(redirectMetaFields)
+	_ = conn.Send("hmget", args...)
+	if err := conn.Flush(); err != nil {
+		return fail(err)
+	}
+
+	longUrl, err := redis.String(conn.Receive())
+	if err != nil && err != redis.ErrNil {
+		return fail(err)
+	}
+	pttl, err := redis.Int64(conn.Receive())
+	if err != nil {
+		return fail(err)
+	}
+	fields, err := redis.Strings(conn.Receive())
+	if err != nil {
+		return fail(err)
+	}
+	for i, field := range redirectMetaFields {
+		if fields[i] != "" {
+			link.meta[field] = fields[i]
+		}
+	}
+
+	if longUrl != "" && link.meta["disabled"] == "1" {
+		return link, errLinkDisabled
+	}
+	if err := verifyLink(shortKey, longUrl, link.meta["hmac"]); err != nil {
+		return link, err
+	}
+	link.longUrl = longUrl
+	link.pttl = pttl
+	link.maxClicks, _ = strconv.ParseInt(link.meta["maxClicks"], 10, 64)
+
+	// 缓存不超过短链接剩余有效期，过期链接不会从内存中跳转
+	// 私有、限次与带 utm 的链接不缓存，每次访问都需读取最新的计数与元数据
+	if longUrl != "" && pttl != -2 && link.maxClicks == 0 && !link.requireAuth() && link.meta["utm"] == "" {
+		linkCache.add(shortKey, link, time.Duration(pttl)*time.Millisecond)
+	}
+
+	return link, nil
+}
+
+// trackVisit records a redirect of a resolved short key: its click counter, the leaderboard, the daily unique
+// visitors, the referring domain and the renewal lock are written in one pipelined round trip. The writes that
+// depend on those replies, syncing the expiry on the first click, renewing, capping the referrers and deleting
+// a link at its maxClicks, only take further round trips when they apply.
+// It returns errLinkExhausted for clicks beyond the limit and errClicksUncounted when the counter wasn't incremented.
+func trackVisit(conn redis.Conn, shortKey string, link resolvedLink, clientIP string, referer string) error {
+	ipHash := sha256.Sum256([]byte(clientIP))
+	uniq := uniqKey(shortKey, time.Now())
+	refKey := redisKey(defaultRefPrefix + shortKey)
+	host := referrerHost(referer)
+	// -1: 永久有效，无需续命；-2: key 不存在，不续命
+	renewing := !noRenew && link.pttl > 0
+
+	_ = conn.Send("incr", redisKey(defaultHitsPrefix+shortKey))
+	_ = conn.Send("zincrby", redisKey(defaultLeaderboardKey), 1, shortKey)
+	_ = conn.Send("pfadd", uniq, hex.EncodeToString(ipHash[:16]))
+	_ = conn.Send("expire", uniq, uniqRetentionDays*secondsPerDay)
+	_ = conn.Send("hlen", refKey)
+	_ = conn.Send("hincrby", refKey, host, 1)
+	sent := 6
+	if renewing {
+		// 加锁，防止续命窗口内多次续命
+		_ = conn.Send("set", redisKey(defaultLockPrefix+shortKey), 1, "nx", "ex", renewLockHours*3600)
+		sent++
+	}
+	if err := conn.Flush(); err != nil {
+		return fmt.Errorf("%w: %v", errClicksUncounted, storageError(err))
+	}
+	replies := make([]interface{}, sent)
+	for i := range replies {
+		reply, err := conn.Receive()
+		if err != nil && i == 0 {
+			return fmt.Errorf("%w: %v", errClicksUncounted, storageError(err))
+		} else if err != nil {
+			return storageError(err)
+		}
+		replies[i] = reply
+	}
+	hits, _ := redis.Int64(replies[0], nil)
+	refCount, _ := redis.Int(replies[4], nil)
+	refHits, _ := redis.Int64(replies[5], nil)
+	renewed := false
+	if renewing {
+		lock, _ := redis.String(replies[6], nil)
+		renewed = lock == "OK"
+	}
+
+	if link.maxClicks > 0 {
+		if hits > link.maxClicks {
+			return errLinkExhausted
+		}
+		if hits == link.maxClicks {
+			// 最后一次访问，跳转后删除链接，墓碑保留用于返回 410
+			if err := deleteLink(conn, shortKey, link.longUrl); err != nil {
+				return storageError(err)
+			}
+			return nil
+		}
+	}
+
+	// 超过上限的新来源域名改计入 other，并发访问下可能略微超出上限，不影响防止无限增长
+	if refHits == 1 && refCount >= maxReferrers && host != otherReferrer {
+		if _, err := conn.Do("hdel", refKey, host); err != nil {
+			return storageError(err)
+		}
+		if _, err := conn.Do("hincrby", refKey, otherReferrer, 1); err != nil {
+			return storageError(err)
+		}
+	}
+	// 获取到长链接后，续命1天。每天仅允许续命1次。
+	if renewed {
+		if _, err := conn.Do("pexpire", redisKey(shortKey), link.pttl+int64(renewalDays*secondsPerDay)*1000); err != nil {
+			return storageError(err)
+		}
+	}
+	// 首次访问创建的计数器与来源哈希，以及续命后的有效期，同步到元数据与过期索引
+	if hits == 1 || refCount == 0 || renewed {
+		if err := syncLinkExpiry(conn, shortKey); err != nil {
+			return storageError(err)
+		}
+	}
+
+	return nil
+}
+
+// linkHits returns the number of times a short key was resolved.
+func linkHits(conn redis.Conn, shortKey string) int64 {
+	hits, _ := redis.Int64(conn.Do("get", redisKey(defaultHitsPrefix+shortKey)))
+
+	return hits
+}
+
+// deleteLink removes a short key with its metadata, counters and md5 reverse mapping.
+// The tombstone is kept so the key keeps answering 410.
+func deleteLink(conn redis.Conn, shortKey string, longUrl string) error {
+	md5Key := redisKey(defaultMd5Prefix + longUrlHash(longUrl))
+	if mapped, _ := redis.String(conn.Do("get", md5Key)); mapped == shortKey {
+		if _, err := conn.Do("del", md5Key); err != nil {
+			return err
+		}
+	}
+
+	// 标签集合无法随链接过期，删除时尽力清理
+	meta, _ := redis.StringMap(conn.Do("hgetall", redisKey(defaultMetaPrefix+shortKey)))
+	if err := untagLink(conn, shortKey, linkTags(meta)); err != nil {
+		return err
+	}
+
+	if _, err := conn.Do("del",
+		redisKey(shortKey),
+		redisKey(defaultMetaPrefix+shortKey),
+		redisKey(defaultHitsPrefix+shortKey),
+		redisKey(defaultRefPrefix+shortKey),
+		redisKey(defaultLockPrefix+shortKey),
+		redisKey(defaultNotifiedPrefix+shortKey),
+	); err != nil {
+		return err
+	}
+	linkCache.remove(shortKey)
+
+	if _, err := conn.Do("zrem", redisKey(defaultExpiryKey), shortKey); err != nil {
+		return err
+	}
+
+	_, err := conn.Do("zrem", redisKey(defaultLeaderboardKey), shortKey)
+
+	return err
+}
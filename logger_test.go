@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestParseLogLevel(t *testing.T) {
+	for raw, want := range map[string]logrus.Level{
+		"debug":   logrus.DebugLevel,
+		"INFO":    logrus.InfoLevel,
+		"warn":    logrus.WarnLevel,
+		"warning": logrus.WarnLevel,
+		"error":   logrus.ErrorLevel,
+	} {
+		if got, err := parseLogLevel(raw); err != nil || got != want {
+			t.Errorf("parseLogLevel(%q) = %v, %v, want %v", raw, got, err, want)
+		}
+	}
+	if _, err := parseLogLevel("verbose"); err == nil {
+		t.Fatal("unknown level accepted")
+	}
+}
+
+func TestLogger(t *testing.T) {
+	logger, err := Logger("", logrus.WarnLevel)
+	if err != nil || logger.Out != os.Stdout || logger.GetLevel() != logrus.WarnLevel {
+		t.Fatalf("stdout logger: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "nested", "access.log")
+	logger, err = Logger(path, logrus.InfoLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Debug("hidden")
+	logger.WithField("reqUri", "/abc").Info()
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), `"reqUri":"/abc"`) || strings.Contains(string(data), "hidden") {
+		t.Fatalf("log file %q", data)
+	}
+
+	if _, err := Logger(filepath.Join(path, "file"), logrus.InfoLevel); err == nil {
+		t.Fatal("log file below a regular file accepted")
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gomodule/redigo/redis"
+)
+
+// LookupResponse is the response structure of the lookup endpoint.
+type LookupResponse struct {
+	Code     int
+	Message  string
+	LongUrl  string
+	ShortKey string
+	ShortUrl string
+	TTL      int
+}
+
+// 查询长链接对应的已有短链接，不创建
+func lookupHandler(context *gin.Context) {
+	res := &LookupResponse{Code: 1}
+
+	longUrl, err := decodeLongUrl(context.Query("longUrl"))
+	if err != nil {
+		res.Code = 0
+		res.Message = err.Error()
+		context.JSON(http.StatusBadRequest, *res)
+		return
+	}
+	res.LongUrl = longUrl
+
+	redisClient := redisPool.Get()
+	defer redisClient.Close()
+
+	shortKey, _ := redis.String(redisClient.Do("get", redisKey(defaultMd5Prefix+longUrlHash(res.LongUrl))))
+	ttl, _ := redis.Int(redisClient.Do("ttl", redisKey(shortKey)))
+	if shortKey == "" || ttl == -2 {
+		res.Code = 0
+		res.Message = "未找到"
+		context.JSON(http.StatusNotFound, *res)
+		return
+	}
+
+	res.ShortKey = shortKey
+	res.ShortUrl = buildShortUrl(context, shortKey)
+	res.TTL = ttl
+
+	context.JSON(http.StatusOK, *res)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+// lookup queries the existing short link of longUrl.
+func lookup(t testing.TB, router http.Handler, longUrl string) (int, LookupResponse) {
+	t.Helper()
+	w := serve(router, http.MethodGet, "/lookup?longUrl="+url.QueryEscape(base64.StdEncoding.EncodeToString([]byte(longUrl))), nil, nil)
+	var res LookupResponse
+	decode(t, w, &res)
+	return w.Code, res
+}
+
+func TestLookup(t *testing.T) {
+	router, mr := newTestRouter(t)
+
+	if code, _ := lookup(t, router, "https://example.com/none"); code != http.StatusNotFound {
+		t.Fatalf("unknown long URL: status %d, want 404", code)
+	}
+
+	key := mustShorten(t, router, "https://example.com/found", nil)
+	code, res := lookup(t, router, "https://example.com/found")
+	if code != http.StatusOK || res.ShortKey != key || res.ShortUrl != "https://s.test/"+key || res.TTL <= 0 {
+		t.Fatalf("existing long URL: status %d, %+v", code, res)
+	}
+	if mr.Exists(defaultHitsPrefix + key) {
+		t.Fatal("lookup counted a click")
+	}
+
+	// 短链接已过期，残留的映射不再返回
+	mr.Del(key)
+	if code, _ := lookup(t, router, "https://example.com/found"); code != http.StatusNotFound {
+		t.Fatalf("expired short link: status %d, want 404", code)
+	}
+
+	if w := serve(router, http.MethodGet, "/lookup?longUrl=%%%", nil, nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("undecodable long URL: status %d, want 400", w.Code)
+	}
+}
@@ -1,17 +1,26 @@
 package main
 
 import (
-	"crypto/md5"
+	stdcontext "context"
+	"crypto/hmac"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"math"
 	"math/rand"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
-	"path"
+	"os/signal"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -21,10 +30,23 @@ import (
 
 // Response is the response structure
 type Response struct {
-	Code     int
-	Message  string
-	LongUrl  string
-	ShortUrl string
+	Code      int
+	Message   string
+	LongUrl   string
+	ShortUrl  string
+	RequestID string
+
+	// ShortUrlInsecure is the http variant of ShortUrl, only set with -return-both.
+	ShortUrlInsecure string `json:",omitempty"`
+
+	// Title is the page title of the long URL, only set with -fetch-title.
+	Title string `json:",omitempty"`
+
+	// Candidates lists the uncommitted short keys generated for a count request.
+	Candidates []string `json:",omitempty"`
+
+	// QrDataUri is a PNG data URI of the QR code of ShortUrl, only set when includeQr is requested.
+	QrDataUri string `json:",omitempty"`
 }
 
 // redisPoolConf is the Redis pool configuration.
@@ -32,9 +54,11 @@ type redisPoolConf struct {
 	maxIdle        int
 	maxActive      int
 	maxIdleTimeout int
+	network        string
 	host           string
 	password       string
 	db             int
+	useTLS         bool
 	handleTimeout  int
 }
 
@@ -50,307 +74,1334 @@ const minShortUrlLen = 1
 // maxShortUrlLen is the maximum length of the generated short URL.
 const maxShortUrlLen = 20
 
+// defaultGenerateRetries is the default number of attempts to generate a free short key.
+const defaultGenerateRetries = 3
+
+// defaultMaxUrlLen is the default maximum length in bytes of a decoded long URL.
+const defaultMaxUrlLen = 2048
+
 // defaultPort is the default port number.
 const defaultPort int = 8002
 
 // defaultExpire is the redis ttl in days for a short URL.
 const defaultExpire = 180
 
+// maxCandidates is the maximum number of candidate short keys generated in one request.
+const maxCandidates = 5
+
+// maxTTL is the longest accepted link lifetime in seconds.
+const maxTTL = 36500 * secondsPerDay
+
+// defaultTemplates is the default glob of the HTML page templates.
+const defaultTemplates = "public/*.html"
+
+// defaultLogFile is the default path of the access log.
+const defaultLogFile = "logs/access.log"
+
 // defaultRedisConfig is the default Redis configuration.
 const defaultRedisConfig = "127.0.0.1:6379"
 
+// defaultRedisMaxIdle is the default maximum number of idle Redis connections.
+const defaultRedisMaxIdle = 1024
+
+// defaultRedisMaxActive is the default maximum number of open Redis connections.
+const defaultRedisMaxActive = 1024
+
+// defaultRedisIdleTimeout is the default time in seconds after which idle Redis connections are closed.
+const defaultRedisIdleTimeout = 30
+
+// defaultRedisHandleTimeout is the default connect, read and write timeout in seconds of Redis connections.
+const defaultRedisHandleTimeout = 30
+
+// defaultReadTimeout is the default time allowed to read a whole request.
+const defaultReadTimeout = 10 * time.Second
+
+// defaultReadHeaderTimeout is the default time allowed to read the request headers.
+const defaultReadHeaderTimeout = 5 * time.Second
+
+// defaultWriteTimeout is the default time allowed to write a response.
+const defaultWriteTimeout = 15 * time.Second
+
+// defaultIdleTimeout is the default time an idle keep-alive connection is kept open.
+const defaultIdleTimeout = 60 * time.Second
+
+// defaultTrustedProxies are the proxies trusted to set X-Forwarded-For by default, loopback only.
+const defaultTrustedProxies = "127.0.0.1/32,::1/128"
+
+// defaultShutdownTimeout bounds how long in-flight requests may take to finish on shutdown.
+const defaultShutdownTimeout = 10 * time.Second
+
 // defaultLockPrefix is the default prefix for Redis locks.
 const defaultLockPrefix = "myurls:lock:"
 
-// defaultMd5Prefix is the default prefix for Redis md5.
+// defaultMd5Prefix is the default prefix for the Redis reverse mappings from long URL digests to short keys.
 const defaultMd5Prefix = "myurls:md5:"
 
-// defaultRenewalDay is the default renewal day for Redis locks.
+// defaultRenewalDay is the default number of days added to a short key on renewal.
 const defaultRenewalDay = 1
 
+// defaultRenewLockHours is the default window in hours during which a short key is renewed at most once.
+const defaultRenewLockHours = 24
+
 // secondsPerDay is the number of seconds in a day.
 const secondsPerDay = 24 * 3600
 
+// reservedKeys are the short keys that would be shadowed by a route.
+var reservedKeys = map[string]struct{}{
+	"short":       {},
+	"healthz":     {},
+	"metrics":     {},
+	"admin":       {},
+	"qr":          {},
+	"api":         {},
+	"stats":       {},
+	"lookup":      {},
+	"renew":       {},
+	"available":   {},
+	"version":     {},
+	"public":      {},
+	"favicon.ico": {},
+}
+
+// errGenerateFailed is returned when every attempt to generate a free short key collided.
+var errGenerateFailed = errors.New("生成短链接失败，请重试")
+
+// errStorageUnavailable is returned when a Redis operation fails.
+var errStorageUnavailable = errors.New("存储服务不可用")
+
+// generateRetries is the number of attempts to generate a free short key.
+var generateRetries int
+
+// maxUrlLen is the maximum length in bytes of a decoded long URL.
+var maxUrlLen int
+
+// defaultAllowedSchemes is the default comma separated list of accepted long URL schemes.
+const defaultAllowedSchemes = "http,https"
+
+// longUrlEncodings are the base64 encodings tried in order when decoding longUrl.
+var longUrlEncodings = []*base64.Encoding{
+	base64.StdEncoding,
+	base64.URLEncoding,
+	base64.RawStdEncoding,
+	base64.RawURLEncoding,
+}
+
+// allowedSchemes is the set of accepted long URL schemes, lowercase.
+var allowedSchemes map[string]struct{}
+
+// rootRedirect is where the root path redirects to, empty to show the index page.
+var rootRedirect string
+
+// keyPrefix is the namespace prepended to every Redis key, empty for none.
+var keyPrefix string
+
+// domain is the domain of the generated short links.
+var domain string
+
+// https controls whether the generated short links use https.
+var https int
+
+// returnBoth returns the https short link in ShortUrl and the http one in ShortUrlInsecure.
+var returnBoth bool
+
+// httpsAuto derives the short link protocol from X-Forwarded-Proto when set.
+var httpsAuto bool
+
+// basePath is the path prefix the service is mounted at, empty for the domain root.
+var basePath string
+
+// renewalDays is the number of days added to a short key on renewal.
+var renewalDays int
+
+// noRenew disables the renewal on access, so links expire strictly after their ttl.
+var noRenew bool
+
+// noDedup disables the md5 reverse mapping, so every submission gets a fresh short key.
+var noDedup bool
+
+// renewLockHours is the window in hours during which a short key is renewed at most once.
+var renewLockHours int
+
 // redisPool is a connection pool for Redis.
 var redisPool *redis.Pool
 
 // redisPoolConfig is the Redis pool configuration.
 var redisPoolConfig *redisPoolConf
 
-// redisClient is a Redis client.
-var redisClient redis.Conn
-
 func main() {
-	gin.SetMode(gin.ReleaseMode)
-	router := gin.Default()
-
-	// Log 收集中间件
-	router.Use(LoggerToFile())
-
-	router.LoadHTMLGlob("public/*.html")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "token":
+			os.Exit(runTokenCommand(os.Args[2:]))
+		case "create":
+			os.Exit(runCreateCommand(os.Args[2:]))
+		}
+	}
 
 	port := flag.Int("port", defaultPort, "服务端口")
-	domain := flag.String("domain", "", "短链接域名，必填项")
-	ttl := flag.Int("ttl", defaultExpire, "短链接有效期，单位(天)，默认180天。")
-	conn := flag.String("conn", defaultRedisConfig, "Redis连接，格式: host:port")
+	bind := flag.String("bind", "", "监听地址，如 127.0.0.1，为空则监听所有网卡")
+	flag.StringVar(&domain, "domain", "", "短链接域名，必填项")
+	hostList := flag.String("allow-hosts", "", "允许的长链接域名，逗号分隔，支持 *.example.com；为空则不限制")
+	flag.BoolVar(&redirectOnly, "redirect-only", false, "严格模式：跳转时再次校验目标域名是否在 -allow-hosts 中")
+	schemeList := flag.String("schemes", defaultAllowedSchemes, "允许的长链接协议，逗号分隔；缺少协议的链接将被拒绝")
+	corsList := flag.String("cors-origins", "", "允许跨域获取短链接的来源，逗号分隔，* 为任意来源；为空则不开启")
+	domainList := flag.String("domains", "", "同一实例服务的多个短链接域名，逗号分隔，默认使用第一个")
+	ttl := flag.String("ttl", strconv.Itoa(defaultExpire), "短链接有效期，纯数字单位为天，也支持 30m、12h、7d 等时长，默认180天。")
+	conn := flag.String("conn", defaultRedisConfig, "Redis连接，格式: host:port、[ipv6]:port、unix:///path 或 redis://:password@host:port/db")
 	passwd := flag.String("passwd", "", "Redis连接密码")
-	https := flag.Int("https", 1, "是否返回 https 短链接")
+	flag.IntVar(&https, "https", 1, "是否返回 https 短链接")
+	flag.BoolVar(&returnBoth, "return-both", false, "同时返回 https (ShortUrl) 与 http (ShortUrlInsecure) 短链接")
+	flag.BoolVar(&httpsAuto, "https-auto", false, "根据 X-Forwarded-Proto 请求头决定短链接协议，缺省时使用 -https")
+	flag.IntVar(&maxUrlLen, "max-url-len", defaultMaxUrlLen, "长链接最大长度，单位(字节)")
+	flag.StringVar(&keyPrefix, "prefix", "", "Redis key 前缀，用于多个实例共用同一 Redis")
+	flag.StringVar(&banner, "banner", "", "维护公告横幅内容，为空则不显示")
+	flag.StringVar(&comingSoonUrl, "coming-soon-url", "", "预留短链接跳转的页面地址，为空则显示内置页面")
+	flag.StringVar(&webhookURL, "webhook-url", "", "创建短链接后异步通知的 Webhook 地址，为空则不通知")
+	flag.StringVar(&expiryWebhookURL, "expiry-webhook-url", "", "短链接即将过期时通知的 Webhook 地址，为空则使用 webhook-url")
+	flag.DurationVar(&expiryNotifyWindow, "expiry-window", 0, "短链接过期前多久发送过期通知，如 24h，0为不通知")
+	flag.StringVar(&adminToken, "admin-token", "", "管理接口访问令牌，为空则禁用管理接口")
+	flag.StringVar(&authToken, "auth-token", "", "创建短链接所需的访问令牌，为空则不校验")
+	flag.StringVar(&jwtSecret, "jwt-secret", "", "私有链接 JWT 校验密钥 (HS256)")
+	flag.IntVar(&uniqRetentionDays, "uniq-days", 30, "每日独立访客统计保留天数")
+	flag.IntVar(&generateRetries, "retries", defaultGenerateRetries, "生成短链接冲突时的重试次数")
+	flag.StringVar(&keyMode, "keygen", keyModeRandom, "短链接生成方式: random 或 counter")
+	blocklist := flag.String("blocklist", "", "生成短链接时屏蔽的词表文件，每行一个，不区分大小写")
+	alphabet := flag.String("alphabet", "", "短链接字符集，仅支持字母、数字及 -_~，为空则使用默认62个字符")
+	flag.BoolVar(&subdomainMode, "subdomain-mode", false, "子域名模式：短链接形如 key.domain，需配置泛域名解析")
+	flag.BoolVar(&readableKeys, "readable-keys", false, "随机生成的短链接去除 0Oo1lI 等易混淆字符，且首字符固定为字母")
+	flag.BoolVar(&caseInsensitive, "case-insensitive", false, "短链接不区分大小写，仅使用小写字母生成")
+	flag.BoolVar(&enableChecksum, "checksum", false, "短链接末尾追加校验位，访问时校验以发现输错")
+	flag.StringVar(&basePath, "basepath", "", "路由前缀，如 /u，用于反向代理子路径部署")
+	flag.BoolVar(&enableExemplars, "exemplars", false, "在延迟指标中附带 trace id exemplar (OpenMetrics)")
+	flag.BoolVar(&passthroughQuery, "passthrough-query", false, "跳转时将请求中的查询参数合并到长链接")
+	flag.IntVar(&redirectStatus, "redirect-status", http.StatusMovedPermanently, "短链接跳转的默认状态码，301 或 302")
+	flag.IntVar(&renewalDays, "renew-days", defaultRenewalDay, "访问时续命的天数")
+	flag.BoolVar(&noRenew, "no-renew", false, "访问时不续命，短链接到期即失效")
+	flag.StringVar(&linkSecret, "secret", "", "长链接完整性校验密钥，开启后跳转前校验 HMAC，未签名的已有链接将无法访问")
+	flag.Var(redirectHeaders, "header", "跳转时附加的响应头，格式 key=value，可重复指定")
+	flag.BoolVar(&degradeEnabled, "degrade", false, "Redis 不可用时以进程内缓存跳转，新建链接排队待恢复后写入，需配合 -cache-size")
+	flag.BoolVar(&recordIP, "record-ip", false, "记录创建者IP，仅管理接口可见")
+	flag.IntVar(&maxPerIP, "max-per-ip", 0, "每个客户端IP可创建的有效短链接数上限，0为不限制")
+	flag.BoolVar(&fetchTitles, "fetch-title", false, "创建时获取目标页面标题，超时1秒")
+	flag.BoolVar(&noDedup, "no-dedup", false, "不复用相同长链接的短链接，每次提交均生成新的短链接")
+	flag.IntVar(&maxRenewDays, "max-renew-days", defaultMaxRenewDays, "手动续期允许设置的最大天数")
+	flag.IntVar(&renewLockHours, "renew-lock-hours", defaultRenewLockHours, "续命间隔，单位(小时)，间隔内仅续命一次")
+	trustedProxies := flag.String("trusted-proxies", defaultTrustedProxies, "可信代理 CIDR，逗号分隔，仅信任其 X-Forwarded-For；为空则不信任任何代理")
+	flag.StringVar(&fallbackUrl, "fallback-url", "", "不存在的短链接跳转的地址，附带 key 参数，为空则返回404")
+	flag.StringVar(&notfoundUrl, "notfound-url", "", "从未创建的短链接跳转的地址，为空则返回404页面")
+	flag.StringVar(&expiredUrl, "expired-url", "", "已过期的短链接跳转的地址，为空则返回410页面")
+	flag.BoolVar(&fallbackExpired, "fallback-expired", false, "已过期的短链接同样跳转到 -fallback-url，而非返回410")
+	flag.StringVar(&rootRedirect, "root-redirect", "", "访问根路径时跳转的地址，为空则显示首页")
+	flag.BoolVar(&enableGzip, "gzip", false, "对管理接口响应启用 gzip 压缩")
+	readOnly := flag.Bool("readonly", false, "维护模式：暂停创建与修改短链接，跳转不受影响")
+	analyticsPath := flag.String("analytics-db", "", "SQLite 访问记录数据库路径，为空则不记录")
+	readTimeout := flag.Duration("read-timeout", defaultReadTimeout, "读取完整请求的超时时间")
+	readHeaderTimeout := flag.Duration("read-header-timeout", defaultReadHeaderTimeout, "读取请求头的超时时间")
+	writeTimeout := flag.Duration("write-timeout", defaultWriteTimeout, "写入响应的超时时间")
+	idleTimeout := flag.Duration("idle-timeout", defaultIdleTimeout, "空闲长连接的保持时间")
+	cacheSize := flag.Int("cache-size", 0, "进程内短链接缓存条目数，0 为不缓存")
+	cacheTTL := flag.Duration("cache-ttl", defaultCacheTTL, "进程内缓存有效期，不超过短链接剩余有效期")
+	redisMaxIdle := flag.Int("redis-max-idle", defaultRedisMaxIdle, "Redis 连接池最大空闲连接数")
+	redisMaxActive := flag.Int("redis-max-active", defaultRedisMaxActive, "Redis 连接池最大连接数")
+	redisIdleTimeout := flag.Int("redis-idle-timeout", defaultRedisIdleTimeout, "Redis 空闲连接关闭时间 (秒)")
+	redisHandleTimeout := flag.Int("redis-timeout", defaultRedisHandleTimeout, "Redis 连接、读写超时时间 (秒)")
+	redisWaitAttempts := flag.Int("redis-wait-attempts", 10, "启动时检测 Redis 的最大尝试次数")
+	redisWaitInterval := flag.Duration("redis-wait-interval", time.Second, "启动时检测 Redis 的初始间隔，每次失败后翻倍")
+	redisOptional := flag.Bool("redis-optional", false, "Redis 始终不可用时仍然启动 (降级模式)")
+	flag.Float64Var(&saturationThreshold, "saturation", 0, "键空间占用比例超过该值时自动增大默认 key 长度，按 DBSIZE 计算（含辅助键），0为不调整")
+	poolStatsInterval := flag.Duration("pool-stats-interval", 0, "定期输出 Redis 连接池状态的间隔，0为不输出")
+	gc := flag.Bool("gc", false, "清理指向已删除短链接的 md5 映射与续命锁后退出")
+	logLevel := flag.String("loglevel", "debug", "日志级别: debug/info/warn/error")
+	templates := flag.String("templates", defaultTemplates, "页面模板路径，支持通配符")
+	logFile := flag.String("logfile", defaultLogFile, "访问日志文件，为空则输出到标准输出")
+	showVersion := flag.Bool("version", false, "打印版本号后退出")
 	flag.Parse()
 
-	if *domain == "" {
+	if *showVersion {
+		fmt.Println("MyUrls", version, buildTime)
+		return
+	}
+
+	for _, d := range splitList(*domainList) {
+		cleaned, err := normalizeDomain(d)
+		if err != nil {
+			log.Fatalln("domains 无效:", err)
+		}
+		domains = append(domains, cleaned)
+	}
+	if err := configureLinkRules(*schemeList, *hostList, *blocklist); err != nil {
+		log.Fatalln(err)
+	}
+	corsOrigins = splitList(*corsList)
+	if redirectOnly && len(allowedHosts) == 0 {
+		log.Fatalln("redirect-only 需配合 allow-hosts 使用")
+	}
+	if domain == "" && len(domains) > 0 {
+		domain = domains[0]
+	}
+	if domain == "" && !*gc {
 		flag.Usage()
 		log.Fatalln("缺少关键参数")
 	}
+	if domain != "" {
+		cleaned, err := normalizeDomain(domain)
+		if err != nil {
+			log.Fatalln("domain 无效:", err)
+		}
+		domain = cleaned
+	}
+	if *redisMaxIdle < 1 || *redisMaxActive < 1 || *redisIdleTimeout < 1 || *redisHandleTimeout < 1 {
+		log.Fatalln("redis-max-idle、redis-max-active、redis-idle-timeout 与 redis-timeout 必须大于0")
+	}
+	if *redisWaitAttempts < 1 || *redisWaitInterval <= 0 {
+		log.Fatalln("redis-wait-attempts 与 redis-wait-interval 必须大于0")
+	}
+	if maxPerIP < 0 {
+		log.Fatalln("max-per-ip 不能为负数")
+	}
+	if saturationThreshold < 0 || saturationThreshold >= 1 || math.IsNaN(saturationThreshold) {
+		log.Fatalln("saturation 需在0到1之间")
+	}
+	if seconds, err := parseTTL(*ttl); err != nil {
+		log.Fatalln("ttl 无效:", err)
+	} else {
+		linkTTL = seconds
+	}
+	if *readTimeout <= 0 || *readHeaderTimeout <= 0 || *writeTimeout <= 0 || *idleTimeout <= 0 {
+		log.Fatalln("read-timeout、read-header-timeout、write-timeout 与 idle-timeout 必须大于0")
+	}
+	if *cacheSize < 0 || *cacheTTL <= 0 {
+		log.Fatalln("cache-size 不能为负数，cache-ttl 必须大于0")
+	}
+	if *cacheSize > 0 {
+		linkCache = newLRUCache(*cacheSize, *cacheTTL)
+	} else if degradeEnabled {
+		log.Fatalln("degrade 需要 cache-size 大于0")
+	}
+	for name, value := range map[string]string{"fallback-url": fallbackUrl, "notfound-url": notfoundUrl, "expired-url": expiredUrl} {
+		if value == "" {
+			continue
+		}
+		if u, err := url.Parse(value); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			log.Fatalln(name, "需为 http:// 或 https:// 开头的完整地址")
+		}
+	}
+	if expiryNotifyWindow < 0 {
+		log.Fatalln("expiry-window 不能为负数")
+	} else if expiryNotifyWindow > 0 && expiryWebhookURL == "" && webhookURL == "" {
+		log.Fatalln("expiry-window 需配合 expiry-webhook-url 或 webhook-url 使用")
+	}
+	if !validRedirectStatus(redirectStatus) {
+		log.Fatalln("redirect-status 仅支持 301 或 302")
+	}
+	if rootRedirect != "" {
+		if u, err := url.Parse(rootRedirect); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			log.Fatalln("root-redirect 需为 http:// 或 https:// 开头的完整地址")
+		}
+	}
+	if maxRenewDays < 1 {
+		log.Fatalln("max-renew-days 必须大于0")
+	}
+	if generateRetries < 1 {
+		log.Fatalln("retries 必须大于0")
+	}
+	if err := configureKeyAlphabet(*alphabet); err != nil {
+		log.Fatalln(err)
+	}
+	if renewalDays < 0 || renewLockHours < 1 {
+		log.Fatalln("renew-days 不能为负数，renew-lock-hours 必须大于0")
+	}
+	basePath = normalizeBasePath(basePath)
+
+	level, err := parseLogLevel(*logLevel)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	logger, err := Logger(*logFile, level)
+	if err != nil {
+		log.Fatalln("初始化日志失败:", err)
+	}
+
+	// 启动时加载模板，避免首个页面请求时才发现路径错误
+	router, err := newRouter(logger, splitList(*trustedProxies), *templates)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	endpoint, err := parseRedisConn(*conn)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	// -passwd 优先于连接串中的密码
+	if *passwd != "" {
+		endpoint.password = *passwd
+	}
 
 	redisPoolConfig = &redisPoolConf{
-		maxIdle:        1024,
-		maxActive:      1024,
-		maxIdleTimeout: 30,
-		host:           *conn,
-		password:       *passwd,
-		db:             0,
-		handleTimeout:  30,
+		maxIdle:        *redisMaxIdle,
+		maxActive:      *redisMaxActive,
+		maxIdleTimeout: *redisIdleTimeout,
+		network:        endpoint.network,
+		host:           endpoint.address,
+		password:       endpoint.password,
+		db:             endpoint.db,
+		useTLS:         endpoint.useTLS,
+		handleTimeout:  *redisHandleTimeout,
 	}
 	initRedisPool()
 
-	router.GET("/", func(context *gin.Context) {
-		context.HTML(http.StatusOK, "index.html", gin.H{
-			"title": "MyUrls",
-		})
-	})
+	// 启动时等待 Redis 就绪，避免容器编排下服务先于 Redis 启动
+	if err := waitForRedis(*redisWaitAttempts, *redisWaitInterval); err != nil {
+		if !*redisOptional {
+			log.Fatalln("Redis 连接失败:", err)
+		}
+		log.Println("Redis 连接失败，以降级模式启动:", err)
+	}
 
-	// 短链接生成
-	router.POST("/short", func(context *gin.Context) {
-		res := &Response{
-			Code:     1,
-			Message:  "",
-			LongUrl:  "",
-			ShortUrl: "",
+	// 维护模式：清理失效的 md5 映射与续命锁后退出
+	if *gc {
+		md5Removed, locksRemoved, err := collectGarbage()
+		if err != nil {
+			log.Fatalln("清理失败:", err)
 		}
-		longUrl := context.PostForm("longUrl")
-		shortKey := context.PostForm("shortKey")
-		shortUrlLenStr := context.PostForm("shortUrlLen")
+		fmt.Printf("清理完成: md5 映射 %d 个, 续命锁 %d 个\n", md5Removed, locksRemoved)
+		return
+	}
 
-		shortUrlLen := defaultShortUrlLen
+	maintenanceMode.Store(*readOnly)
 
-		if longUrl == "" {
-			res.Code = 0
-			res.Message = "longUrl为空"
-			context.JSON(200, *res)
-			return
+	if *poolStatsInterval > 0 {
+		go logPoolStats(*poolStatsInterval)
+	}
+	// 计数器模式的 key 长度由计数器决定，无需按饱和度调整
+	if saturationThreshold > 0 && keyMode == keyModeRandom {
+		go watchKeyspace()
+	}
+	if expiryNotifyWindow > 0 {
+		go watchExpiringLinks()
+	}
+
+	if *analyticsPath != "" {
+		if err := openAnalytics(*analyticsPath); err != nil {
+			log.Fatalln("访问记录数据库打开失败:", err)
 		}
-		if shortUrlLenStr != "" {
-			_shortUrlLen, err := strconv.Atoi(shortUrlLenStr)
-			if err != nil {
-				res.Code = 0
-				res.Message = "shortUrlLen必须为数字"
-				context.JSON(200, *res)
-				return
-			}
-			// 如果填写了 shortUrlLen，检测是否在范围内
-			if _shortUrlLen >= minShortUrlLen && _shortUrlLen <= maxShortUrlLen {
-				shortUrlLen = _shortUrlLen
-			} else {
-				res.Code = 0
-				res.Message = fmt.Sprintf("shortUrlLen范围为%d-%d", minShortUrlLen, maxShortUrlLen)
-				context.JSON(200, *res)
-				return
-			}
+	}
+
+	// 显式设置超时，防止慢速客户端长期占用连接
+	server := &http.Server{
+		Addr:              net.JoinHostPort(*bind, strconv.Itoa(*port)),
+		Handler:           router,
+		ReadTimeout:       *readTimeout,
+		ReadHeaderTimeout: *readHeaderTimeout,
+		WriteTimeout:      *writeTimeout,
+		IdleTimeout:       *idleTimeout,
+	}
+
+	// 收到退出信号后停止接收新请求，等待处理中的请求完成并写入缓冲的访问记录
+	// ListenAndServe 在 Shutdown 开始时即返回，需等待处理中的请求结束后再关闭访问记录
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		<-quit
+
+		ctx, cancel := stdcontext.WithTimeout(stdcontext.Background(), defaultShutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			log.Println("Shutdown:", err)
 		}
+	}()
 
-		// longUrl base64 解码
-		_longUrl, _ := base64.StdEncoding.DecodeString(longUrl)
-		longUrl = string(_longUrl)
-		res.LongUrl = longUrl
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Fatalln(err)
+	}
+	<-shutdownDone
+	closeAnalytics()
+}
 
-		// 根据有没有填写 short key，分别执行
-		if shortKey != "" {
-			redisClient := redisPool.Get()
+// 首页，子域名模式下转交短链接跳转
+func indexHandler(context *gin.Context) {
+	if key := subdomainKey(context.Request.Host); key != "" {
+		context.Params = append(context.Params, gin.Param{Key: "shortKey", Value: key})
+		redirectHandler(context)
+		return
+	}
+	if rootRedirect != "" {
+		context.Redirect(http.StatusFound, rootRedirect)
+		return
+	}
 
-			// 检测短链是否已存在
-			_exists, _ := redis.String(redisClient.Do("get", shortKey))
-			if _exists != "" && _exists != longUrl {
-				res.Code = 0
-				res.Message = "短链接已存在，请更换key"
-				context.JSON(200, *res)
-				return
-			}
+	context.HTML(http.StatusOK, "index.html", gin.H{
+		"title":  "MyUrls",
+		"banner": currentBanner(),
+	})
+}
 
-			// 存储
-			_, _ = redisClient.Do("set", shortKey, longUrl)
+// 短链接生成
+func createHandler(context *gin.Context) {
+	res := &Response{
+		Code:     1,
+		Message:  "",
+		LongUrl:  "",
+		ShortUrl: "",
+	}
+	// 维护模式或 Redis 只读时暂停创建，跳转不受影响
+	if err := writesSuspended(); err != nil {
+		respondError(context, http.StatusServiceUnavailable, err.Error())
+		return
+	}
 
-		} else {
-			shortKey = longToShort(longUrl, *ttl*secondsPerDay, shortUrlLen)
+	longUrl := context.PostForm("longUrl")
+	shortKey := context.PostForm("shortKey")
+	shortUrlLenStr := context.PostForm("shortUrlLen")
+	requireAuthStr := context.PostForm("requireAuth")
+	maxClicksStr := context.PostForm("maxClicks")
+	utmStr := context.PostForm("utm")
+	languagesStr := context.PostForm("languages")
+	platformsStr := context.PostForm("platforms")
+	tagValues := context.PostFormArray("tags")
+	ttlStr := context.PostForm("ttl")
+	countStr := context.PostForm("count")
+	unlistedStr := context.PostForm("private")
+	redirectStatusStr := context.PostForm("redirectStatus")
+	includeQrStr := context.PostForm("includeQr")
+
+	requireAuth := false
+	maxClicks := 0
+	utm := ""
+	languages := ""
+	platforms := ""
+	redirectCode := 0
+
+	// 仅限制已存储的链接数，与请求频率无关
+	if reached, err := ipLimitReached(context.ClientIP()); err != nil {
+		if err = storageError(err); !degradedRead(err) {
+			respondError(context, http.StatusInternalServerError, err.Error())
+			return
+		}
+	} else if reached {
+		respondError(context, http.StatusTooManyRequests, "创建的短链接数量已达上限")
+		return
+	}
+
+	// 仅填写 shortKey 时预留短链接
+	if longUrl == "" && shortKey != "" {
+		reserveShortKey(context, shortKey)
+		return
+	}
+	if longUrl == "" {
+		respondError(context, http.StatusBadRequest, "longUrl为空")
+		return
+	}
+	if d := context.PostForm("domain"); d != "" && !domainAllowed(d) {
+		respondError(context, http.StatusBadRequest, "domain不在允许的域名列表中")
+		return
+	}
+	shortUrlLen, err := parseShortUrlLen(shortUrlLenStr)
+	if err != nil {
+		respondError(context, http.StatusBadRequest, err.Error())
+		return
+	}
+	if requireAuthStr != "" {
+		_requireAuth, err := strconv.ParseBool(requireAuthStr)
+		if err != nil {
+			respondError(context, http.StatusBadRequest, "requireAuth必须为布尔值")
+			return
+		}
+		if _requireAuth && jwtSecret == "" {
+			respondError(context, http.StatusBadRequest, "未配置JWT密钥，无法创建私有链接")
+			return
+		}
+		requireAuth = _requireAuth
+	}
+	if maxClicksStr != "" {
+		_maxClicks, err := strconv.Atoi(maxClicksStr)
+		if err != nil || _maxClicks < 0 {
+			respondError(context, http.StatusBadRequest, "maxClicks必须为非负整数")
+			return
+		}
+		maxClicks = _maxClicks
+	}
+	if redirectStatusStr != "" {
+		_status, err := strconv.Atoi(redirectStatusStr)
+		if err != nil || !validRedirectStatus(_status) {
+			respondError(context, http.StatusBadRequest, "redirectStatus必须为301或302")
+			return
+		}
+		redirectCode = _status
+	}
+	includeQr := false
+	if includeQrStr != "" {
+		if includeQr, err = strconv.ParseBool(includeQrStr); err != nil {
+			respondError(context, http.StatusBadRequest, "includeQr必须为布尔值")
+			return
+		}
+	}
+	tags, err := parseTags(tagValues)
+	if err != nil {
+		respondError(context, http.StatusBadRequest, err.Error())
+		return
+	}
+	// 不公开链接使用高熵随机 key，且不出现在管理列表与排行榜中
+	unlisted := false
+	if unlistedStr != "" {
+		if unlisted, err = strconv.ParseBool(unlistedStr); err != nil {
+			respondError(context, http.StatusBadRequest, "private必须为布尔值")
+			return
 		}
+		if unlisted && (shortKey != "" || countStr != "") {
+			respondError(context, http.StatusBadRequest, "private不能与shortKey或count同时使用")
+			return
+		}
+	}
+	// 未指定 ttl 时使用全局有效期，自定义短链接则不过期
+	ttl := linkTTL
+	if ttlStr != "" {
+		if ttl, err = parseTTL(ttlStr); err != nil {
+			respondError(context, http.StatusBadRequest, "ttl无效: "+err.Error())
+			return
+		}
+	}
+	if utmStr != "" {
+		_utm, err := parseUtm(utmStr)
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err.Error())
+			return
+		}
+		utm = _utm
+	}
+	if languagesStr != "" {
+		_languages, err := parseLanguages(languagesStr)
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err.Error())
+			return
+		}
+		languages = _languages
+	}
+	if platformsStr != "" {
+		_platforms, err := parsePlatforms(platformsStr)
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err.Error())
+			return
+		}
+		platforms = _platforms
+	}
 
-		protocol := "http://"
-		if *https != 0 {
-			protocol = "https://"
+	// longUrl base64 解码
+	longUrl, err = decodeLongUrl(longUrl)
+	if err == nil {
+		err = validateLongUrl(longUrl)
+	}
+	if err != nil {
+		respondError(context, http.StatusBadRequest, err.Error())
+		return
+	}
+	res.LongUrl = longUrl
+
+	// 根据有没有填写 short key，分别执行
+	custom := shortKey != ""
+
+	// 仅生成候选短链接供用户挑选，不写入存储，选定后以 shortKey 再次提交
+	if countStr != "" {
+		if custom {
+			respondError(context, http.StatusBadRequest, "count与shortKey不能同时使用")
+			return
+		}
+		count, err := strconv.Atoi(strings.TrimSpace(countStr))
+		if err != nil || count < 1 || count > maxCandidates {
+			respondError(context, http.StatusBadRequest, fmt.Sprintf("count必须为1-%d之间的整数", maxCandidates))
+			return
+		}
+		candidates, err := generateCandidates(count, shortUrlLen)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, errCounterOverflow) {
+				status = http.StatusBadRequest
+			}
+			respondError(context, status, err.Error())
+			return
+		}
+		res.Candidates = candidates
+		if wantsText(context) {
+			context.String(200, strings.Join(candidates, "\n"))
+			return
 		}
-		res.ShortUrl = protocol + *domain + "/" + shortKey
+		context.JSON(200, *res)
+		return
+	}
 
-		// context.Header("Access-Control-Allow-Origin", "*")
+	// Redis 不可用时仅接受普通链接，写入队列待恢复后补写
+	if degradeEnabled && storageDegraded.Load() {
+		if custom || keyMode != keyModeRandom || requireAuth || maxClicks > 0 || utm != "" || len(tags) > 0 || unlisted || redirectCode != 0 || languages != "" || platforms != "" {
+			respondError(context, http.StatusServiceUnavailable, errStorageUnavailable.Error())
+			return
+		}
+		var err error
+		if shortKey, err = generate(shortUrlLen); err != nil {
+			respondError(context, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if err := queueLink(shortKey, longUrl, ttl); err != nil {
+			respondError(context, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		res.ShortUrl, res.ShortUrlInsecure = shortUrlVariants(context, shortKey)
+		if includeQr {
+			res.QrDataUri = shortUrlQr(res.ShortUrl)
+		}
+		if wantsText(context) {
+			context.String(200, res.ShortUrl)
+			return
+		}
 		context.JSON(200, *res)
+		return
+	}
+
+	shortKey, res.Title, err = createLink(linkRequest{
+		longUrl:        longUrl,
+		shortKey:       shortKey,
+		shortUrlLen:    shortUrlLen,
+		ttl:            ttl,
+		customTTL:      ttlStr != "",
+		requireAuth:    requireAuth,
+		maxClicks:      maxClicks,
+		utm:            utm,
+		languages:      languages,
+		platforms:      platforms,
+		tags:           tags,
+		unlisted:       unlisted,
+		redirectStatus: redirectCode,
+		clientIP:       context.ClientIP(),
 	})
+	if err != nil {
+		respondError(context, errorStatus(err), err.Error())
+		return
+	}
+
+	res.ShortUrl, res.ShortUrlInsecure = shortUrlVariants(context, shortKey)
+	if includeQr {
+		res.QrDataUri = shortUrlQr(res.ShortUrl)
+	}
 
-	// 短链接跳转
-	router.GET("/:shortKey", func(context *gin.Context) {
-		shortKey := context.Param("shortKey")
-		longUrl := shortToLong(shortKey)
+	if webhookURL != "" {
+		// 自定义短链接不过期，ttl 为 -1
+		event := LinkEvent{ShortUrl: res.ShortUrl, LongUrl: longUrl, ShortKey: shortKey, TTL: -1, CreatedAt: time.Now().Unix()}
+		if created, err := getLinkMeta(shortKey); err == nil && linkCreatedAt(created) > 0 {
+			event.CreatedAt = linkCreatedAt(created)
+		}
+		if !custom || ttlStr != "" {
+			event.TTL = ttl
+		}
+		notifyLinkCreated(event)
+	}
 
+	if wantsText(context) {
+		context.String(200, res.ShortUrl)
+		return
+	}
+
+	// context.Header("Access-Control-Allow-Origin", "*")
+	context.JSON(200, *res)
+}
+
+// 短链接跳转，子域名模式下由首页路由转交
+func redirectHandler(context *gin.Context) {
+	if shadowedRoute(context) {
+		return
+	}
+	setCorsHeaders(context)
+	shortKey := normalizeShortKey(context.Param("shortKey"))
+
+	// 配置多域名时，仅服务已配置的域名
+	if !hostAllowed(context.Request.Host) {
+		respondNotFound(context, http.StatusNotFound, "未知域名")
+		return
+	}
+
+	// 校验位错误，无需查询 Redis
+	if !validChecksum(strings.TrimSuffix(shortKey, "+")) {
+		respondNotFound(context, http.StatusNotFound, "短链接校验失败，请检查是否输错")
+		return
+	}
+
+	// 预览模式：/:shortKey+ 或 ?preview=1，仅展示目标地址，不续命
+	if strings.HasSuffix(shortKey, "+") || context.Query("preview") == "1" {
+		shortKey = strings.TrimSuffix(shortKey, "+")
+		// 私有链接需校验 JWT
+		if linkRequiresAuth(shortKey) && !authorizePrivateLink(context.GetHeader("Authorization")) {
+			context.String(http.StatusUnauthorized, "该链接需要授权访问")
+			return
+		}
+		longUrl, err := peekLongUrl(shortKey)
+		if err != nil {
+			context.String(http.StatusInternalServerError, err.Error())
+			return
+		}
 		if longUrl == "" {
-			context.String(http.StatusNotFound, "短链接不存在或已过期")
-		} else {
-			context.Redirect(http.StatusMovedPermanently, longUrl)
+			respondMissing(context, shortKey)
+			return
 		}
-	})
+		if linkDisabled(shortKey) {
+			context.String(http.StatusForbidden, errLinkDisabled.Error())
+			return
+		}
+		if isPlaceholder(longUrl) {
+			respondComingSoon(context)
+			return
+		}
+
+		context.HTML(http.StatusOK, "preview.html", gin.H{
+			"title":       "MyUrls",
+			"banner":      currentBanner(),
+			"longUrl":     longUrl,
+			"continueUrl": shortKey,
+		})
+		return
+	}
+
+	// 一次往返读取长链接与跳转所需的全部元数据
+	redisClient := redisPool.Get()
+	defer redisClient.Close()
+	link, err := resolveLink(redisClient, shortKey)
+
+	// 私有链接需校验 JWT
+	if link.requireAuth() && !authorizePrivateLink(context.GetHeader("Authorization")) {
+		context.String(http.StatusUnauthorized, "该链接需要授权访问")
+		return
+	}
 
-	router.Run(fmt.Sprintf(":%d", *port))
+	longUrl := link.longUrl
+	if err == nil && longUrl != "" && !isPlaceholder(longUrl) {
+		// 按设备平台与 Accept-Language 选择目标地址，缓存需区分对应请求头
+		var vary []string
+		if longUrl, vary = targetUrl(shortKey, link, context.Request.Header); len(vary) > 0 {
+			context.Writer.Header().Add("Vary", strings.Join(vary, ", "))
+		}
+		if !redirectOnly || destinationAllowed(longUrl) {
+			// 统计访问次数，超出访问次数上限的链接不再跳转
+			// 计数与续命均为尽力写入，Redis 只读或不可用时仍然跳转；限次链接无法计数时不跳转，否则上限失效
+			if err = trackVisit(redisClient, shortKey, link, context.ClientIP(), context.Request.Referer()); err != nil && !errors.Is(err, errLinkExhausted) {
+				log.Printf("Visit tracking of %s failed: %v", shortKey, err)
+				if link.maxClicks == 0 || !errors.Is(err, errClicksUncounted) {
+					err = nil
+				}
+			}
+		}
+	}
+
+	if errors.Is(err, errLinkDisabled) {
+		context.String(http.StatusForbidden, err.Error())
+	} else if errors.Is(err, errLinkExhausted) {
+		context.String(http.StatusGone, err.Error())
+	} else if errors.Is(err, errClicksUncounted) {
+		context.String(http.StatusServiceUnavailable, errClicksUncounted.Error())
+	} else if err != nil {
+		context.String(http.StatusInternalServerError, err.Error())
+	} else if longUrl == "" {
+		respondMissing(context, shortKey)
+	} else if isPlaceholder(longUrl) {
+		respondComingSoon(context)
+	} else if redirectOnly && !destinationAllowed(longUrl) {
+		// 允许列表变更后，已有链接同样不再跳转
+		log.Printf("Rejected redirect of %s to %s: host not allowed", shortKey, longUrl)
+		context.String(http.StatusForbidden, "目标地址不在允许列表中")
+	} else {
+		recordVisitEvent(context, shortKey)
+		longUrl = appendUtm(link, longUrl)
+		if passthroughQuery {
+			longUrl = mergeQuery(longUrl, context.Request.URL.Query())
+		}
+		status := linkRedirectStatus(link)
+		setRedirectHeaders(context, status)
+		context.Redirect(status, encodeLocation(longUrl))
+	}
 }
 
-// 短链接转长链接
-func shortToLong(shortKey string) string {
-	redisClient = redisPool.Get()
+// 查询短链接对应的长链接，不续命
+func peekLongUrl(shortKey string) (string, error) {
+	redisClient := redisPool.Get()
 	defer redisClient.Close()
 
-	longUrl, _ := redis.String(redisClient.Do("get", shortKey))
+	longUrl, err := redis.String(redisClient.Do("get", redisKey(shortKey)))
+	if err != nil && err != redis.ErrNil {
+		return "", storageError(err)
+	}
+	if linkSecret != "" && longUrl != "" {
+		mac, _ := redis.String(redisClient.Do("hget", redisKey(defaultMetaPrefix+shortKey), "hmac"))
+		if err := verifyLink(shortKey, longUrl, mac); err != nil {
+			return "", err
+		}
+	}
+
+	return longUrl, nil
+}
+
+// storageError logs a failed Redis operation and returns the error reported to clients.
+func storageError(err error) error {
+	if noteWriteError(err) {
+		return errStorageReadOnly
+	}
+	noteUnavailable(err)
+	log.Println("Redis error:", err)
+
+	return errStorageUnavailable
+}
+
+// redisKey namespaces a Redis key with the configured -prefix, unchanged when no prefix is set.
+func redisKey(key string) string {
+	if keyPrefix == "" {
+		return key
+	}
+
+	return keyPrefix + ":" + key
+}
+
+// wantsText reports whether the client asked for a plain text response via ?format=text or the Accept header.
+func wantsText(context *gin.Context) bool {
+	if format := context.Query("format"); format != "" {
+		return format == "text"
+	}
+
+	return context.NegotiateFormat(gin.MIMEJSON, gin.MIMEPlain) == gin.MIMEPlain
+}
+
+// respondError writes an error Response with Code 0 and the given HTTP status,
+// or only the message for plain text clients.
+func respondError(context *gin.Context, httpStatus int, message string) {
+	if wantsText(context) {
+		context.String(httpStatus, message)
+		return
+	}
+
+	context.JSON(httpStatus, Response{
+		Code:      0,
+		Message:   message,
+		RequestID: requestID(context),
+	})
+}
+
+// buildShortUrl returns the public short URL of a short key.
+func buildShortUrl(context *gin.Context, shortKey string) string {
+	return requestScheme(context) + "://" + shortAddress(context, shortKey)
+}
 
-	// 获取到长链接后，续命1天。每天仅允许续命1次。
-	if longUrl != "" {
-		renew(shortKey)
+// shortAddress returns the short URL of a short key without its scheme, carrying the key
+// as the first DNS label in -subdomain-mode.
+func shortAddress(context *gin.Context, shortKey string) string {
+	if subdomainMode {
+		return shortKey + "." + requestDomain(context) + basePath
 	}
 
-	return longUrl
+	return requestDomain(context) + basePath + "/" + shortKey
+}
+
+// shortUrlVariants returns the short URL of a short key and, with -return-both, its https and http variants.
+func shortUrlVariants(context *gin.Context, shortKey string) (string, string) {
+	if !returnBoth {
+		return buildShortUrl(context, shortKey), ""
+	}
+
+	address := shortAddress(context, shortKey)
+
+	return "https://" + address, "http://" + address
+}
+
+// requestScheme returns the protocol of the generated short links, honoring
+// X-Forwarded-Proto in -https-auto mode.
+func requestScheme(context *gin.Context) string {
+	if httpsAuto {
+		proto := context.GetHeader("X-Forwarded-Proto")
+		if i := strings.IndexByte(proto, ','); i >= 0 {
+			proto = proto[:i]
+		}
+
+		switch strings.ToLower(strings.TrimSpace(proto)) {
+		case "https":
+			return "https"
+		case "http":
+			return "http"
+		}
+	}
+
+	return defaultScheme()
+}
+
+// defaultScheme returns the protocol configured by -https, used when the request doesn't decide it.
+func defaultScheme() string {
+	if https != 0 {
+		return "https"
+	}
+
+	return "http"
+}
+
+// decodeLongUrl decodes the base64 encoded longUrl parameter.
+// Standard, URL-safe and their unpadded variants are all accepted.
+func decodeLongUrl(encoded string) (string, error) {
+	for _, encoding := range longUrlEncodings {
+		longUrl, err := encoding.DecodeString(encoded)
+		if err == nil && len(longUrl) > 0 {
+			return string(longUrl), nil
+		}
+	}
+
+	return "", errors.New("longUrl格式错误，需为 base64 编码")
+}
+
+// validateLongUrl checks a decoded long URL before it is stored.
+// Schemeless input such as example.com/path is rejected rather than guessed as https.
+func validateLongUrl(longUrl string) error {
+	if len(longUrl) > maxUrlLen {
+		return errors.New("长链接过长")
+	}
+
+	u, err := url.Parse(longUrl)
+	if err != nil {
+		return errors.New("长链接格式错误")
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if scheme == "" {
+		return errors.New("长链接缺少协议，请以 http:// 或 https:// 开头")
+	}
+	if _, ok := allowedSchemes[scheme]; !ok {
+		return fmt.Errorf("不支持的链接协议: %s", scheme)
+	}
+	if (scheme == "http" || scheme == "https") && u.Host == "" {
+		return errors.New("长链接缺少域名")
+	}
+	if !hostAllowedByList(u.Hostname()) {
+		return fmt.Errorf("长链接域名不在允许列表中: %s", u.Hostname())
+	}
+
+	return nil
+}
+
+// splitList splits a comma separated flag value, trimming spaces and dropping empty entries.
+func splitList(list string) []string {
+	var result []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+
+	return result
+}
+
+// longUrlHash returns the hex digest of a long URL used as the dedup key, an HMAC-SHA256 keyed
+// with -secret so the reverse mappings cannot be matched against guessed URLs, or a plain SHA-256
+// when no secret is set. Mappings written under another digest are no longer found and age out.
+func longUrlHash(longUrl string) string {
+	if linkSecret == "" {
+		digest := sha256.Sum256([]byte(longUrl))
+		return hex.EncodeToString(digest[:])
+	}
+
+	mac := hmac.New(sha256.New, []byte(linkSecret))
+	mac.Write([]byte(longUrl))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// normalizeBasePath turns a -basepath value into "" or "/prefix" without a trailing slash.
+func normalizeBasePath(p string) string {
+	p = strings.Trim(strings.TrimSpace(p), "/")
+	if p == "" {
+		return ""
+	}
+
+	return "/" + p
 }
 
 // 长链接转短链接
-func longToShort(longUrl string, ttl int, shortUrlLen int) string {
-	redisClient = redisPool.Get()
+func longToShort(longUrl string, ttl int, shortUrlLen int, dedup bool, unlisted bool) (string, error) {
+	redisClient := redisPool.Get()
 	defer redisClient.Close()
 
 	// 是否生成过该长链接对应短链接
-	longUrlMD5Bytes := md5.Sum([]byte(longUrl))
-	longUrlMD5 := hex.EncodeToString(longUrlMD5Bytes[:])
-	// 添加前缀，防止和短链接冲突
-	_existsKey, _ := redis.String(redisClient.Do("get", defaultMd5Prefix+longUrlMD5))
+	longUrlDigest := longUrlHash(longUrl)
+	if dedup {
+		// 添加前缀，防止和短链接冲突
+		_existsKey, err := redis.String(redisClient.Do("get", redisKey(defaultMd5Prefix+longUrlDigest)))
+		if err != nil && err != redis.ErrNil {
+			return "", storageError(err)
+		}
 
-	// 如果存在，直接返回
-	if _existsKey != "" {
-		// 更新shortKey过期时间
-		_, _ = redisClient.Do("expire", _existsKey, ttl)
+		// 如果存在，直接返回
+		if _existsKey != "" {
+			pttl, err := redis.Int64(redisClient.Do("pttl", redisKey(_existsKey)))
+			if err != nil {
+				return "", storageError(err)
+			}
+			// 更新shortKey过期时间，仅延长，不缩短已续命或永久有效的链接
+			if pttl > 0 && pttl < int64(ttl)*1000 {
+				if _, err := redisClient.Do("expire", redisKey(_existsKey), ttl); err != nil {
+					return "", storageError(err)
+				}
+				if err := syncLinkExpiry(redisClient, _existsKey); err != nil {
+					return "", storageError(err)
+				}
+			}
 
-		log.Println("Hit cache: " + _existsKey)
-		return _existsKey
+			// 映射残留而短链接已过期时重新生成
+			if pttl != -2 {
+				log.Println("Hit cache: " + _existsKey)
+				return _existsKey, nil
+			}
+		}
 	}
 
-	// 重试三次
+	// 重试 generateRetries 次，SET NX 原子占用 shortKey，避免并发请求写入同一 key
 	var shortKey string
-	for i := 0; i < 3; i++ {
-		shortKey = generate(shortUrlLen)
+	for i := 0; i < generateRetries; i++ {
+		var candidate string
+		var err error
+		if unlisted {
+			candidate, err = unblockedKey(func() (string, error) { return generateSecure(unlistedKeyLen) })
+		} else {
+			candidate, err = candidateForAttempt(redisClient, shortUrlLen, i)
+		}
+		if err != nil {
+			return "", err
+		}
 
-		_existsLongUrl, _ := redis.String(redisClient.Do("get", shortKey))
-		if _existsLongUrl == "" {
+		reply, err := redis.String(redisClient.Do("set", redisKey(candidate), longUrl, "nx", "ex", ttl))
+		if err != nil && err != redis.ErrNil {
+			return "", storageError(err)
+		}
+		if reply == "OK" {
+			shortKey = candidate
+			if err := syncLinkExpiry(redisClient, shortKey); err != nil {
+				return "", storageError(err)
+			}
 			break
 		}
 	}
 
-	if shortKey != "" {
-		// 设定shortKey和md5缓存，MD5添加前缀，防止和短链接冲突
-		_, _ = redisClient.Do("mset", shortKey, longUrl, defaultMd5Prefix+longUrlMD5, shortKey)
+	if shortKey == "" {
+		return "", errGenerateFailed
+	}
 
-		// 设置shortKey过期时间
-		_, _ = redisClient.Do("expire", shortKey, ttl)
-		// 设置longUrlMD5过期时间
-		_, _ = redisClient.Do("expire", defaultMd5Prefix+longUrlMD5, secondsPerDay)
+	if dedup {
+		// 设定md5缓存，MD5添加前缀，防止和短链接冲突
+		if _, err := redisClient.Do("set", redisKey(defaultMd5Prefix+longUrlDigest), shortKey, "ex", secondsPerDay); err != nil {
+			return "", storageError(err)
+		}
 	}
 
-	return shortKey
+	return shortKey, nil
 }
 
-// 续命
-func renew(shortKey string) {
-	redisClient = redisPool.Get()
-	defer redisClient.Close()
+// candidateForAttempt generates the short key for the given retry attempt.
+// In random mode the key grows by one character every two consecutive collisions.
+func candidateForAttempt(conn redis.Conn, shortUrlLen int, attempt int) (string, error) {
+	if keyMode == keyModeRandom {
+		shortUrlLen += attempt / 2
+		if shortUrlLen > maxShortUrlLen {
+			shortUrlLen = maxShortUrlLen
+		}
+	}
+	candidate, err := nextCandidate(conn, shortUrlLen)
+	if errors.Is(err, errCounterOverflow) || errors.Is(err, errKeyBlocked) {
+		return "", err
+	} else if err != nil {
+		return "", storageError(err)
+	}
 
-	// 加锁， 防止多次续命
-	lockKey := defaultLockPrefix + shortKey
-	lock, _ := redis.Int(redisClient.Do("setnx", lockKey, 1))
-	if lock == 1 {
-		// 设置锁过期时间
-		_, _ = redisClient.Do("expire", lockKey, defaultRenewalDay*secondsPerDay)
+	return candidate, nil
+}
 
-		// 续命
-		ttl, err := redis.Int(redisClient.Do("ttl", shortKey))
-		if err == nil && ttl != -1 {
-			_, _ = redisClient.Do("expire", shortKey, ttl+defaultRenewalDay*secondsPerDay)
+// generateCandidates returns count distinct short keys that are currently unused, without storing any of them.
+func generateCandidates(count int, shortUrlLen int) ([]string, error) {
+	redisClient := redisPool.Get()
+	defer redisClient.Close()
+
+	candidates := make([]string, 0, count)
+	seen := map[string]struct{}{}
+	for i := 0; i < generateRetries*count && len(candidates) < count; i++ {
+		candidate, err := candidateForAttempt(redisClient, shortUrlLen, i/count)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := seen[candidate]; ok {
+			continue
 		}
+		exists, err := redis.Bool(redisClient.Do("exists", redisKey(candidate)))
+		if err != nil {
+			return nil, storageError(err)
+		}
+		if exists {
+			continue
+		}
+		seen[candidate] = struct{}{}
+		candidates = append(candidates, candidate)
+	}
+
+	if len(candidates) < count {
+		return nil, errGenerateFailed
 	}
+
+	return candidates, nil
 }
 
-// generate is a function that takes an integer bits and returns a string.
-// The function generates a random string of length equal to bits using the letterBytes slice.
-// The letterBytes slice contains characters that can be used to generate a random string.
-// The generation of the random string is based on the current time using the UnixNano() function.
-func generate(bits int) string {
-	// Create a byte slice b of length bits.
-	b := make([]byte, bits)
+// 短链接是否为需要授权访问的私有链接
+func linkRequiresAuth(shortKey string) bool {
+	meta, _ := getLinkMeta(shortKey)
+
+	return meta["requireAuth"] == "1"
+}
+
+// parseShortUrlLen parses the shortUrlLen parameter, returning the default length when it is blank.
+// Only plain decimal digits are accepted, so signs and out of range values all report the valid range.
+func parseShortUrlLen(raw string) (int, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return currentShortUrlLen(), nil
+	}
 
-	// Create a new random number generator with the current time as the seed.
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	rangeErr := fmt.Errorf("shortUrlLen必须为%d-%d之间的整数", minShortUrlLen, maxShortUrlLen)
+	for _, c := range raw {
+		if c < '0' || c > '9' {
+			return 0, rangeErr
+		}
+	}
 
-	// Generate a random byte for each element in the byte slice b using the letterBytes slice.
-	for i := range b {
-		b[i] = letterBytes[r.Intn(len(letterBytes))]
+	// 超出 int 范围时 Atoi 报错，同样视为超出范围
+	shortUrlLen, err := strconv.Atoi(raw)
+	if err != nil || shortUrlLen < minShortUrlLen || shortUrlLen > maxShortUrlLen {
+		return 0, rangeErr
 	}
 
-	// Convert the byte slice to a string and return it.
-	return string(b)
+	return shortUrlLen, nil
 }
 
-// 定义 logger
-func Logger() *logrus.Logger {
-	logFilePath := ""
-	if dir, err := os.Getwd(); err == nil {
-		logFilePath = dir + "/logs/"
+// parseTTL parses a link lifetime into seconds. A bare integer is a number of days, otherwise
+// the value is a Go duration such as "90m" or "12h", with an additional "d" suffix for days.
+func parseTTL(raw string) (int, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0, errors.New("有效期为空")
+	}
+
+	var ttl time.Duration
+	if days, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		if days < 1 || days > maxTTL/secondsPerDay {
+			return 0, fmt.Errorf("有效期需在1秒至%d天之间", maxTTL/secondsPerDay)
+		}
+		return int(days) * secondsPerDay, nil
+	} else if strings.HasSuffix(raw, "d") {
+		days, err := strconv.ParseFloat(strings.TrimSuffix(raw, "d"), 64)
+		if err != nil || math.IsNaN(days) || math.IsInf(days, 0) {
+			return 0, fmt.Errorf("无法解析有效期 %q", raw)
+		}
+		if days > maxTTL/secondsPerDay {
+			return 0, fmt.Errorf("有效期需在1秒至%d天之间", maxTTL/secondsPerDay)
+		}
+		ttl = time.Duration(days * float64(24*time.Hour))
+	} else {
+		ttl, err = time.ParseDuration(raw)
+		if err != nil {
+			return 0, fmt.Errorf("无法解析有效期 %q", raw)
+		}
+	}
+
+	seconds := ttl / time.Second
+	if seconds < 1 || seconds > maxTTL {
+		return 0, fmt.Errorf("有效期需在1秒至%d天之间", maxTTL/secondsPerDay)
 	}
-	if err := os.MkdirAll(logFilePath, 0777); err != nil {
-		fmt.Println(err.Error())
+
+	return int(seconds), nil
+}
+
+// validateShortKey checks that a custom short key is neither reserved nor contains characters outside keyAlphabet.
+func validateShortKey(shortKey string) error {
+	if _, ok := reservedKeys[strings.ToLower(shortKey)]; ok {
+		return errors.New("该短链接为保留字")
 	}
-	logFileName := "access.log"
 
-	//日志文件
-	fileName := path.Join(logFilePath, logFileName)
-	if _, err := os.Stat(fileName); err != nil {
-		if _, err := os.Create(fileName); err != nil {
-			fmt.Println(err.Error())
+	for _, c := range shortKey {
+		if !strings.ContainsRune(keyAlphabet, c) {
+			return errors.New("短链接包含非法字符")
 		}
 	}
 
-	//写入文件
-	src, err := os.OpenFile(fileName, os.O_APPEND|os.O_WRONLY, os.ModeAppend)
-	if err != nil {
-		fmt.Println("err", err)
+	return nil
+}
+
+// generate is a function that takes an integer bits and returns a string.
+// The function generates a random string of length equal to bits using the keyAlphabet slice.
+// The keyAlphabet slice contains characters that can be used to generate a random string.
+// The generation uses the auto-seeded global source, which is safe for concurrent use,
+// so requests generating in the same nanosecond don't produce the same key.
+// With -readable-keys the first character is always a letter.
+// The returned key carries the -checksum check character, and keys containing a token of the
+// -blocklist are regenerated until unblockedKey gives up with errKeyBlocked.
+func generate(bits int) (string, error) {
+	// Create a byte slice b of length bits.
+	b := make([]byte, bits)
+
+	return unblockedKey(func() (string, error) {
+		// Generate a random byte for each element in the byte slice b using the keyAlphabet slice.
+		for i := range b {
+			alphabet := keyPositionAlphabet(i)
+			b[i] = alphabet[rand.Intn(len(alphabet))]
+		}
+
+		// Convert the byte slice to a string and return it.
+		return string(b), nil
+	})
+}
+
+// parseLogLevel parses a -loglevel value, accepting only debug, info, warn and error.
+func parseLogLevel(level string) (logrus.Level, error) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return logrus.DebugLevel, nil
+	case "info":
+		return logrus.InfoLevel, nil
+	case "warn", "warning":
+		return logrus.WarnLevel, nil
+	case "error":
+		return logrus.ErrorLevel, nil
 	}
 
+	return logrus.DebugLevel, fmt.Errorf("不支持的日志级别: %s", level)
+}
+
+// 定义 logger，logFile 为空时输出到标准输出
+func Logger(logFile string, level logrus.Level) (*logrus.Logger, error) {
 	//实例化
 	logger := logrus.New()
 
 	//设置输出
-	logger.SetOutput(src)
-	// logger.Out = src
+	if logFile != "" {
+		//日志文件
+		if err := os.MkdirAll(filepath.Dir(logFile), 0777); err != nil {
+			return nil, err
+		}
+
+		//写入文件
+		src, err := os.OpenFile(logFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
+		if err != nil {
+			return nil, err
+		}
+		logger.SetOutput(src)
+	} else {
+		logger.SetOutput(os.Stdout)
+	}
 
 	//设置日志级别
-	logger.SetLevel(logrus.DebugLevel)
+	logger.SetLevel(level)
 
 	//设置日志格式
 	logger.Formatter = &logrus.JSONFormatter{}
 
-	return logger
+	return logger, nil
 }
 
 // 文件日志
-func LoggerToFile() gin.HandlerFunc {
-	logger := Logger()
+func LoggerToFile(logger *logrus.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		logMap := make(map[string]interface{})
 
@@ -383,6 +1434,9 @@ func LoggerToFile() gin.HandlerFunc {
 		// 请求 UA
 		logMap["clientUA"] = c.Request.UserAgent()
 
+		// 请求 ID
+		logMap["requestID"] = requestID(c)
+
 		//日志格式
 		// logJson, _ := json.Marshal(logMap)
 		// logger.Info(string(logJson))
@@ -396,6 +1450,7 @@ func LoggerToFile() gin.HandlerFunc {
 			"statusCode":  logMap["statusCode"],
 			"clientIP":    logMap["clientIP"],
 			"clientUA":    logMap["clientUA"],
+			"requestID":   logMap["requestID"],
 		}).Info()
 	}
 }
@@ -409,15 +1464,18 @@ func initRedisPool() {
 		IdleTimeout: time.Duration(redisPoolConfig.maxIdleTimeout) * time.Second,
 		Wait:        true,
 		Dial: func() (redis.Conn, error) {
-			con, err := redis.Dial("tcp", redisPoolConfig.host,
+			con, err := redis.Dial(redisPoolConfig.network, redisPoolConfig.host,
 				redis.DialPassword(redisPoolConfig.password),
 				redis.DialDatabase(redisPoolConfig.db),
+				redis.DialUseTLS(redisPoolConfig.useTLS),
 				redis.DialConnectTimeout(time.Duration(redisPoolConfig.handleTimeout)*time.Second),
 				redis.DialReadTimeout(time.Duration(redisPoolConfig.handleTimeout)*time.Second),
 				redis.DialWriteTimeout(time.Duration(redisPoolConfig.handleTimeout)*time.Second))
 			if err != nil {
+				redisDials.WithLabelValues("error").Inc()
 				return nil, err
 			}
+			redisDials.WithLabelValues("ok").Inc()
 			return con, nil
 		},
 	}
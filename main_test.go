@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+	gin.DefaultErrorWriter = io.Discard
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// setFlag overrides a flag-backed global for the duration of the test.
+func setFlag[T any](t testing.TB, p *T, v T) {
+	t.Helper()
+	old := *p
+	*p = v
+	t.Cleanup(func() { *p = old })
+}
+
+// newTestRouter returns a router with the flag defaults of main, backed by a fresh miniredis.
+func newTestRouter(t testing.TB) (*gin.Engine, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+
+	setFlag(t, &domain, "s.test")
+	setFlag(t, &https, 1)
+	setFlag(t, &linkTTL, defaultExpire*secondsPerDay)
+	setFlag(t, &generateRetries, defaultGenerateRetries)
+	setFlag(t, &allowedSchemes, map[string]struct{}{"http": {}, "https": {}})
+	setFlag(t, &keyMode, keyModeRandom)
+	setFlag(t, &keyAlphabet, letterBytes)
+	setFlag(t, &maxUrlLen, defaultMaxUrlLen)
+	setFlag(t, &renewalDays, defaultRenewalDay)
+	setFlag(t, &renewLockHours, defaultRenewLockHours)
+	setFlag(t, &maxRenewDays, defaultMaxRenewDays)
+	setFlag(t, &uniqRetentionDays, 30)
+	setFlag(t, &redirectStatus, http.StatusMovedPermanently)
+	setFlag(t, &linkCache, nil)
+
+	maintenanceMode.Store(false)
+	storageReadOnly.Store(false)
+	storageDegraded.Store(false)
+	scaledShortUrlLen.Store(defaultShortUrlLen)
+
+	setFlag(t, &redisPoolConfig, &redisPoolConf{
+		maxIdle:        4,
+		maxActive:      16,
+		maxIdleTimeout: 30,
+		network:        "tcp",
+		host:           mr.Addr(),
+		handleTimeout:  5,
+	})
+	oldPool := redisPool
+	initRedisPool()
+	t.Cleanup(func() {
+		redisPool.Close()
+		redisPool = oldPool
+	})
+
+	router, err := newRouter(discardLogger(), nil, defaultTemplates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return router, mr
+}
+
+// discardLogger returns an access logger writing nowhere.
+func discardLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.Out = io.Discard
+	return logger
+}
+
+// serve sends a request through the router and returns the recorded response.
+func serve(router http.Handler, method, target string, form url.Values, header http.Header) *httptest.ResponseRecorder {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req := httptest.NewRequest(method, target, body)
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	return serveRequest(router, req)
+}
+
+// serveRequest sends req through the router and returns the recorded response.
+func serveRequest(router http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// decode unmarshals a JSON response body into v.
+func decode(t testing.TB, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("decode response %q: %v", w.Body.String(), err)
+	}
+}
+
+// shorten creates a short link for longUrl with the extra form fields and returns the decoded response.
+func shorten(t testing.TB, router http.Handler, longUrl string, fields url.Values) (int, Response) {
+	t.Helper()
+	return shortenWith(t, router, longUrl, fields, nil)
+}
+
+// shortenWith is shorten sending the given request headers.
+func shortenWith(t testing.TB, router http.Handler, longUrl string, fields url.Values, header http.Header) (int, Response) {
+	t.Helper()
+	form := url.Values{}
+	for name, values := range fields {
+		form[name] = values
+	}
+	form.Set("longUrl", base64.StdEncoding.EncodeToString([]byte(longUrl)))
+	w := serve(router, http.MethodPost, "/short", form, header)
+	var res Response
+	decode(t, w, &res)
+	return w.Code, res
+}
+
+// mustShorten creates a short link and returns its key, failing the test on any error.
+func mustShorten(t testing.TB, router http.Handler, longUrl string, fields url.Values) string {
+	t.Helper()
+	return mustShortenWith(t, router, longUrl, fields, nil)
+}
+
+// mustShortenWith is mustShorten sending the given request headers.
+func mustShortenWith(t testing.TB, router http.Handler, longUrl string, fields url.Values, header http.Header) string {
+	t.Helper()
+	code, res := shortenWith(t, router, longUrl, fields, header)
+	if code != http.StatusOK || res.Code != 1 {
+		t.Fatalf("shorten %s: status %d, %+v", longUrl, code, res)
+	}
+	key, ok := strings.CutPrefix(res.ShortUrl, "https://s.test/")
+	if !ok {
+		t.Fatalf("unexpected short url %q", res.ShortUrl)
+	}
+	return key
+}
+
+func TestCreateAndRedirect(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	key := mustShorten(t, router, "https://example.com/a?b=c", nil)
+	if len(key) != defaultShortUrlLen {
+		t.Fatalf("key %q has length %d, want %d", key, len(key), defaultShortUrlLen)
+	}
+
+	w := serve(router, http.MethodGet, "/"+key, nil, nil)
+	if w.Code != http.StatusMovedPermanently {
+		t.Fatalf("redirect status %d, want 301", w.Code)
+	}
+	if got := w.Header().Get("Location"); got != "https://example.com/a?b=c" {
+		t.Fatalf("Location %q", got)
+	}
+
+	if again := mustShorten(t, router, "https://example.com/a?b=c", nil); again != key {
+		t.Fatalf("same long url got key %q, want deduplicated %q", again, key)
+	}
+}
+
+func TestRedirectUnknownKey(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	if w := serve(router, http.MethodGet, "/nope42", nil, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("unknown key status %d, want 404", w.Code)
+	}
+}
+
+func TestCreateRejectsInvalidScheme(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	code, res := shorten(t, router, "javascript:alert(1)", nil)
+	if code != http.StatusBadRequest || res.Code != 0 {
+		t.Fatalf("status %d, %+v", code, res)
+	}
+}
+
+// runCommand runs a subcommand with args, returning its exit code and what it printed to stdout.
+// Its stderr is discarded.
+func runCommand(t testing.TB, command func(args []string) int, args ...string) (int, string) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = w, devNull
+	defer func() { os.Stdout, os.Stderr = stdout, stderr }()
+
+	out := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(r)
+		out <- data
+	}()
+	code := command(args)
+	w.Close()
+
+	return code, string(<-out)
+}
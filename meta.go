@@ -0,0 +1,99 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// defaultMetaPrefix is the default prefix for Redis link metadata.
+const defaultMetaPrefix = "myurls:meta:"
+
+// setLinkMeta stores metadata fields of a short key in a hash expiring together with the key.
+func setLinkMeta(shortKey string, fields map[string]interface{}) error {
+	redisClient := redisPool.Get()
+	defer redisClient.Close()
+
+	args := redis.Args{}.Add(redisKey(defaultMetaPrefix + shortKey)).AddFlat(fields)
+	if _, err := redisClient.Do("hset", args...); err != nil {
+		return err
+	}
+
+	return syncLinkExpiry(redisClient, shortKey)
+}
+
+// markLinkCreated records the creation time of a short key unless it was already recorded.
+func markLinkCreated(shortKey string) error {
+	redisClient := redisPool.Get()
+	defer redisClient.Close()
+
+	if _, err := redisClient.Do("hsetnx", redisKey(defaultMetaPrefix+shortKey), "createdAt", time.Now().Unix()); err != nil {
+		return err
+	}
+
+	return syncLinkExpiry(redisClient, shortKey)
+}
+
+// markLinkCreatedBy records the IP of the client creating a short key, keeping the original submitter
+// when an existing link is returned again.
+func markLinkCreatedBy(shortKey string, clientIP string) error {
+	redisClient := redisPool.Get()
+	defer redisClient.Close()
+
+	if _, err := redisClient.Do("hsetnx", redisKey(defaultMetaPrefix+shortKey), "createdBy", clientIP); err != nil {
+		return err
+	}
+
+	return syncLinkExpiry(redisClient, shortKey)
+}
+
+// linkCreatedAt parses the createdAt metadata field, zero when it is unknown.
+func linkCreatedAt(meta map[string]string) int64 {
+	createdAt, _ := strconv.ParseInt(meta["createdAt"], 10, 64)
+
+	return createdAt
+}
+
+// metaLinkRequest rebuilds the per-creator settings of a stored link from its metadata.
+func metaLinkRequest(meta map[string]string) linkRequest {
+	maxClicks, _ := strconv.Atoi(meta["maxClicks"])
+	redirectStatus, _ := strconv.Atoi(meta["redirectStatus"])
+
+	return linkRequest{
+		requireAuth:    meta["requireAuth"] == "1",
+		unlisted:       meta["unlisted"] == "1",
+		customTTL:      meta["customTTL"] == "1",
+		maxClicks:      maxClicks,
+		utm:            meta["utm"],
+		tags:           linkTags(meta),
+		languages:      meta["languages"],
+		platforms:      meta["platforms"],
+		redirectStatus: redirectStatus,
+	}
+}
+
+// getLinkMeta returns all metadata fields of a short key, empty if none were stored.
+func getLinkMeta(shortKey string) (map[string]string, error) {
+	redisClient := redisPool.Get()
+	defer redisClient.Close()
+
+	return redis.StringMap(redisClient.Do("hgetall", redisKey(defaultMetaPrefix+shortKey)))
+}
+
+// linkUnlisted reports whether a short key was created as private and is hidden from listings.
+func linkUnlisted(conn redis.Conn, shortKey string) bool {
+	unlisted, _ := redis.String(conn.Do("hget", redisKey(defaultMetaPrefix+shortKey), "unlisted"))
+
+	return unlisted == "1"
+}
+
+// linkDisabled reports whether an operator disabled a short key.
+func linkDisabled(shortKey string) bool {
+	redisClient := redisPool.Get()
+	defer redisClient.Close()
+
+	disabled, _ := redis.String(redisClient.Do("hget", redisKey(defaultMetaPrefix+shortKey), "disabled"))
+
+	return disabled == "1"
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registeredRoutes are the routes of the router, set once all routes are registered.
+var registeredRoutes gin.RoutesInfo
+
+// staticRoutes maps the paths of routes without parameters to their methods.
+var staticRoutes map[string][]string
+
+// setRoutes indexes the registered routes for the 405 responses.
+func setRoutes(routes gin.RoutesInfo) {
+	registeredRoutes = routes
+	staticRoutes = map[string][]string{}
+	for _, route := range routes {
+		if !strings.ContainsAny(route.Path, ":*") {
+			staticRoutes[route.Path] = append(staticRoutes[route.Path], route.Method)
+		}
+	}
+	for _, methods := range staticRoutes {
+		sort.Strings(methods)
+	}
+}
+
+// allowedMethods returns the methods registered for path. Like the router, routes with a
+// static path take precedence over routes with parameters.
+func allowedMethods(path string) []string {
+	if methods, ok := staticRoutes[path]; ok {
+		return methods
+	}
+
+	var methods []string
+	for _, route := range registeredRoutes {
+		if routeMatches(route.Path, path) {
+			methods = append(methods, route.Method)
+		}
+	}
+	sort.Strings(methods)
+
+	return methods
+}
+
+// routeMatches reports whether path matches a route pattern with :param and *wildcard segments.
+func routeMatches(pattern string, path string) bool {
+	patternParts := strings.Split(pattern, "/")
+	pathParts := strings.Split(path, "/")
+	for i, part := range patternParts {
+		if strings.HasPrefix(part, "*") {
+			return true
+		}
+		if i >= len(pathParts) {
+			return false
+		}
+		if strings.HasPrefix(part, ":") {
+			if pathParts[i] == "" {
+				return false
+			}
+			continue
+		}
+		if part != pathParts[i] {
+			return false
+		}
+	}
+
+	return len(patternParts) == len(pathParts)
+}
+
+// 请求方法不被允许，通过 Allow 头告知可用的方法
+func methodNotAllowed(context *gin.Context) {
+	context.Header("Allow", strings.Join(allowedMethods(context.Request.URL.Path), ", "))
+	respondError(context, http.StatusMethodNotAllowed, "请求方法不允许")
+}
+
+// 已注册路径的 OPTIONS 请求，通过 Allow 头告知可用的方法
+func optionsHandler(context *gin.Context) {
+	context.Header("Allow", strings.Join(allowedMethods(context.Request.URL.Path), ", "))
+	context.Status(http.StatusNoContent)
+}
+
+// shadowedRoute answers 405 when a short key path is in fact a route registered for other methods,
+// such as GET /short, reporting whether it did. The index path is never shadowed, since in
+// -subdomain-mode it resolves the short key of the Host.
+func shadowedRoute(context *gin.Context) bool {
+	path := context.Request.URL.Path
+	if _, ok := staticRoutes[path]; !ok || path == basePath+"/" {
+		return false
+	}
+	methodNotAllowed(context)
+
+	return true
+}
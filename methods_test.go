@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestMethodNotAllowed(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	for _, tc := range []struct {
+		method, path string
+		allow        string
+	}{
+		{http.MethodGet, "/short", "OPTIONS, POST"},
+		{http.MethodHead, "/short", "OPTIONS, POST"},
+		{http.MethodDelete, "/short", "OPTIONS, POST"},
+		{http.MethodPost, "/api", "GET"},
+		{http.MethodOptions, "/api", "GET"},
+		{http.MethodDelete, "/abc", "GET, HEAD, OPTIONS, PUT"},
+	} {
+		w := serve(router, tc.method, tc.path, nil, nil)
+		if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != tc.allow {
+			t.Errorf("%s %s: status %d, Allow %q", tc.method, tc.path, w.Code, w.Header().Get("Allow"))
+		}
+		if tc.method == http.MethodHead {
+			continue
+		}
+		var res Response
+		decode(t, w, &res)
+		if res.Code != 0 || res.Message == "" {
+			t.Errorf("%s %s: body %+v", tc.method, tc.path, res)
+		}
+	}
+
+	if w := serve(router, http.MethodOptions, "/short", nil, nil); w.Code != http.StatusNoContent || w.Header().Get("Allow") != "OPTIONS, POST" {
+		t.Fatalf("OPTIONS /short: status %d, Allow %q", w.Code, w.Header().Get("Allow"))
+	}
+
+	// 短链接本身不受影响
+	key := mustShorten(t, router, "https://example.com/methods", nil)
+	if w := serve(router, http.MethodGet, "/"+key, nil, nil); w.Code != http.StatusMovedPermanently {
+		t.Fatalf("redirect: status %d", w.Code)
+	}
+}
+
+func TestRouteMatches(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"/stats/:shortKey", "/stats/abc", true},
+		{"/stats/:shortKey", "/stats/", false},
+		{"/stats/:shortKey/history", "/stats/abc", false},
+		{"/public/*filepath", "/public/a/b.css", true},
+		{"/api", "/api", true},
+	} {
+		if got := routeMatches(tc.pattern, tc.path); got != tc.want {
+			t.Errorf("routeMatches(%q, %q) = %v, want %v", tc.pattern, tc.path, got, tc.want)
+		}
+	}
+}
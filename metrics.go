@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/hex"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// enableExemplars controls whether latency observations carry trace id exemplars.
+var enableExemplars bool
+
+// httpRequestDuration is the latency histogram of handled requests.
+var httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "myurls_http_request_duration_seconds",
+	Help:    "Latency of HTTP requests.",
+	Buckets: prometheus.DefBuckets,
+}, []string{"method", "route", "status"})
+
+// redisDials counts the connections dialed by the Redis pool, labelled by outcome.
+var redisDials = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "myurls_redis_pool_dials_total",
+	Help: "Connections dialed by the Redis pool.",
+}, []string{"result"})
+
+// redisPoolGauges report the Redis pool statistics at scrape time.
+// The redigo version in use only tracks active and idle connections, not waits.
+var redisPoolGauges = []prometheus.Collector{
+	prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "myurls_redis_pool_active_connections",
+		Help: "Connections in the Redis pool, idle or in use.",
+	}, func() float64 { return float64(currentPoolStats().ActiveCount) }),
+	prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "myurls_redis_pool_idle_connections",
+		Help: "Idle connections in the Redis pool.",
+	}, func() float64 { return float64(currentPoolStats().IdleCount) }),
+	prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "myurls_redis_pool_max_active_connections",
+		Help: "Configured maximum of connections in the Redis pool.",
+	}, func() float64 {
+		if redisPool == nil {
+			return 0
+		}
+		return float64(redisPool.MaxActive)
+	}),
+}
+
+func init() {
+	prometheus.MustRegister(httpRequestDuration, redisDials)
+	prometheus.MustRegister(redisPoolGauges...)
+}
+
+// currentPoolStats returns the Redis pool statistics, zero before the pool is created.
+func currentPoolStats() redis.PoolStats {
+	if redisPool == nil {
+		return redis.PoolStats{}
+	}
+
+	return redisPool.Stats()
+}
+
+// 定期输出 Redis 连接池状态，便于排查连接耗尽
+func logPoolStats(interval time.Duration) {
+	for range time.Tick(interval) {
+		stats := currentPoolStats()
+		log.Printf("Redis pool: active=%d idle=%d max=%d", stats.ActiveCount, stats.IdleCount, redisPool.MaxActive)
+	}
+}
+
+// traceIDFromRequest extracts the trace id from a W3C traceparent header, empty if absent or invalid.
+func traceIDFromRequest(c *gin.Context) string {
+	// traceparent: version-traceid-parentid-flags
+	parts := strings.Split(c.GetHeader("traceparent"), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || parts[1] == strings.Repeat("0", 32) {
+		return ""
+	}
+	if _, err := hex.DecodeString(parts[1]); err != nil {
+		return ""
+	}
+
+	return parts[1]
+}
+
+// 请求耗时指标
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		observer := httpRequestDuration.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status()))
+		elapsed := time.Since(startTime).Seconds()
+
+		if traceID := traceIDFromRequest(c); enableExemplars && traceID != "" {
+			observer.(prometheus.ExemplarObserver).ObserveWithExemplar(elapsed, prometheus.Labels{"trace_id": traceID})
+			return
+		}
+		observer.Observe(elapsed)
+	}
+}
+
+// metricsHandler serves the Prometheus metrics, in OpenMetrics format when exemplars are enabled.
+func metricsHandler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{
+		EnableOpenMetrics: enableExemplars,
+	}))
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	router, _ := newTestRouter(t)
+	mustShorten(t, router, "https://example.com/metrics", nil)
+
+	w := serve(router, http.MethodGet, "/metrics", nil, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d", w.Code)
+	}
+	for _, name := range []string{
+		"myurls_http_request_duration_seconds_bucket",
+		"myurls_redis_pool_dials_total",
+		"myurls_redis_pool_active_connections",
+		"myurls_redis_pool_idle_connections",
+		"myurls_redis_pool_max_active_connections 16",
+	} {
+		if !strings.Contains(w.Body.String(), name) {
+			t.Errorf("metric %s missing", name)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Platforms of the per-platform destinations, as detected from the User-Agent.
+const (
+	platformIOS     = "ios"
+	platformAndroid = "android"
+	platformDesktop = "desktop"
+)
+
+// errInvalidPlatforms is returned when the platforms parameter is not a JSON object of platforms to URLs.
+var errInvalidPlatforms = errors.New("platforms格式错误，应为 ios、android、desktop 到长链接的JSON对象")
+
+// parsePlatforms parses the platforms parameter of /short into its canonical JSON encoding,
+// validating every destination like the default long URL.
+func parsePlatforms(raw string) (string, error) {
+	params := map[string]string{}
+	if err := json.Unmarshal([]byte(raw), &params); err != nil {
+		return "", errInvalidPlatforms
+	}
+
+	destinations := make(map[string]string, len(params))
+	for platform, longUrl := range params {
+		platform = strings.ToLower(strings.TrimSpace(platform))
+		if platform != platformIOS && platform != platformAndroid && platform != platformDesktop {
+			return "", errInvalidPlatforms
+		}
+		if err := validateLongUrl(longUrl); err != nil {
+			return "", fmt.Errorf("platforms中 %s 的长链接无效: %v", platform, err)
+		}
+		destinations[platform] = longUrl
+	}
+	if len(destinations) == 0 {
+		return "", nil
+	}
+
+	// map 按键排序编码，相同参数得到相同的存储值
+	encoded, _ := json.Marshal(destinations)
+
+	return string(encoded), nil
+}
+
+// linkPlatforms decodes the platforms metadata field, nil when the link has none.
+func linkPlatforms(meta map[string]string) map[string]string {
+	if meta["platforms"] == "" {
+		return nil
+	}
+
+	destinations := map[string]string{}
+	if err := json.Unmarshal([]byte(meta["platforms"]), &destinations); err != nil {
+		return nil
+	}
+
+	return destinations
+}
+
+// detectPlatform buckets a User-Agent into ios, android or desktop by plain substring matching.
+func detectPlatform(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	switch {
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipad"), strings.Contains(ua, "ipod"):
+		return platformIOS
+	case strings.Contains(ua, "android"):
+		return platformAndroid
+	default:
+		return platformDesktop
+	}
+}
+
+// targetUrl returns the destination of a resolved short key for a request: the destination of its platform,
+// then of its most preferred language, then its long URL. Destinations failing the integrity check are ignored.
+// It also returns the request headers the choice depended on, for the Vary header of the redirect.
+func targetUrl(shortKey string, link resolvedLink, header http.Header) (string, []string) {
+	longUrl, meta := link.longUrl, link.meta
+	// 校验失败的备选目标地址不予使用，回退到已校验的长链接
+	if verifyDestinations(shortKey, meta["platforms"], meta["languages"], meta["destinationsHmac"]) != nil {
+		return longUrl, nil
+	}
+
+	var vary []string
+	if platforms := linkPlatforms(meta); len(platforms) > 0 {
+		vary = append(vary, "User-Agent")
+		if destination, ok := platforms[detectPlatform(header.Get("User-Agent"))]; ok {
+			return destination, vary
+		}
+	}
+	if languages := linkLanguages(meta); len(languages) > 0 {
+		vary = append(vary, "Accept-Language")
+		if destination, ok := matchLanguage(languages, header.Get("Accept-Language")); ok {
+			return destination, vary
+		}
+	}
+
+	return longUrl, vary
+}
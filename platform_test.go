@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+// Sample User-Agent strings of the detected platforms.
+const (
+	iphoneUA  = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 Mobile/15E148"
+	androidUA = "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 Chrome/120.0 Mobile Safari/537.36"
+	desktopUA = "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 Chrome/120.0 Safari/537.36"
+)
+
+func TestDetectPlatform(t *testing.T) {
+	for ua, want := range map[string]string{
+		iphoneUA:             platformIOS,
+		"Mozilla/5.0 (iPad)": platformIOS,
+		androidUA:            platformAndroid,
+		desktopUA:            platformDesktop,
+		"":                   platformDesktop,
+	} {
+		if got := detectPlatform(ua); got != want {
+			t.Errorf("detectPlatform(%q) = %q, want %q", ua, got, want)
+		}
+	}
+}
+
+func TestPlatformDestinations(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	key := mustShorten(t, router, "https://example.com/web", url.Values{
+		"platforms": {`{"ios":"https://apps.example.com/ios","android":"https://apps.example.com/android"}`},
+		"languages": {`{"de":"https://example.com/de"}`},
+	})
+	for _, tc := range []struct {
+		ua, acceptLanguage string
+		want, vary         string
+	}{
+		{iphoneUA, "de", "https://apps.example.com/ios", "User-Agent"},
+		{androidUA, "", "https://apps.example.com/android", "User-Agent"},
+		{desktopUA, "de", "https://example.com/de", "User-Agent, Accept-Language"},
+		{desktopUA, "", "https://example.com/web", "User-Agent, Accept-Language"},
+	} {
+		w := serve(router, http.MethodGet, "/"+key, nil, http.Header{"User-Agent": {tc.ua}, "Accept-Language": {tc.acceptLanguage}})
+		if got := w.Header().Get("Location"); got != tc.want {
+			t.Errorf("%q, %q: Location %q, want %q", tc.ua, tc.acceptLanguage, got, tc.want)
+		}
+		if got := w.Header().Get("Vary"); got != tc.vary {
+			t.Errorf("%q, %q: Vary %q, want %q", tc.ua, tc.acceptLanguage, got, tc.vary)
+		}
+	}
+
+	// HEAD 请求返回同样的目标地址
+	w := serve(router, http.MethodHead, "/"+key, nil, http.Header{"User-Agent": {androidUA}})
+	if got := w.Header().Get("Location"); got != "https://apps.example.com/android" {
+		t.Fatalf("HEAD Location %q", got)
+	}
+
+	if code, _ := shorten(t, router, "https://example.com/bad", url.Values{"platforms": {`{"windows":"https://example.com/"}`}}); code != http.StatusBadRequest {
+		t.Fatalf("unknown platform: status %d, want 400", code)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"encoding/base64"
+	"log"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// qrModuleSize is the size in pixels of a QR code module in the rendered PNG.
+const qrModuleSize = 4
+
+// qrDataUri renders content as a QR code PNG data URI at error correction level M,
+// in the smallest version it fits, with the standard quiet zone.
+func qrDataUri(content string) (string, error) {
+	png, err := qrcode.Encode(content, qrcode.Medium, -qrModuleSize)
+	if err != nil {
+		return "", err
+	}
+
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(png), nil
+}
+
+// shortUrlQr returns the QR code data URI of a created short URL, empty when it can't be rendered
+// since the link itself is already stored.
+func shortUrlQr(shortUrl string) string {
+	dataUri, err := qrDataUri(shortUrl)
+	if err != nil {
+		log.Println("QR code generation failed:", err)
+	}
+
+	return dataUri
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// decodeQrImage decodes a PNG data URI into its image.
+func decodeQrImage(t testing.TB, dataUri string) image.Image {
+	t.Helper()
+	encoded, ok := strings.CutPrefix(dataUri, "data:image/png;base64,")
+	if !ok {
+		t.Fatalf("not a PNG data URI: %.40s", dataUri)
+	}
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func TestQrDataUri(t *testing.T) {
+	for _, content := range []string{
+		"HELLO",
+		"https://s.test/abc",
+		"https://s.test/" + strings.Repeat("a", 175),
+		// 超出版本10的容量
+		"https://s.test/" + strings.Repeat("a", 2000),
+	} {
+		dataUri, err := qrDataUri(content)
+		if err != nil {
+			t.Fatalf("%d bytes: %v", len(content), err)
+		}
+		code, _ := qrcode.New(content, qrcode.Medium)
+		if got, want := decodeQrImage(t, dataUri).Bounds().Dx(), len(code.Bitmap())*qrModuleSize; got != want {
+			t.Errorf("%d bytes: image width %d, want %d", len(content), got, want)
+		}
+	}
+
+	if _, err := qrDataUri(strings.Repeat("a", 3000)); err == nil {
+		t.Fatal("oversized content encoded")
+	}
+}
+
+func TestIncludeQr(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	code, res := shorten(t, router, "https://example.com/qr", url.Values{"includeQr": {"1"}})
+	if code != http.StatusOK || res.QrDataUri == "" {
+		t.Fatalf("status %d, %+v", code, res)
+	}
+	decodeQrImage(t, res.QrDataUri)
+	if _, res := shorten(t, router, "https://example.com/noqr", nil); res.QrDataUri != "" {
+		t.Fatal("QR code returned without includeQr")
+	}
+}
+
+// sampleQr reads the module grid of a rendered QR code, grid[row][column] is true for dark modules.
+func sampleQr(img image.Image) [][]bool {
+	size := img.Bounds().Dx() / qrModuleSize
+	grid := make([][]bool, size)
+	for row := range grid {
+		grid[row] = make([]bool, size)
+		for col := range grid[row] {
+			r, _, _, _ := img.At(col*qrModuleSize+qrModuleSize/2, row*qrModuleSize+qrModuleSize/2).RGBA()
+			grid[row][col] = r < 0x8000
+		}
+	}
+	return grid
+}
+
+// renders reports whether the QR code image of dataUri shows the level M symbol of content.
+func renders(t testing.TB, dataUri string, content string) bool {
+	t.Helper()
+	code, err := qrcode.New(content, qrcode.Medium)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reflect.DeepEqual(sampleQr(decodeQrImage(t, dataUri)), code.Bitmap())
+}
+
+func TestQrPayload(t *testing.T) {
+	for _, content := range []string{"https://s.test/abc", "https://s.test/" + strings.Repeat("d", 175)} {
+		dataUri, err := qrDataUri(content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !renders(t, dataUri, content) {
+			t.Errorf("QR code image of %q doesn't match its symbol", content)
+		}
+	}
+
+	router, _ := newTestRouter(t)
+	code, res := shorten(t, router, "https://example.com/scan", url.Values{"includeQr": {"1"}})
+	if code != http.StatusOK {
+		t.Fatalf("status %d, %+v", code, res)
+	}
+	if !renders(t, res.QrDataUri, res.ShortUrl) {
+		t.Fatalf("QR code of the response doesn't encode %q", res.ShortUrl)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// defaultReadOnlyProbeKey is the Redis key written to detect that writes are accepted again.
+const defaultReadOnlyProbeKey = "myurls:probe"
+
+// readOnlyProbeInterval is how often a read-only Redis is probed for recovery.
+var readOnlyProbeInterval = 10 * time.Second
+
+// errStorageReadOnly is returned when Redis rejects writes.
+var errStorageReadOnly = errors.New("存储服务只读，暂停创建")
+
+// errMaintenance is returned while the operator suspended writes.
+var errMaintenance = errors.New("服务维护中，暂停创建")
+
+// maintenanceMode is set by -readonly or the admin endpoint, redirects keep being served.
+var maintenanceMode atomic.Bool
+
+// storageReadOnly is set while Redis rejects writes, redirects keep being served.
+var storageReadOnly atomic.Bool
+
+// readOnlyGauge reports whether the service degraded to read-only mode.
+var readOnlyGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "myurls_storage_readonly",
+	Help: "Whether Redis rejects writes and link creation is suspended.",
+})
+
+func init() {
+	prometheus.MustRegister(readOnlyGauge)
+}
+
+// writesSuspended returns the reason link writes are currently rejected, nil when they are accepted.
+func writesSuspended() error {
+	if maintenanceMode.Load() {
+		return errMaintenance
+	}
+	if storageReadOnly.Load() {
+		return errStorageReadOnly
+	}
+
+	return nil
+}
+
+// 开启维护模式，暂停创建与修改短链接
+func enableMaintenanceHandler(context *gin.Context) {
+	maintenanceMode.Store(true)
+	log.Println("Maintenance mode enabled")
+	context.JSON(http.StatusOK, Response{Code: 1, Message: errMaintenance.Error()})
+}
+
+// 关闭维护模式
+func disableMaintenanceHandler(context *gin.Context) {
+	maintenanceMode.Store(false)
+	log.Println("Maintenance mode disabled")
+	context.JSON(http.StatusOK, Response{Code: 1})
+}
+
+// isReadOnlyError reports whether err is a READONLY reply from a replica.
+func isReadOnlyError(err error) bool {
+	var redisErr redis.Error
+
+	return errors.As(err, &redisErr) && strings.HasPrefix(string(redisErr), "READONLY")
+}
+
+// noteWriteError switches to read-only mode if err is a READONLY reply, reporting whether it was.
+func noteWriteError(err error) bool {
+	if !isReadOnlyError(err) {
+		return false
+	}
+
+	if storageReadOnly.CompareAndSwap(false, true) {
+		log.Println("Redis rejects writes, switching to read-only mode:", err)
+		readOnlyGauge.Set(1)
+		go watchReadOnlyRecovery()
+	}
+
+	return true
+}
+
+// watchReadOnlyRecovery probes Redis until a write succeeds, then leaves read-only mode.
+func watchReadOnlyRecovery() {
+	ticker := time.NewTicker(readOnlyProbeInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		redisClient := redisPool.Get()
+		_, err := redisClient.Do("set", redisKey(defaultReadOnlyProbeKey), time.Now().Unix(), "ex", 60)
+		redisClient.Close()
+
+		if err == nil {
+			storageReadOnly.Store(false)
+			readOnlyGauge.Set(0)
+			log.Println("Redis accepts writes again, leaving read-only mode")
+			return
+		}
+	}
+}
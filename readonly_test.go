@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+func TestStorageReadOnly(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	key := mustShorten(t, router, "https://example.com/ro", nil)
+	if !isReadOnlyError(redis.Error("READONLY You can't write against a read only replica.")) {
+		t.Fatal("READONLY reply not detected")
+	}
+	if isReadOnlyError(redis.Error("ERR wrong number of arguments")) || isReadOnlyError(errors.New("READONLY")) {
+		t.Fatal("other errors detected as READONLY")
+	}
+
+	storageReadOnly.Store(true)
+	defer storageReadOnly.Store(false)
+
+	code, res := shorten(t, router, "https://example.com/new", nil)
+	if code != http.StatusServiceUnavailable || res.Message != errStorageReadOnly.Error() {
+		t.Fatalf("create while read-only: status %d, %+v", code, res)
+	}
+	if w := serve(router, http.MethodGet, "/"+key, nil, nil); w.Code != http.StatusMovedPermanently {
+		t.Fatalf("redirect while read-only: status %d", w.Code)
+	}
+}
+
+func TestMaintenanceMode(t *testing.T) {
+	router, _ := newTestRouter(t)
+	setFlag(t, &adminToken, "secret-admin")
+
+	key := mustShorten(t, router, "https://example.com/maint", nil)
+	if w := serve(router, http.MethodPost, "/admin/readonly", nil, bearer(adminToken)); w.Code != http.StatusOK {
+		t.Fatalf("enable maintenance: status %d", w.Code)
+	}
+
+	if code, res := shorten(t, router, "https://example.com/new", nil); code != http.StatusServiceUnavailable || res.Message != errMaintenance.Error() {
+		t.Fatalf("create during maintenance: status %d, %+v", code, res)
+	}
+	if w := serve(router, http.MethodPut, "/"+key, updateForm("https://example.com/x"), bearer(adminToken)); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("update during maintenance: status %d", w.Code)
+	}
+	if w := serve(router, http.MethodGet, "/"+key, nil, nil); w.Code != http.StatusMovedPermanently {
+		t.Fatalf("redirect during maintenance: status %d", w.Code)
+	}
+
+	serve(router, http.MethodDelete, "/admin/readonly", nil, bearer(adminToken))
+	mustShorten(t, router, "https://example.com/new", url.Values{})
+}
+
+// readCommands are the commands a read-only replica still accepts.
+var readCommands = map[string]bool{
+	"get": true, "ttl": true, "pttl": true, "exists": true, "hget": true, "hmget": true, "hgetall": true,
+	"smembers": true, "zscore": true, "zrange": true, "pfcount": true, "scan": true, "ping": true,
+}
+
+// replicaConn rejects writes with a READONLY reply while rejectWrites is set, like a Redis replica.
+type replicaConn struct {
+	redis.Conn
+	rejectWrites *atomic.Bool
+	// pending records, for every sent command, whether its reply is a rejection.
+	pending []bool
+}
+
+func (c *replicaConn) rejects(command string) bool {
+	return c.rejectWrites.Load() && !readCommands[strings.ToLower(command)]
+}
+
+func (c *replicaConn) Do(command string, args ...interface{}) (interface{}, error) {
+	if c.rejects(command) {
+		return nil, redis.Error("READONLY You can't write against a read only replica.")
+	}
+	return c.Conn.Do(command, args...)
+}
+
+func (c *replicaConn) Send(command string, args ...interface{}) error {
+	rejected := c.rejects(command)
+	c.pending = append(c.pending, rejected)
+	if rejected {
+		return nil
+	}
+	return c.Conn.Send(command, args...)
+}
+
+func (c *replicaConn) Receive() (interface{}, error) {
+	rejected := c.pending[0]
+	c.pending = c.pending[1:]
+	if rejected {
+		return nil, redis.Error("READONLY You can't write against a read only replica.")
+	}
+	return c.Conn.Receive()
+}
+
+func TestReadOnlyReplicaRedirects(t *testing.T) {
+	router, mr := newTestRouter(t)
+	setFlag(t, &readOnlyProbeInterval, 10*time.Millisecond)
+	var rejectWrites atomic.Bool
+	redisPool = &redis.Pool{Dial: func() (redis.Conn, error) {
+		conn, err := redis.Dial("tcp", mr.Addr())
+		return &replicaConn{Conn: conn, rejectWrites: &rejectWrites}, err
+	}}
+
+	key := mustShorten(t, router, "https://example.com/replica", nil)
+	limited := mustShorten(t, router, "https://example.com/limited", url.Values{"maxClicks": {"5"}})
+	rejectWrites.Store(true)
+
+	// 计数与续命失败不影响跳转，限次链接无法计数时不跳转
+	if w := serve(router, http.MethodGet, "/"+key, nil, nil); w.Code != http.StatusMovedPermanently {
+		t.Fatalf("redirect on a read-only replica: status %d, %s", w.Code, w.Body)
+	}
+	if w := serve(router, http.MethodGet, "/"+limited, nil, nil); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("limited link on a read-only replica: status %d, want 503", w.Code)
+	}
+	if !storageReadOnly.Load() {
+		t.Fatal("READONLY reply not noticed")
+	}
+	if code, _ := shorten(t, router, "https://example.com/new", nil); code != http.StatusServiceUnavailable {
+		t.Fatalf("create on a read-only replica: status %d, want 503", code)
+	}
+
+	rejectWrites.Store(false)
+	deadline := time.Now().Add(time.Second)
+	for storageReadOnly.Load() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if storageReadOnly.Load() {
+		t.Fatal("still read-only after the replica accepted writes again")
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// redirectStatus is the default status code of short link redirects, 301 or 302.
+var redirectStatus = http.StatusMovedPermanently
+
+// passthroughQuery controls whether the query string of a short link request is forwarded to the destination.
+var passthroughQuery bool
+
+// permanentCacheControl is the default Cache-Control of permanent redirects.
+const permanentCacheControl = "public, max-age=86400"
+
+// temporaryCacheControl is the default Cache-Control of temporary redirects.
+const temporaryCacheControl = "no-store"
+
+// redirectHeaders are the extra response headers set on redirects, configured with -header.
+var redirectHeaders = headerFlag{}
+
+// headerFlag collects repeated -header key=value flags.
+type headerFlag http.Header
+
+func (h headerFlag) String() string {
+	pairs := make([]string, 0, len(h))
+	for key, values := range h {
+		for _, value := range values {
+			pairs = append(pairs, key+"="+value)
+		}
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+func (h headerFlag) Set(raw string) error {
+	key, value, ok := strings.Cut(raw, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" || strings.ContainsAny(key, " \t\r\n:") {
+		return fmt.Errorf("响应头需为 key=value 格式: %q", raw)
+	}
+	if strings.ContainsAny(value, "\r\n") {
+		return fmt.Errorf("响应头的值不能包含换行: %q", raw)
+	}
+	http.Header(h).Add(key, strings.TrimSpace(value))
+
+	return nil
+}
+
+// 设置跳转响应头，未配置 Cache-Control 时按跳转状态码选择默认值
+func setRedirectHeaders(context *gin.Context, status int) {
+	header := context.Writer.Header()
+	for key, values := range redirectHeaders {
+		for _, value := range values {
+			header.Add(key, value)
+		}
+	}
+	if header.Get("Cache-Control") != "" {
+		return
+	}
+	if status == http.StatusMovedPermanently || status == http.StatusPermanentRedirect {
+		header.Set("Cache-Control", permanentCacheControl)
+	} else {
+		header.Set("Cache-Control", temporaryCacheControl)
+	}
+}
+
+// validRedirectStatus reports whether status is a supported redirect status code.
+func validRedirectStatus(status int) bool {
+	return status == http.StatusMovedPermanently || status == http.StatusFound
+}
+
+// linkRedirectStatus returns the redirect status stored for a resolved short key, falling back to -redirect-status.
+func linkRedirectStatus(link resolvedLink) int {
+	status, err := strconv.Atoi(link.meta["redirectStatus"])
+	if err != nil || !validRedirectStatus(status) {
+		return redirectStatus
+	}
+
+	return status
+}
+
+// 检查短链接是否存在，返回跳转目标但不计数、不续命
+func headHandler(context *gin.Context) {
+	if shadowedRoute(context) {
+		return
+	}
+	setCorsHeaders(context)
+	shortKey := normalizeShortKey(context.Param("shortKey"))
+
+	if !hostAllowed(context.Request.Host) || !validChecksum(shortKey) {
+		context.Status(http.StatusNotFound)
+		return
+	}
+
+	redisClient := redisPool.Get()
+	defer redisClient.Close()
+
+	link, err := resolveLink(redisClient, shortKey)
+	if link.requireAuth() && !authorizePrivateLink(context.GetHeader("Authorization")) {
+		context.Status(http.StatusUnauthorized)
+		return
+	}
+	longUrl := link.longUrl
+	if err == nil && longUrl != "" && !isPlaceholder(longUrl) {
+		longUrl, _ = targetUrl(shortKey, link, context.Request.Header)
+	}
+	switch {
+	case errors.Is(err, errLinkDisabled):
+		context.Status(http.StatusForbidden)
+	case err != nil:
+		context.Status(http.StatusInternalServerError)
+	case longUrl == "":
+		respondMissing(context, shortKey)
+	case isPlaceholder(longUrl):
+		context.Status(http.StatusOK)
+	case redirectOnly && !destinationAllowed(longUrl):
+		context.Status(http.StatusForbidden)
+	default:
+		longUrl = appendUtm(link, longUrl)
+		if passthroughQuery {
+			longUrl = mergeQuery(longUrl, context.Request.URL.Query())
+		}
+		context.Header("Location", encodeLocation(longUrl))
+		context.Status(http.StatusOK)
+	}
+}
+
+// mergeQuery appends the incoming query parameters to longUrl, keeping the stored value of any parameter present in both.
+func mergeQuery(longUrl string, incoming url.Values) string {
+	if len(incoming) == 0 {
+		return longUrl
+	}
+
+	u, err := url.Parse(longUrl)
+	if err != nil {
+		return longUrl
+	}
+
+	existing := u.Query()
+	extra := url.Values{}
+	for key, values := range incoming {
+		if _, ok := existing[key]; !ok {
+			extra[key] = values
+		}
+	}
+	if len(extra) == 0 {
+		return longUrl
+	}
+
+	if u.RawQuery == "" {
+		u.RawQuery = extra.Encode()
+	} else {
+		u.RawQuery += "&" + extra.Encode()
+	}
+
+	return u.String()
+}
+
+// encodeLocation re-encodes a long URL for use in the Location header, escaping spaces and
+// non-ASCII characters while leaving existing percent-encoded sequences untouched.
+func encodeLocation(longUrl string) string {
+	u, err := url.Parse(longUrl)
+	if err != nil {
+		return longUrl
+	}
+	u.RawQuery = escapeQuery(u.RawQuery)
+
+	return u.String()
+}
+
+// escapeQuery percent-encodes the bytes of a raw query that are not allowed in a URL,
+// keeping valid escapes and the query delimiters as they are.
+func escapeQuery(rawQuery string) string {
+	var b strings.Builder
+	for i := 0; i < len(rawQuery); i++ {
+		c := rawQuery[i]
+		switch {
+		case c == '%' && i+2 < len(rawQuery) && isHex(rawQuery[i+1]) && isHex(rawQuery[i+2]):
+			b.WriteByte(c)
+		case c > 0x20 && c < 0x7f && !strings.ContainsRune("%\"<>\\^`{|}", rune(c)):
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
+
+// isHex reports whether c is a hexadecimal digit.
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
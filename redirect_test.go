@@ -0,0 +1,270 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+func TestRedirectPreview(t *testing.T) {
+	router, mr := newTestRouter(t)
+
+	key := mustShorten(t, router, "https://example.com/preview", nil)
+	for _, target := range []string{"/" + key + "+", "/" + key + "?preview=1"} {
+		w := serve(router, http.MethodGet, target, nil, nil)
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "https://example.com/preview") {
+			t.Fatalf("%s: status %d", target, w.Code)
+		}
+		if w.Header().Get("Location") != "" {
+			t.Fatalf("%s redirected", target)
+		}
+	}
+	if mr.Exists(defaultHitsPrefix + key) {
+		t.Fatal("preview counted a click")
+	}
+}
+
+func TestRedirectLocationEncoding(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	for _, tc := range []struct {
+		longUrl string
+		want    string
+	}{
+		{"https://example.com/a b", "https://example.com/a%20b"},
+		{"https://example.com/路径", "https://example.com/%E8%B7%AF%E5%BE%84"},
+		{"https://example.com/?q=a%20b&r=1", "https://example.com/?q=a%20b&r=1"},
+		{"https://example.com/?q=a b", "https://example.com/?q=a%20b"},
+	} {
+		key := mustShorten(t, router, tc.longUrl, nil)
+		if got := serve(router, http.MethodGet, "/"+key, nil, nil).Header().Get("Location"); got != tc.want {
+			t.Errorf("%q: Location %q, want %q", tc.longUrl, got, tc.want)
+		}
+	}
+}
+
+func TestRedirectPassthroughQuery(t *testing.T) {
+	router, _ := newTestRouter(t)
+	setFlag(t, &passthroughQuery, true)
+
+	plain := mustShorten(t, router, "https://example.com/plain", nil)
+	query := mustShorten(t, router, "https://example.com/query?a=1", nil)
+
+	for _, tc := range []struct {
+		target string
+		want   string
+	}{
+		{"/" + plain + "?x=2", "https://example.com/plain?x=2"},
+		{"/" + query + "?x=2", "https://example.com/query?a=1&x=2"},
+		{"/" + query + "?a=9", "https://example.com/query?a=1"},
+		{"/" + query, "https://example.com/query?a=1"},
+	} {
+		if got := serve(router, http.MethodGet, tc.target, nil, nil).Header().Get("Location"); got != tc.want {
+			t.Errorf("%s: Location %q, want %q", tc.target, got, tc.want)
+		}
+	}
+}
+
+func TestRedirectHeaders(t *testing.T) {
+	router, _ := newTestRouter(t)
+	headers := headerFlag{}
+	for _, raw := range []string{"X-Robots-Tag=noindex", "Cache-Control=private, max-age=60"} {
+		if err := headers.Set(raw); err != nil {
+			t.Fatal(err)
+		}
+	}
+	setFlag(t, &redirectHeaders, headers)
+	setFlag(t, &redirectStatus, http.StatusFound)
+
+	key := mustShorten(t, router, "https://example.com/headers", nil)
+	w := serve(router, http.MethodGet, "/"+key, nil, nil)
+	if w.Code != http.StatusFound {
+		t.Fatalf("status %d, want 302", w.Code)
+	}
+	if w.Header().Get("X-Robots-Tag") != "noindex" || w.Header().Get("Cache-Control") != "private, max-age=60" {
+		t.Fatalf("headers %v", w.Header())
+	}
+
+	for _, raw := range []string{"novalue", "=x", "Bad Key=x", "X-A=b\r\nX-B: c"} {
+		if err := (headerFlag{}).Set(raw); err == nil {
+			t.Errorf("-header %q accepted", raw)
+		}
+	}
+}
+
+func TestRedirectDefaultCacheControl(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	key := mustShorten(t, router, "https://example.com/cache", nil)
+	if got := serve(router, http.MethodGet, "/"+key, nil, nil).Header().Get("Cache-Control"); got != permanentCacheControl {
+		t.Fatalf("301 Cache-Control %q", got)
+	}
+	setFlag(t, &redirectStatus, http.StatusFound)
+	if got := serve(router, http.MethodGet, "/"+key, nil, nil).Header().Get("Cache-Control"); got != temporaryCacheControl {
+		t.Fatalf("302 Cache-Control %q", got)
+	}
+}
+
+func TestRedirectStatusPerLink(t *testing.T) {
+	router, _ := newTestRouter(t)
+	setFlag(t, &redirectStatus, http.StatusFound)
+
+	permanent := mustShorten(t, router, "https://example.com/permanent", url.Values{"redirectStatus": {"301"}})
+	temporary := mustShorten(t, router, "https://example.com/temporary", nil)
+
+	if w := serve(router, http.MethodGet, "/"+permanent, nil, nil); w.Code != http.StatusMovedPermanently {
+		t.Fatalf("link created with 301: status %d", w.Code)
+	}
+	if w := serve(router, http.MethodGet, "/"+temporary, nil, nil); w.Code != http.StatusFound {
+		t.Fatalf("link using the default: status %d", w.Code)
+	}
+	if code, _ := shorten(t, router, "https://example.com/bad", url.Values{"redirectStatus": {"307"}}); code != http.StatusBadRequest {
+		t.Fatalf("redirectStatus 307: status %d, want 400", code)
+	}
+}
+
+func TestHeadLink(t *testing.T) {
+	router, mr := newTestRouter(t)
+
+	key := mustShorten(t, router, "https://example.com/head", nil)
+	ttl := mr.TTL(key)
+
+	w := serve(router, http.MethodHead, "/"+key, nil, nil)
+	if w.Code != http.StatusOK || w.Header().Get("Location") != "https://example.com/head" {
+		t.Fatalf("HEAD existing: status %d, Location %q", w.Code, w.Header().Get("Location"))
+	}
+	if mr.Exists(defaultHitsPrefix+key) || mr.TTL(key) != ttl {
+		t.Fatal("HEAD counted a click or renewed the link")
+	}
+	if w := serve(router, http.MethodHead, "/nope42", nil, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("HEAD missing: status %d, want 404", w.Code)
+	}
+}
+
+func TestRedirectMaxClicks(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	key := mustShorten(t, router, "https://example.com/once", url.Values{"maxClicks": {"1"}})
+	if w := serve(router, http.MethodGet, "/"+key, nil, nil); w.Code != http.StatusMovedPermanently {
+		t.Fatalf("first click: status %d", w.Code)
+	}
+	if w := serve(router, http.MethodGet, "/"+key, nil, nil); w.Code != http.StatusGone {
+		t.Fatalf("second click: status %d, want 410", w.Code)
+	}
+}
+
+func TestRedirectMaxClicksUncounted(t *testing.T) {
+	router, mr := newTestRouter(t)
+
+	// 计数器无法递增时，限次链接不跳转，普通链接照常跳转
+	limited := mustShorten(t, router, "https://example.com/limited", url.Values{"maxClicks": {"5"}})
+	plain := mustShorten(t, router, "https://example.com/plain", nil)
+	for _, key := range []string{limited, plain} {
+		mr.HSet(defaultHitsPrefix+key, "broken", "1")
+	}
+	if w := serve(router, http.MethodGet, "/"+limited, nil, nil); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("limited link without a counter: status %d, want 503", w.Code)
+	}
+	if w := serve(router, http.MethodGet, "/"+plain, nil, nil); w.Code != http.StatusMovedPermanently {
+		t.Fatalf("plain link without a counter: status %d, want 301", w.Code)
+	}
+}
+
+func TestMergeQuery(t *testing.T) {
+	for _, tc := range []struct {
+		longUrl  string
+		incoming url.Values
+		want     string
+	}{
+		{"https://e.com/", nil, "https://e.com/"},
+		{"https://e.com/", url.Values{"a": {"1"}}, "https://e.com/?a=1"},
+		{"https://e.com/?a=1", url.Values{"b": {"2"}}, "https://e.com/?a=1&b=2"},
+		{"https://e.com/?a=1", url.Values{"a": {"2"}}, "https://e.com/?a=1"},
+		{"https://e.com/?a=1#top", url.Values{"b": {"2"}}, "https://e.com/?a=1&b=2#top"},
+	} {
+		if got := mergeQuery(tc.longUrl, tc.incoming); got != tc.want {
+			t.Errorf("mergeQuery(%q, %v) = %q, want %q", tc.longUrl, tc.incoming, got, tc.want)
+		}
+	}
+}
+
+// roundTripConn counts the round trips to Redis: every Do and every Flush of a pipeline.
+type roundTripConn struct {
+	redis.Conn
+	roundTrips *atomic.Int64
+}
+
+func (c roundTripConn) Do(command string, args ...interface{}) (interface{}, error) {
+	if command != "" {
+		c.roundTrips.Add(1)
+	}
+	return c.Conn.Do(command, args...)
+}
+
+func (c roundTripConn) Flush() error {
+	c.roundTrips.Add(1)
+	return c.Conn.Flush()
+}
+
+func TestRedirectRoundTrips(t *testing.T) {
+	router, mr := newTestRouter(t)
+	setFlag(t, &linkSecret, "integrity-secret")
+	var roundTrips atomic.Int64
+	redisPool = &redis.Pool{Dial: func() (redis.Conn, error) {
+		conn, err := redis.Dial("tcp", mr.Addr())
+		return roundTripConn{Conn: conn, roundTrips: &roundTrips}, err
+	}}
+
+	plain := mustShorten(t, router, "https://example.com/plain", nil)
+	campaign := mustShorten(t, router, "https://example.com/campaign", url.Values{
+		"utm":            {`{"utm_source":"mail"}`},
+		"platforms":      {`{"ios":"https://apps.example.com/ios"}`},
+		"redirectStatus": {"302"},
+		"maxClicks":      {"10"},
+	})
+
+	for _, tc := range []struct {
+		key      string
+		status   int
+		location string
+	}{
+		{plain, http.StatusMovedPermanently, "https://example.com/plain"},
+		{campaign, http.StatusFound, "https://apps.example.com/ios?utm_source=mail"},
+	} {
+		// 首次访问还需同步计数器有效期并续命
+		serve(router, http.MethodGet, "/"+tc.key, nil, http.Header{"User-Agent": {iphoneUA}})
+
+		roundTrips.Store(0)
+		w := serve(router, http.MethodGet, "/"+tc.key, nil, http.Header{"User-Agent": {iphoneUA}, "Referer": {"https://news.test/"}})
+		if w.Code != tc.status || w.Header().Get("Location") != tc.location {
+			t.Fatalf("%s: status %d, Location %q", tc.key, w.Code, w.Header().Get("Location"))
+		}
+		// 读取元数据一次往返，写入访问统计一次往返
+		if got := roundTrips.Load(); got != 2 {
+			t.Fatalf("%s: %d round trips per redirect, want 2", tc.key, got)
+		}
+	}
+
+	if got, _ := mr.Get(defaultHitsPrefix + campaign); got != "2" {
+		t.Fatalf("pipelined click count %q, want 2", got)
+	}
+	if got := mr.HGet(defaultRefPrefix+campaign, "news.test"); got != "1" {
+		t.Fatalf("pipelined referrer count %q", got)
+	}
+	if mr.TTL(defaultRefPrefix+campaign) <= 0 {
+		t.Fatal("referrer hash created without ttl")
+	}
+
+	// 不存在的短链接仅读取链接与墓碑，不写入任何统计
+	roundTrips.Store(0)
+	if w := serve(router, http.MethodGet, "/nope42", nil, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("missing key: status %d", w.Code)
+	}
+	if got := roundTrips.Load(); got != 2 || mr.Exists(defaultHitsPrefix+"nope42") {
+		t.Fatalf("missing key: %d round trips", got)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultRedisPort is the port assumed when the connection string omits it.
+const defaultRedisPort = "6379"
+
+// maxRedisWaitBackoff caps the delay between two startup pings.
+const maxRedisWaitBackoff = 30 * time.Second
+
+// redisEndpoint is a parsed -conn value.
+type redisEndpoint struct {
+	network  string
+	address  string
+	password string
+	db       int
+	useTLS   bool
+}
+
+// parseRedisConn parses a -conn value: host:port, [ipv6]:port, unix:///path/to/socket
+// or a redis:// / rediss:// URL with optional password and database.
+func parseRedisConn(conn string) (*redisEndpoint, error) {
+	conn = strings.TrimSpace(conn)
+	if conn == "" {
+		return nil, fmt.Errorf("Redis连接为空")
+	}
+
+	if !strings.Contains(conn, "://") {
+		address, err := normalizeHostPort(conn)
+		if err != nil {
+			return nil, fmt.Errorf("无法解析Redis连接 %q: %v", conn, err)
+		}
+		return &redisEndpoint{network: "tcp", address: address}, nil
+	}
+
+	u, err := url.Parse(conn)
+	if err != nil {
+		return nil, fmt.Errorf("无法解析Redis连接 %q: %v", conn, err)
+	}
+
+	switch u.Scheme {
+	case "unix":
+		if u.Path == "" {
+			return nil, fmt.Errorf("无法解析Redis连接 %q: 缺少 socket 路径", conn)
+		}
+		return &redisEndpoint{network: "unix", address: u.Path}, nil
+	case "redis", "rediss":
+		address, err := normalizeHostPort(u.Host)
+		if err != nil {
+			return nil, fmt.Errorf("无法解析Redis连接 %q: %v", conn, err)
+		}
+
+		endpoint := &redisEndpoint{network: "tcp", address: address, useTLS: u.Scheme == "rediss"}
+		if u.User != nil {
+			if password, ok := u.User.Password(); ok {
+				endpoint.password = password
+			} else {
+				endpoint.password = u.User.Username()
+			}
+		}
+		if db := strings.Trim(u.Path, "/"); db != "" {
+			endpoint.db, err = strconv.Atoi(db)
+			if err != nil || endpoint.db < 0 {
+				return nil, fmt.Errorf("无法解析Redis连接 %q: 无效的数据库编号 %q", conn, db)
+			}
+		}
+		return endpoint, nil
+	}
+
+	return nil, fmt.Errorf("无法解析Redis连接 %q: 不支持的协议 %s", conn, u.Scheme)
+}
+
+// normalizeHostPort validates a host:port pair, adding the default port when it is missing.
+func normalizeHostPort(hostPort string) (string, error) {
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		// 未指定端口，如 127.0.0.1、::1、[::1]
+		host = strings.TrimSuffix(strings.TrimPrefix(hostPort, "["), "]")
+		port = defaultRedisPort
+	}
+	if host == "" || strings.ContainsAny(host, "/ ") {
+		return "", fmt.Errorf("无效的主机 %q", hostPort)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return "", fmt.Errorf("无效的端口 %q", port)
+	}
+
+	return net.JoinHostPort(host, port), nil
+}
+
+// waitForRedis pings Redis up to attempts times, doubling the delay from interval between tries.
+func waitForRedis(attempts int, interval time.Duration) error {
+	var err error
+	delay := interval
+	for i := 1; i <= attempts; i++ {
+		redisClient := redisPool.Get()
+		_, err = redisClient.Do("ping")
+		redisClient.Close()
+		if err == nil {
+			return nil
+		}
+
+		log.Printf("Redis 未就绪 (%d/%d): %v", i, attempts, err)
+		if i < attempts {
+			time.Sleep(delay)
+			delay *= 2
+			if delay > maxRedisWaitBackoff {
+				delay = maxRedisWaitBackoff
+			}
+		}
+	}
+
+	return err
+}
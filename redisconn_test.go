@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRedisConn(t *testing.T) {
+	for _, tc := range []struct {
+		conn string
+		want redisEndpoint
+	}{
+		{"127.0.0.1:6380", redisEndpoint{network: "tcp", address: "127.0.0.1:6380"}},
+		{"redis.local", redisEndpoint{network: "tcp", address: "redis.local:6379"}},
+		{"[::1]:6380", redisEndpoint{network: "tcp", address: "[::1]:6380"}},
+		{"::1", redisEndpoint{network: "tcp", address: "[::1]:6379"}},
+		{"unix:///var/run/redis.sock", redisEndpoint{network: "unix", address: "/var/run/redis.sock"}},
+		{"redis://:pw@cache:6381/2", redisEndpoint{network: "tcp", address: "cache:6381", password: "pw", db: 2}},
+		{"redis://token@cache", redisEndpoint{network: "tcp", address: "cache:6379", password: "token"}},
+		{"rediss://cache:6390", redisEndpoint{network: "tcp", address: "cache:6390", useTLS: true}},
+	} {
+		got, err := parseRedisConn(tc.conn)
+		if err != nil || *got != tc.want {
+			t.Errorf("parseRedisConn(%q) = %+v, %v, want %+v", tc.conn, got, err, tc.want)
+		}
+	}
+	for _, conn := range []string{"", "unix://", "redis://cache/x", "redis://cache:99999", "http://cache", "bad host:1"} {
+		if got, err := parseRedisConn(conn); err == nil {
+			t.Errorf("parseRedisConn(%q) = %+v, want an error", conn, got)
+		}
+	}
+}
+
+func TestInitRedisPool(t *testing.T) {
+	_, mr := newTestRouter(t)
+	mr.RequireAuth("pw")
+	mr.Select(3)
+	redisPoolConfig = &redisPoolConf{maxIdle: 2, maxActive: 5, maxIdleTimeout: 7, network: "tcp", host: mr.Addr(), password: "pw", db: 3, handleTimeout: 1}
+	initRedisPool()
+
+	if redisPool.MaxIdle != 2 || redisPool.MaxActive != 5 || redisPool.IdleTimeout != 7*time.Second || !redisPool.Wait {
+		t.Fatalf("pool settings %+v", redisPool)
+	}
+	conn := redisPool.Get()
+	defer conn.Close()
+	if _, err := conn.Do("set", "k", "v"); err != nil {
+		t.Fatal(err)
+	}
+	mr.Select(3)
+	if got, _ := mr.Get("k"); got != "v" {
+		t.Fatal("connection not using the configured database")
+	}
+}
+
+func TestWaitForRedis(t *testing.T) {
+	_, mr := newTestRouter(t)
+	mr.Close()
+
+	if err := waitForRedis(2, time.Millisecond); err == nil {
+		t.Fatal("unreachable Redis reported ready")
+	}
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		_ = mr.Restart()
+	}()
+	if err := waitForRedis(6, 20*time.Millisecond); err != nil {
+		t.Fatalf("Redis started late: %v", err)
+	}
+}
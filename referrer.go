@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// defaultRefPrefix is the default prefix for Redis per-link referrer hashes.
+const defaultRefPrefix = "myurls:ref:"
+
+// maxReferrers is the maximum number of distinct referring domains tracked per link.
+const maxReferrers = 100
+
+// directReferrer is the bucket of visits without a Referer header.
+const directReferrer = "direct"
+
+// otherReferrer is the bucket of invalid referrers and of domains beyond maxReferrers.
+const otherReferrer = "other"
+
+// referrerHost normalizes a Referer header to its lowercase host.
+func referrerHost(referer string) string {
+	referer = strings.TrimSpace(referer)
+	if referer == "" {
+		return directReferrer
+	}
+	u, err := url.Parse(referer)
+	if err != nil || u.Hostname() == "" {
+		return otherReferrer
+	}
+
+	return strings.ToLower(u.Hostname())
+}
+
+// linkReferrers returns the visit counts per referring domain of a short key.
+func linkReferrers(conn redis.Conn, shortKey string) map[string]int64 {
+	referrers, _ := redis.Int64Map(conn.Do("hgetall", redisKey(defaultRefPrefix+shortKey)))
+
+	return referrers
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gomodule/redigo/redis"
+)
+
+// 为短链接重新生成随机 key，保留目标地址、有效期与元数据，旧 key 随即失效
+func rekeyLinkHandler(context *gin.Context) {
+	if err := writesSuspended(); err != nil {
+		respondError(context, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	oldKey := normalizeShortKey(context.Param("shortKey"))
+
+	redisClient := redisPool.Get()
+	defer redisClient.Close()
+
+	longUrl, err := redis.String(redisClient.Do("get", redisKey(oldKey)))
+	if err == redis.ErrNil {
+		respondError(context, http.StatusNotFound, "短链接不存在")
+		return
+	} else if err != nil {
+		respondError(context, http.StatusInternalServerError, storageError(err).Error())
+		return
+	}
+
+	shortKey, err := rekeyLink(redisClient, oldKey, longUrl)
+	if err != nil {
+		respondError(context, errorStatus(err), err.Error())
+		return
+	}
+
+	context.JSON(http.StatusOK, Response{Code: 1, LongUrl: longUrl, ShortUrl: buildShortUrl(context, shortKey)})
+}
+
+// rekeyLink moves a short link to a fresh random key with the same remaining ttl, carrying over
+// its metadata, counters, tags and md5 reverse mapping, then deletes the old key. The old key's
+// tombstone is removed too, so it answers 404 as if it had never been created.
+func rekeyLink(conn redis.Conn, oldKey string, longUrl string) (string, error) {
+	pttl, err := redis.Int64(conn.Do("pttl", redisKey(oldKey)))
+	if err != nil {
+		return "", storageError(err)
+	}
+	meta, err := redis.StringMap(conn.Do("hgetall", redisKey(defaultMetaPrefix+oldKey)))
+	if err != nil {
+		return "", storageError(err)
+	}
+
+	// 泄露的 key 可能已被枚举，新 key 始终使用安全随机数生成
+	var shortKey string
+	for i := 0; i < generateRetries && shortKey == ""; i++ {
+		keyLen := currentShortUrlLen() + i/2
+		if meta["unlisted"] == "1" || keyLen > maxShortUrlLen {
+			keyLen = maxShortUrlLen
+		}
+		candidate, err := unblockedKey(func() (string, error) { return generateSecure(keyLen) })
+		if err != nil {
+			return "", err
+		}
+
+		args := []interface{}{redisKey(candidate), longUrl, "nx"}
+		if pttl > 0 {
+			args = append(args, "px", pttl)
+		}
+		reply, err := redis.String(conn.Do("set", args...))
+		if err != nil && err != redis.ErrNil {
+			return "", storageError(err)
+		}
+		if reply == "OK" {
+			shortKey = candidate
+		}
+	}
+	if shortKey == "" {
+		return "", errGenerateFailed
+	}
+
+	// 元数据、计数与来源统计随链接迁移，签名绑定 key，需重新计算
+	for _, prefix := range []string{defaultMetaPrefix, defaultHitsPrefix, defaultRefPrefix} {
+		exists, err := redis.Bool(conn.Do("exists", redisKey(prefix+oldKey)))
+		if err != nil {
+			return "", storageError(err)
+		}
+		if exists {
+			if _, err := conn.Do("rename", redisKey(prefix+oldKey), redisKey(prefix+shortKey)); err != nil {
+				return "", storageError(err)
+			}
+		}
+	}
+	if _, err := conn.Do("hdel", redisKey(defaultMetaPrefix+shortKey), "hmac", "destinationsHmac"); err != nil {
+		return "", storageError(err)
+	}
+	if err := signLink(conn, shortKey, longUrl); err != nil {
+		return "", storageError(err)
+	}
+	if err := signDestinations(conn, shortKey, meta["platforms"], meta["languages"]); err != nil {
+		return "", storageError(err)
+	}
+	if err := syncLinkExpiry(conn, shortKey); err != nil {
+		return "", storageError(err)
+	}
+
+	tags := linkTags(meta)
+	if err := untagLink(conn, oldKey, tags); err != nil {
+		return "", storageError(err)
+	}
+	if err := tagLink(shortKey, tags); err != nil {
+		return "", storageError(err)
+	}
+	if score, err := redis.Float64(conn.Do("zscore", redisKey(defaultLeaderboardKey), oldKey)); err == nil {
+		if _, err := conn.Do("zadd", redisKey(defaultLeaderboardKey), score, shortKey); err != nil {
+			return "", storageError(err)
+		}
+	}
+
+	md5Key := redisKey(defaultMd5Prefix + longUrlHash(longUrl))
+	if mapped, _ := redis.String(conn.Do("get", md5Key)); mapped == oldKey {
+		if _, err := conn.Do("set", md5Key, shortKey, "ex", secondsPerDay); err != nil {
+			return "", storageError(err)
+		}
+	}
+
+	if err := deleteLink(conn, oldKey, longUrl); err != nil {
+		return "", storageError(err)
+	}
+	if _, err := conn.Do("del", redisKey(defaultTombPrefix+oldKey)); err != nil {
+		return "", storageError(err)
+	}
+
+	return shortKey, nil
+}
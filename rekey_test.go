@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRekeyLink(t *testing.T) {
+	router, mr := newTestRouter(t)
+	setFlag(t, &adminToken, "secret-admin")
+	setFlag(t, &linkSecret, "integrity-secret")
+
+	oldKey := mustShorten(t, router, "https://example.com/leaked", url.Values{"tags": {"promo"}})
+	serve(router, http.MethodGet, "/"+oldKey, nil, nil)
+	ttl := mr.TTL(oldKey)
+	setFlag(t, &noRenew, true)
+
+	w := serve(router, http.MethodPost, "/admin/rekey/"+oldKey, nil, bearer(adminToken))
+	var res Response
+	decode(t, w, &res)
+	if w.Code != http.StatusOK || res.LongUrl != "https://example.com/leaked" {
+		t.Fatalf("rekey: status %d, %+v", w.Code, res)
+	}
+	newKey := strings.TrimPrefix(res.ShortUrl, "https://s.test/")
+	if newKey == oldKey {
+		t.Fatal("rekey kept the key")
+	}
+
+	if w := serve(router, http.MethodGet, "/"+oldKey, nil, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("old key: status %d, want 404", w.Code)
+	}
+	if w := serve(router, http.MethodGet, "/"+newKey, nil, nil); w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "https://example.com/leaked" {
+		t.Fatalf("new key: status %d, %s", w.Code, w.Body)
+	}
+	if got := mr.TTL(newKey); got <= 0 || got > ttl {
+		t.Fatalf("new key ttl %v, old %v", got, ttl)
+	}
+	if got, _ := mr.Get(defaultHitsPrefix + newKey); got != "2" {
+		t.Fatalf("hits carried over as %q", got)
+	}
+	if members, _ := mr.SMembers(defaultTagPrefix + "promo"); len(members) != 1 || members[0] != newKey {
+		t.Fatalf("tag members %v", members)
+	}
+
+	// 去重映射随链接迁移
+	plain := mustShorten(t, router, "https://example.com/plain", nil)
+	w = serve(router, http.MethodPost, "/admin/rekey/"+plain, nil, bearer(adminToken))
+	decode(t, w, &res)
+	if got, _ := mr.Get(defaultMd5Prefix + longUrlHash("https://example.com/plain")); got != strings.TrimPrefix(res.ShortUrl, "https://s.test/") {
+		t.Fatalf("md5 mapping points at %q after rekey to %s", got, res.ShortUrl)
+	}
+
+	if w := serve(router, http.MethodPost, "/admin/rekey/missing", nil, bearer(adminToken)); w.Code != http.StatusNotFound {
+		t.Fatalf("unknown key: status %d, want 404", w.Code)
+	}
+}
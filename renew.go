@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gomodule/redigo/redis"
+)
+
+// defaultMaxRenewDays is the default upper bound in days of an explicit renewal.
+const defaultMaxRenewDays = 365
+
+// maxRenewDays clamps the days requested from the renew endpoint.
+var maxRenewDays int
+
+// RenewResponse is the response structure of the renew endpoint.
+type RenewResponse struct {
+	Code     int
+	Message  string
+	ShortKey string
+	TTL      int
+	ExpireAt int64
+}
+
+// 手动续期，将有效期设置为指定天数，不受自动续命锁限制
+func renewHandler(context *gin.Context) {
+	shortKey := normalizeShortKey(context.Param("shortKey"))
+	res := &RenewResponse{Code: 1, ShortKey: shortKey}
+
+	if err := writesSuspended(); err != nil {
+		res.Code = 0
+		res.Message = err.Error()
+		context.JSON(http.StatusServiceUnavailable, *res)
+		return
+	}
+
+	days, err := strconv.Atoi(context.PostForm("days"))
+	if err != nil || days < 1 {
+		res.Code = 0
+		res.Message = "days必须为正整数"
+		context.JSON(http.StatusBadRequest, *res)
+		return
+	}
+	if days > maxRenewDays {
+		days = maxRenewDays
+	}
+
+	redisClient := redisPool.Get()
+	defer redisClient.Close()
+
+	ttl, err := redis.Int(redisClient.Do("ttl", redisKey(shortKey)))
+	if err != nil {
+		res.Code = 0
+		res.Message = storageError(err).Error()
+		context.JSON(http.StatusInternalServerError, *res)
+		return
+	}
+	if ttl == -2 {
+		res.Code = 0
+		res.Message = "短链接不存在"
+		context.JSON(http.StatusNotFound, *res)
+		return
+	}
+
+	// 永久有效的短链接保持不变
+	if ttl != -1 {
+		ttl = days * secondsPerDay
+		if _, err := redisClient.Do("expire", redisKey(shortKey), ttl); err != nil {
+			res.Code = 0
+			res.Message = storageError(err).Error()
+			context.JSON(http.StatusInternalServerError, *res)
+			return
+		}
+		if err := syncLinkExpiry(redisClient, shortKey); err != nil {
+			res.Code = 0
+			res.Message = storageError(err).Error()
+			context.JSON(http.StatusInternalServerError, *res)
+			return
+		}
+		res.ExpireAt = time.Now().Add(time.Duration(ttl) * time.Second).Unix()
+	}
+	res.TTL = ttl
+
+	context.JSON(http.StatusOK, *res)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestRenewOnAccess(t *testing.T) {
+	router, mr := newTestRouter(t)
+	setFlag(t, &renewalDays, 2)
+
+	key := mustShorten(t, router, "https://example.com/renew", url.Values{"ttl": {"1d"}})
+	serve(router, http.MethodGet, "/"+key, nil, nil)
+	if got := mr.TTL(key); got != 3*24*time.Hour {
+		t.Fatalf("ttl after first access %v, want 72h", got)
+	}
+
+	// 续命锁期间不再续命
+	serve(router, http.MethodGet, "/"+key, nil, nil)
+	if got := mr.TTL(key); got != 3*24*time.Hour {
+		t.Fatalf("ttl after second access %v, want 72h", got)
+	}
+	if got := mr.TTL(defaultLockPrefix + key); got != time.Duration(renewLockHours)*time.Hour {
+		t.Fatalf("renew lock ttl %v", got)
+	}
+}
+
+func TestNoRenew(t *testing.T) {
+	router, mr := newTestRouter(t)
+	setFlag(t, &noRenew, true)
+
+	key := mustShorten(t, router, "https://example.com/norenew", url.Values{"ttl": {"1d"}})
+	serve(router, http.MethodGet, "/"+key, nil, nil)
+	if got := mr.TTL(key); got != 24*time.Hour {
+		t.Fatalf("ttl changed to %v with -no-renew", got)
+	}
+}
+
+func TestRenewPersistentAndMissing(t *testing.T) {
+	router, mr := newTestRouter(t)
+
+	mustShorten(t, router, "https://example.com/forever", url.Values{"shortKey": {"forever"}})
+	serve(router, http.MethodGet, "/forever", nil, nil)
+	if mr.TTL("forever") != 0 {
+		t.Fatalf("persistent link got ttl %v", mr.TTL("forever"))
+	}
+	if w := serve(router, http.MethodGet, "/missing", nil, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("missing key: status %d, want 404", w.Code)
+	}
+	if mr.Exists("missing") || mr.Exists(defaultLockPrefix+"missing") {
+		t.Fatal("renew created keys for a missing link")
+	}
+}
+
+func TestRenewEndpoint(t *testing.T) {
+	router, mr := newTestRouter(t)
+	setFlag(t, &maxRenewDays, 30)
+
+	key := mustShorten(t, router, "https://example.com/explicit", url.Values{"ttl": {"1d"}})
+	renewDays := func(key string, days string) (int, RenewResponse) {
+		w := serve(router, http.MethodPost, "/renew/"+key, url.Values{"days": {days}}, nil)
+		var res RenewResponse
+		decode(t, w, &res)
+		return w.Code, res
+	}
+
+	if code, res := renewDays(key, "10"); code != http.StatusOK || res.TTL != 10*secondsPerDay {
+		t.Fatalf("renew 10 days: status %d, %+v", code, res)
+	}
+	if got := mr.TTL(key); got != 10*24*time.Hour {
+		t.Fatalf("ttl %v, want 240h", got)
+	}
+	if code, res := renewDays(key, "100"); code != http.StatusOK || res.TTL != 30*secondsPerDay {
+		t.Fatalf("renew beyond -max-renew-days: status %d, %+v", code, res)
+	}
+	if code, _ := renewDays(key, "0"); code != http.StatusBadRequest {
+		t.Fatalf("renew 0 days: status %d, want 400", code)
+	}
+	if code, _ := renewDays("missing", "1"); code != http.StatusNotFound {
+		t.Fatalf("renew missing key: status %d, want 404", code)
+	}
+
+	mustShorten(t, router, "https://example.com/forever", url.Values{"shortKey": {"forever"}})
+	if code, res := renewDays("forever", "5"); code != http.StatusOK || res.TTL != -1 || mr.TTL("forever") != 0 {
+		t.Fatalf("renew persistent link: status %d, %+v", code, res)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestIDHeader is the header carrying the request ID.
+const requestIDHeader = "X-Request-ID"
+
+// requestIDContextKey is the gin context key of the request ID.
+const requestIDContextKey = "requestID"
+
+// maxRequestIDLen is the maximum length of a client supplied request ID.
+const maxRequestIDLen = 128
+
+// 请求 ID，沿用客户端传入的值，否则生成 UUID
+func RequestID() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		id := context.GetHeader(requestIDHeader)
+		if !validRequestID(id) {
+			id = newUUID()
+		}
+
+		context.Set(requestIDContextKey, id)
+		context.Header(requestIDHeader, id)
+		context.Next()
+	}
+}
+
+// requestID returns the ID of the current request.
+func requestID(context *gin.Context) string {
+	return context.GetString(requestIDContextKey)
+}
+
+// validRequestID reports whether a client supplied request ID is safe to log and echo,
+// i.e. non-empty, not too long and printable ASCII only.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// newUUID returns a random version 4 UUID.
+func newUUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// newLoggedRouter builds a router on the current test Redis whose access log is written to the returned buffer.
+func newLoggedRouter(t testing.TB, trustedProxies []string) (http.Handler, *bytes.Buffer) {
+	t.Helper()
+	var buf bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&buf)
+	logger.Formatter = &logrus.JSONFormatter{}
+	router, err := newRouter(logger, trustedProxies, defaultTemplates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return router, &buf
+}
+
+// accessLog decodes the single access log entry written to buf.
+func accessLog(t testing.TB, buf *bytes.Buffer) map[string]interface{} {
+	t.Helper()
+	var entry map[string]interface{}
+	if err := json.Unmarshal(bytes.TrimSpace(buf.Bytes()), &entry); err != nil {
+		t.Fatalf("access log %q: %v", buf, err)
+	}
+	return entry
+}
+
+func TestRequestID(t *testing.T) {
+	newTestRouter(t)
+	router, buf := newLoggedRouter(t, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api", nil)
+	req.Header.Set(requestIDHeader, "trace-123")
+	if got := serveRequest(router, req).Header().Get(requestIDHeader); got != "trace-123" {
+		t.Fatalf("echoed request ID %q", got)
+	}
+	if entry := accessLog(t, buf); entry["requestID"] != "trace-123" {
+		t.Fatalf("logged request ID %v", entry["requestID"])
+	}
+
+	// 非法的请求 ID 被替换为 UUID
+	for _, id := range []string{"", "has space", "line\nbreak", strings.Repeat("a", maxRequestIDLen+1)} {
+		req.Header.Set(requestIDHeader, id)
+		got := serveRequest(router, req).Header().Get(requestIDHeader)
+		if got == id || len(got) != 36 {
+			t.Errorf("request ID %q replaced by %q", id, got)
+		}
+	}
+}
+
+func TestRequestIDInResponse(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/short", strings.NewReader(""))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set(requestIDHeader, "trace-456")
+	w := serveRequest(router, req)
+	var res Response
+	decode(t, w, &res)
+	if res.RequestID != "trace-456" {
+		t.Fatalf("error response RequestID %q", res.RequestID)
+	}
+}
+
+func TestTrustedProxies(t *testing.T) {
+	newTestRouter(t)
+	for _, tc := range []struct {
+		name    string
+		trusted []string
+		want    string
+	}{
+		{"trusted", []string{"10.0.0.0/8"}, "203.0.113.5"},
+		{"untrusted", []string{"192.168.0.0/16"}, "10.0.0.1"},
+		{"none", nil, "10.0.0.1"},
+	} {
+		router, buf := newLoggedRouter(t, tc.trusted)
+		req := httptest.NewRequest(http.MethodGet, "/api", nil)
+		req.RemoteAddr = "10.0.0.1:5000"
+		req.Header.Set("X-Forwarded-For", "203.0.113.5")
+		serveRequest(router, req)
+		if got := accessLog(t, buf)["clientIP"]; got != tc.want {
+			t.Errorf("%s: logged client IP %v, want %s", tc.name, got, tc.want)
+		}
+	}
+
+	if _, err := newRouter(discardLogger(), []string{"not-a-cidr"}, defaultTemplates); err == nil {
+		t.Fatal("invalid trusted proxy accepted")
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gomodule/redigo/redis"
+)
+
+// placeholderUrl is stored as the destination of a reserved short key until a PUT sets the real one.
+const placeholderUrl = "myurls:reserved"
+
+// comingSoonUrl is where reserved short keys redirect to, the built-in page when empty.
+var comingSoonUrl string
+
+// isPlaceholder reports whether longUrl marks a reserved short key without destination.
+func isPlaceholder(longUrl string) bool {
+	return longUrl == placeholderUrl
+}
+
+// 预留短链接，暂不设置目标地址，稍后通过 PUT 设置
+func reserveShortKey(context *gin.Context, shortKey string) {
+	shortKey = normalizeShortKey(shortKey)
+	if err := validateShortKey(shortKey); err != nil {
+		respondError(context, http.StatusBadRequest, err.Error())
+		return
+	}
+	shortKey = withChecksum(shortKey)
+
+	redisClient := redisPool.Get()
+	defer redisClient.Close()
+
+	// 预留同样受有效期约束，已存在的短链接不可预留
+	ok, err := redis.String(redisClient.Do("set", redisKey(shortKey), placeholderUrl, "nx", "ex", linkTTL))
+	if err != nil && err != redis.ErrNil {
+		err = storageError(err)
+		status := http.StatusInternalServerError
+		if err == errStorageReadOnly {
+			status = http.StatusServiceUnavailable
+		}
+		respondError(context, status, err.Error())
+		return
+	}
+	if ok != "OK" {
+		respondError(context, http.StatusConflict, "短链接已存在，请更换key")
+		return
+	}
+	if err := syncLinkExpiry(redisClient, shortKey); err != nil {
+		respondError(context, http.StatusInternalServerError, storageError(err).Error())
+		return
+	}
+	_ = markLinkCreated(shortKey)
+	recordCreatorIP(context.ClientIP(), shortKey)
+	if recordIP {
+		_ = markLinkCreatedBy(shortKey, context.ClientIP())
+	}
+
+	shortUrl, shortUrlInsecure := shortUrlVariants(context, shortKey)
+	if wantsText(context) {
+		context.String(http.StatusOK, shortUrl)
+		return
+	}
+
+	context.JSON(http.StatusOK, Response{Code: 1, Message: "短链接已预留，尚未设置目标地址", ShortUrl: shortUrl, ShortUrlInsecure: shortUrlInsecure})
+}
+
+// 预留的短链接跳转至“即将上线”页面
+func respondComingSoon(context *gin.Context) {
+	if comingSoonUrl != "" {
+		context.Redirect(http.StatusFound, comingSoonUrl)
+		return
+	}
+
+	context.HTML(http.StatusOK, "comingsoon.html", gin.H{
+		"title":  "MyUrls",
+		"banner": currentBanner(),
+	})
+}
+
+// AvailableResponse is the response structure of the key availability endpoint.
+type AvailableResponse struct {
+	Code      int
+	Message   string
+	ShortKey  string
+	Available bool
+}
+
+// 检查自定义短链接是否可用，不创建任何数据
+func availableHandler(context *gin.Context) {
+	shortKey := normalizeShortKey(context.Param("shortKey"))
+	res := &AvailableResponse{Code: 1, ShortKey: shortKey}
+
+	if err := validateShortKey(shortKey); err != nil {
+		res.Message = err.Error()
+		context.JSON(http.StatusOK, *res)
+		return
+	}
+	// 与创建时一致，开启校验位时追加校验位
+	res.ShortKey = withChecksum(shortKey)
+
+	redisClient := redisPool.Get()
+	defer redisClient.Close()
+
+	exists, err := redis.Bool(redisClient.Do("exists", redisKey(res.ShortKey)))
+	if err != nil {
+		res.Code = 0
+		res.Message = storageError(err).Error()
+		context.JSON(http.StatusInternalServerError, *res)
+		return
+	}
+	if exists {
+		res.Message = "短链接已存在，请更换key"
+	}
+	res.Available = !exists
+
+	context.JSON(http.StatusOK, *res)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestReserveShortKey(t *testing.T) {
+	router, mr := newTestRouter(t)
+	setFlag(t, &adminToken, "secret-admin")
+
+	code, res := shorten(t, router, "", url.Values{"shortKey": {"launch"}})
+	if code != http.StatusOK || res.ShortUrl != "https://s.test/launch" {
+		t.Fatalf("reserve: status %d, %+v", code, res)
+	}
+	if mr.TTL("launch") <= 0 {
+		t.Fatal("reserved key without ttl")
+	}
+	if code, _ := shorten(t, router, "", url.Values{"shortKey": {"launch"}}); code != http.StatusConflict {
+		t.Fatalf("reserve twice: status %d, want 409", code)
+	}
+
+	w := serve(router, http.MethodGet, "/launch", nil, nil)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "即将上线") {
+		t.Fatalf("reserved key: status %d", w.Code)
+	}
+	setFlag(t, &comingSoonUrl, "https://example.com/soon")
+	if w := serve(router, http.MethodGet, "/launch", nil, nil); w.Code != http.StatusFound || w.Header().Get("Location") != comingSoonUrl {
+		t.Fatalf("reserved key with -coming-soon-url: status %d, Location %q", w.Code, w.Header().Get("Location"))
+	}
+
+	serve(router, http.MethodPut, "/launch", updateForm("https://example.com/launched"), bearer(adminToken))
+	if w := serve(router, http.MethodGet, "/launch", nil, nil); w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "https://example.com/launched" {
+		t.Fatalf("filled in key: status %d, Location %q", w.Code, w.Header().Get("Location"))
+	}
+}
+
+func TestAvailable(t *testing.T) {
+	router, _ := newTestRouter(t)
+	mustShorten(t, router, "https://example.com/taken", url.Values{"shortKey": {"taken"}})
+
+	for _, tc := range []struct {
+		key       string
+		available bool
+	}{
+		{"taken", false},
+		{"free", true},
+		{"short", false},
+		{"bad!key", false},
+	} {
+		w := serve(router, http.MethodGet, "/available/"+url.PathEscape(tc.key), nil, nil)
+		var res AvailableResponse
+		decode(t, w, &res)
+		if w.Code != http.StatusOK || res.Available != tc.available {
+			t.Errorf("available %q: status %d, %+v", tc.key, w.Code, res)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// newRouter builds the HTTP router with its middlewares and routes, trusting the X-Forwarded-For
+// of trustedProxies only and loading the page templates matching the templates glob.
+func newRouter(logger *logrus.Logger, trustedProxies []string, templates string) (*gin.Engine, error) {
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.Default()
+
+	tmpl, err := template.New("").Funcs(router.FuncMap).ParseGlob(templates)
+	if err != nil {
+		return nil, fmt.Errorf("templates 加载失败: %w", err)
+	}
+	router.SetHTMLTemplate(tmpl)
+
+	// 仅信任指定代理传入的 X-Forwarded-For，避免客户端伪造 IP
+	if err := router.SetTrustedProxies(trustedProxies); err != nil {
+		return nil, fmt.Errorf("trusted-proxies 无效: %w", err)
+	}
+
+	// Log 收集中间件
+	router.Use(RequestID())
+	router.Use(LoggerToFile(logger))
+	router.Use(Metrics())
+
+	router.GET("/metrics", metricsHandler())
+
+	// 业务路由挂载在 basePath 下，运维类接口 /metrics 始终挂载在根路径
+	app := router.Group(basePath)
+
+	app.GET("/", indexHandler)
+
+	// 管理接口
+	admin := app.Group("/admin", AdminAuth(), Gzip())
+	admin.POST("/banner", setBannerHandler)
+	admin.DELETE("/banner", clearBannerHandler)
+	admin.GET("/top", topHandler)
+	admin.POST("/disable/:shortKey", disableLinkHandler)
+	admin.POST("/enable/:shortKey", enableLinkHandler)
+	admin.GET("/links", tagLinksHandler)
+	admin.GET("/export", exportHandler)
+	admin.POST("/import", importHandler)
+	admin.POST("/import/csv", importCsvHandler)
+	admin.POST("/readonly", enableMaintenanceHandler)
+	admin.DELETE("/readonly", disableMaintenanceHandler)
+	admin.DELETE("/links", deleteLinksHandler)
+	admin.GET("/link/:shortKey", adminLinkInfoHandler)
+	admin.POST("/rekey/:shortKey", rekeyLinkHandler)
+
+	app.POST("/short", CreateAuth(), Idempotency(), createHandler)
+	app.OPTIONS("/short", optionsHandler)
+
+	// 查询长链接是否已有短链接
+	app.GET("/lookup", lookupHandler)
+
+	// 服务信息
+	app.GET("/api", infoHandler)
+
+	// 自定义短链接是否可用
+	app.GET("/available/:shortKey", availableHandler)
+
+	// 短链接元数据
+	app.GET("/api/link/:shortKey", linkInfoHandler)
+
+	// 即将过期的短链接
+	app.GET("/api/expiring", AdminAuth(), Gzip(), expiringHandler)
+
+	// 短链接统计
+	app.GET("/stats/:shortKey", statsHandler)
+	// 访问记录包含访客 IP、User-Agent 与来源，仅管理员可查看
+	app.GET("/stats/:shortKey/history", AdminAuth(), historyHandler)
+
+	// 手动续期
+	app.POST("/renew/:shortKey", CreateAuth(), renewHandler)
+
+	// 修改短链接目标地址
+	app.PUT("/:shortKey", ManageAuth(), updateHandler)
+
+	// 短链接检查
+	app.HEAD("/:shortKey", headHandler)
+	app.OPTIONS("/:shortKey", preflightHandler)
+
+	app.GET("/:shortKey", redirectHandler)
+
+	// 已知路径使用了错误的请求方法时返回 405
+	router.HandleMethodNotAllowed = true
+	router.NoMethod(methodNotAllowed)
+	setRoutes(router.Routes())
+
+	return router, nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTemplatesDir(t *testing.T) {
+	newTestRouter(t)
+	dir := t.TempDir()
+	for _, name := range []string{"index.html", "preview.html", "notfound.html", "comingsoon.html"} {
+		page := `<p>custom {{ .title }}</p>`
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(page), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	router, err := newRouter(discardLogger(), nil, filepath.Join(dir, "*.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := serve(router, http.MethodGet, "/", nil, nil); w.Code != http.StatusOK || w.Body.String() != "<p>custom MyUrls</p>" {
+		t.Fatalf("index from custom templates: status %d, %s", w.Code, w.Body)
+	}
+
+	if _, err := newRouter(discardLogger(), nil, filepath.Join(t.TempDir(), "*.html")); err == nil {
+		t.Fatal("missing templates accepted")
+	}
+}
+
+func TestRootRedirect(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	w := serve(router, http.MethodGet, "/", nil, nil)
+	if w.Code != http.StatusOK || !strings.Contains(w.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("index page: status %d, %s", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	setFlag(t, &rootRedirect, "https://example.com/home")
+	if w := serve(router, http.MethodGet, "/", nil, nil); w.Code != http.StatusFound || w.Header().Get("Location") != rootRedirect {
+		t.Fatalf("root redirect: status %d, Location %q", w.Code, w.Header().Get("Location"))
+	}
+}
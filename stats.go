@@ -0,0 +1,238 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gomodule/redigo/redis"
+)
+
+// defaultUniqPrefix is the default prefix for Redis daily unique visitor HyperLogLogs.
+const defaultUniqPrefix = "myurls:uniq:"
+
+// defaultStatsDays is the default number of days reported by the stats endpoint.
+const defaultStatsDays = 7
+
+// uniqDateLayout is the date layout used in the unique visitor keys.
+const uniqDateLayout = "20060102"
+
+// uniqRetentionDays is how many days the daily unique visitor counters are kept.
+var uniqRetentionDays int
+
+// Stats is the statistics response of a short key.
+type Stats struct {
+	Code         int
+	Message      string
+	ShortKey     string
+	LongUrl      string
+	Reserved     bool
+	TTL          int
+	CreatedAt    int64
+	Hits         int64
+	Utm          map[string]string
+	Tags         []string
+	Title        string
+	DailyUniques map[string]int64
+	Referrers    map[string]int64
+}
+
+// uniqKey returns the HyperLogLog key of a short key for the given day (UTC).
+func uniqKey(shortKey string, day time.Time) string {
+	return redisKey(defaultUniqPrefix + shortKey + ":" + day.UTC().Format(uniqDateLayout))
+}
+
+// 按天统计独立访客数
+func dailyUniques(conn redis.Conn, shortKey string, days int) map[string]int64 {
+	result := make(map[string]int64, days)
+	today := time.Now().UTC()
+	for i := 0; i < days; i++ {
+		day := today.AddDate(0, 0, -i)
+		count, _ := redis.Int64(conn.Do("pfcount", uniqKey(shortKey, day)))
+		result[day.Format(uniqDateLayout)] = count
+	}
+
+	return result
+}
+
+// 短链接统计
+func statsHandler(context *gin.Context) {
+	shortKey := normalizeShortKey(context.Param("shortKey"))
+	res := &Stats{Code: 1, ShortKey: shortKey}
+
+	days := defaultStatsDays
+	if daysStr := context.Query("days"); daysStr != "" {
+		_days, err := strconv.Atoi(daysStr)
+		if err != nil || _days < 1 || _days > uniqRetentionDays {
+			res.Code = 0
+			res.Message = "days范围为1-" + strconv.Itoa(uniqRetentionDays)
+			context.JSON(http.StatusBadRequest, *res)
+			return
+		}
+		days = _days
+	}
+
+	// 与跳转一致，校验失败的长链接不予返回
+	longUrl, err := peekLongUrl(shortKey)
+	if err != nil {
+		res.Code = 0
+		res.Message = err.Error()
+		context.JSON(http.StatusInternalServerError, *res)
+		return
+	}
+	if longUrl == "" {
+		res.Code = 0
+		if linkExpired(shortKey) {
+			res.Message = "短链接已过期"
+			context.JSON(http.StatusGone, *res)
+			return
+		}
+		res.Message = "短链接不存在"
+		context.JSON(http.StatusNotFound, *res)
+		return
+	}
+
+	meta, _ := getLinkMeta(shortKey)
+	if meta["requireAuth"] == "1" && !authorizePrivateLink(context.GetHeader("Authorization")) {
+		res.Code = 0
+		res.Message = "该链接需要授权访问"
+		context.JSON(http.StatusUnauthorized, *res)
+		return
+	}
+	// 禁用的链接不再跳转，也不公开其统计
+	if meta["disabled"] == "1" {
+		res.Code = 0
+		res.Message = errLinkDisabled.Error()
+		context.JSON(http.StatusForbidden, *res)
+		return
+	}
+
+	redisClient := redisPool.Get()
+	defer redisClient.Close()
+
+	if isPlaceholder(longUrl) {
+		res.Reserved = true
+	} else {
+		res.LongUrl = longUrl
+	}
+	res.TTL, _ = redis.Int(redisClient.Do("ttl", redisKey(shortKey)))
+	res.CreatedAt = linkCreatedAt(meta)
+	res.Hits = linkHits(redisClient, shortKey)
+	res.Utm = linkUtm(meta)
+	res.Tags = linkTags(meta)
+	res.Title = meta["title"]
+	res.DailyUniques = dailyUniques(redisClient, shortKey, days)
+	res.Referrers = linkReferrers(redisClient, shortKey)
+
+	context.JSON(http.StatusOK, *res)
+}
+
+// LinkInfo is the full stored metadata of a short key.
+type LinkInfo struct {
+	Code        int
+	Message     string
+	ShortKey    string
+	LongUrl     string
+	Reserved    bool
+	TTL         int
+	CreatedAt   int64
+	Hits        int64
+	MaxClicks   int64
+	RequireAuth bool
+	Disabled    bool
+	Unlisted    bool
+	Utm         map[string]string
+	Languages   map[string]string
+	Platforms   map[string]string
+	Tags        []string
+	Title       string
+
+	// CreatedBy is the IP of the creating client, only returned by the admin endpoint.
+	CreatedBy string `json:",omitempty"`
+}
+
+// 短链接元数据，只读，不跳转、不计数、不续命
+func linkInfoHandler(context *gin.Context) {
+	respondLinkInfo(context, false)
+}
+
+// 管理接口的短链接元数据，额外包含创建者 IP
+func adminLinkInfoHandler(context *gin.Context) {
+	respondLinkInfo(context, true)
+}
+
+// respondLinkInfo writes the stored metadata of the short key in the request path,
+// including the audit fields only for admin requests.
+func respondLinkInfo(context *gin.Context, admin bool) {
+	shortKey := normalizeShortKey(context.Param("shortKey"))
+	res := &LinkInfo{Code: 1, ShortKey: shortKey}
+
+	longUrl, err := peekLongUrl(shortKey)
+	if err != nil {
+		res.Code = 0
+		res.Message = err.Error()
+		context.JSON(http.StatusInternalServerError, *res)
+		return
+	}
+	if longUrl == "" {
+		res.Code = 0
+		if linkExpired(shortKey) {
+			res.Message = "短链接已过期"
+			context.JSON(http.StatusGone, *res)
+			return
+		}
+		res.Message = "短链接不存在"
+		context.JSON(http.StatusNotFound, *res)
+		return
+	}
+
+	meta, _ := getLinkMeta(shortKey)
+	if !admin && meta["requireAuth"] == "1" && !authorizePrivateLink(context.GetHeader("Authorization")) {
+		res.Code = 0
+		res.Message = "该链接需要授权访问"
+		context.JSON(http.StatusUnauthorized, *res)
+		return
+	}
+	if !admin {
+		// 禁用的链接对外视为已下线
+		if meta["disabled"] == "1" {
+			res.Code = 0
+			res.Message = errLinkDisabled.Error()
+			context.JSON(http.StatusGone, *res)
+			return
+		}
+		// 与跳转一致，校验失败的备选目标地址不予返回
+		if err := verifyDestinations(shortKey, meta["platforms"], meta["languages"], meta["destinationsHmac"]); err != nil {
+			res.Code = 0
+			res.Message = err.Error()
+			context.JSON(http.StatusInternalServerError, *res)
+			return
+		}
+	} else {
+		res.CreatedBy = meta["createdBy"]
+	}
+
+	redisClient := redisPool.Get()
+	defer redisClient.Close()
+
+	if isPlaceholder(longUrl) {
+		res.Reserved = true
+	} else {
+		res.LongUrl = longUrl
+	}
+	res.TTL, _ = redis.Int(redisClient.Do("ttl", redisKey(shortKey)))
+	res.CreatedAt = linkCreatedAt(meta)
+	res.Hits = linkHits(redisClient, shortKey)
+	res.MaxClicks, _ = strconv.ParseInt(meta["maxClicks"], 10, 64)
+	res.RequireAuth = meta["requireAuth"] == "1"
+	res.Disabled = meta["disabled"] == "1"
+	res.Unlisted = meta["unlisted"] == "1"
+	res.Utm = linkUtm(meta)
+	res.Languages = linkLanguages(meta)
+	res.Platforms = linkPlatforms(meta)
+	res.Tags = linkTags(meta)
+	res.Title = meta["title"]
+
+	context.JSON(http.StatusOK, *res)
+}
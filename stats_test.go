@@ -0,0 +1,132 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// linkStats fetches the stats of a short key.
+func linkStats(t *testing.T, router http.Handler, shortKey string, header http.Header) (int, Stats) {
+	t.Helper()
+	w := serve(router, http.MethodGet, "/stats/"+shortKey, nil, header)
+	var res Stats
+	decode(t, w, &res)
+	return w.Code, res
+}
+
+func TestStatsDailyUniques(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	key := mustShorten(t, router, "https://example.com/uniq", nil)
+	for i := 0; i < 100; i++ {
+		// 每个 IP 访问三次
+		for j := 0; j < 3; j++ {
+			req := httptest.NewRequest(http.MethodGet, "/"+key, nil)
+			req.RemoteAddr = "10.0.0." + strconv.Itoa(i) + ":40000"
+			serveRequest(router, req)
+		}
+	}
+
+	code, res := linkStats(t, router, key, nil)
+	today := time.Now().UTC().Format(uniqDateLayout)
+	if code != http.StatusOK || len(res.DailyUniques) != defaultStatsDays {
+		t.Fatalf("status %d, %+v", code, res)
+	}
+	if got := res.DailyUniques[today]; got < 95 || got > 105 {
+		t.Fatalf("unique visitors today %d, want about 100", got)
+	}
+}
+
+func TestStatsHitsAndReferrers(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	key := mustShorten(t, router, "https://example.com/ref", nil)
+	for _, referer := range []string{"", "https://News.example.org/a", "https://news.example.org/b", "not a url", ""} {
+		header := http.Header{}
+		if referer != "" {
+			header.Set("Referer", referer)
+		}
+		serve(router, http.MethodGet, "/"+key, nil, header)
+	}
+
+	code, res := linkStats(t, router, key, nil)
+	if code != http.StatusOK || res.Hits != 5 || res.LongUrl != "https://example.com/ref" {
+		t.Fatalf("status %d, %+v", code, res)
+	}
+	want := map[string]int64{directReferrer: 2, "news.example.org": 2, otherReferrer: 1}
+	for host, count := range want {
+		if res.Referrers[host] != count {
+			t.Fatalf("referrers %v, want %v", res.Referrers, want)
+		}
+	}
+}
+
+func TestStatsReferrerCap(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	key := mustShorten(t, router, "https://example.com/popular", nil)
+	for i := 0; i < maxReferrers+5; i++ {
+		serve(router, http.MethodGet, "/"+key, nil, http.Header{"Referer": {"https://site" + strconv.Itoa(i) + ".test/"}})
+	}
+	serve(router, http.MethodGet, "/"+key, nil, http.Header{"Referer": {"https://site0.test/"}})
+
+	_, res := linkStats(t, router, key, nil)
+	if len(res.Referrers) != maxReferrers+1 || res.Referrers[otherReferrer] != 5 || res.Referrers["site0.test"] != 2 {
+		t.Fatalf("%d referrers, other %d, site0 %d", len(res.Referrers), res.Referrers[otherReferrer], res.Referrers["site0.test"])
+	}
+}
+
+func TestStatsMissingAndExpired(t *testing.T) {
+	router, mr := newTestRouter(t)
+
+	if code, _ := linkStats(t, router, "never1", nil); code != http.StatusNotFound {
+		t.Fatalf("missing link: status %d, want 404", code)
+	}
+	key := mustShorten(t, router, "https://example.com/gone", url.Values{"ttl": {"1h"}})
+	mr.FastForward(2 * time.Hour)
+	if code, _ := linkStats(t, router, key, nil); code != http.StatusGone {
+		t.Fatalf("expired link: status %d, want 410", code)
+	}
+	if w := serve(router, http.MethodGet, "/stats/"+key+"?days=0", nil, nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("days=0: status %d, want 400", w.Code)
+	}
+}
+
+func TestLinkInfoHasNoSideEffects(t *testing.T) {
+	router, mr := newTestRouter(t)
+
+	key := mustShorten(t, router, "https://example.com/info", url.Values{"ttl": {"2d"}, "maxClicks": {"3"}, "tags": {"docs"}})
+	mr.FastForward(time.Minute)
+	ttl := mr.TTL(key)
+
+	w := serve(router, http.MethodGet, "/api/link/"+key, nil, nil)
+	var res LinkInfo
+	decode(t, w, &res)
+	if w.Code != http.StatusOK || res.LongUrl != "https://example.com/info" || res.MaxClicks != 3 || len(res.Tags) != 1 || res.Hits != 0 {
+		t.Fatalf("status %d, %+v", w.Code, res)
+	}
+	if res.CreatedBy != "" {
+		t.Fatal("creator IP exposed by the public endpoint")
+	}
+	if mr.TTL(key) != ttl || mr.Exists(defaultHitsPrefix+key) || mr.Exists(defaultLockPrefix+key) {
+		t.Fatal("metadata read renewed or counted the link")
+	}
+}
+
+func TestStatsPrivateLink(t *testing.T) {
+	router, _ := newTestRouter(t)
+	setFlag(t, &jwtSecret, "jwt-secret")
+
+	key := mustShorten(t, router, "https://example.com/private", url.Values{"requireAuth": {"1"}})
+	if code, _ := linkStats(t, router, key, nil); code != http.StatusUnauthorized {
+		t.Fatalf("private stats without token: status %d, want 401", code)
+	}
+	token, _ := signJWT(jwtClaims{Exp: time.Now().Add(time.Hour).Unix()}, jwtSecret)
+	if code, _ := linkStats(t, router, key, bearer(token)); code != http.StatusOK {
+		t.Fatalf("private stats with token: status %d, want 200", code)
+	}
+}
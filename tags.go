@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gomodule/redigo/redis"
+)
+
+// defaultTagPrefix is the default prefix for the Redis sets of short keys per tag.
+const defaultTagPrefix = "myurls:tag:"
+
+// maxTags is the maximum number of tags of a link.
+const maxTags = 10
+
+// maxTagLen is the maximum length in characters of a tag.
+const maxTagLen = 32
+
+// errInvalidTags is returned when the tags parameter is malformed.
+var errInvalidTags = errors.New("tags格式错误，最多10个标签，每个不超过32个字符且不含空白")
+
+// TaggedLink is a short key listed by tag.
+type TaggedLink struct {
+	ShortKey  string
+	LongUrl   string
+	TTL       int
+	CreatedAt int64
+}
+
+// TagResponse is the response structure of the links by tag endpoint.
+type TagResponse struct {
+	Code    int
+	Message string
+	Tag     string
+	Links   []TaggedLink
+}
+
+// parseTags parses the tags parameter of /short, given as a JSON array or as repeated form values.
+// Duplicates are dropped.
+func parseTags(values []string) ([]string, error) {
+	if len(values) == 1 && strings.HasPrefix(strings.TrimSpace(values[0]), "[") {
+		raw := values[0]
+		values = nil
+		if err := json.Unmarshal([]byte(raw), &values); err != nil {
+			return nil, errInvalidTags
+		}
+	}
+
+	var tags []string
+	seen := map[string]bool{}
+	for _, tag := range values {
+		tag = strings.TrimSpace(tag)
+		if !validTag(tag) {
+			return nil, errInvalidTags
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) > maxTags {
+		return nil, errInvalidTags
+	}
+
+	return tags, nil
+}
+
+// validTag reports whether tag is non-empty, short enough and free of whitespace and control characters.
+func validTag(tag string) bool {
+	if tag == "" || utf8.RuneCountInString(tag) > maxTagLen {
+		return false
+	}
+	for _, r := range tag {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// encodeTags encodes tags for the tags metadata field.
+func encodeTags(tags []string) string {
+	encoded, _ := json.Marshal(tags)
+
+	return string(encoded)
+}
+
+// linkTags decodes the tags metadata field, nil when the link has none.
+func linkTags(meta map[string]string) []string {
+	if meta["tags"] == "" {
+		return nil
+	}
+
+	var tags []string
+	if err := json.Unmarshal([]byte(meta["tags"]), &tags); err != nil {
+		return nil
+	}
+
+	return tags
+}
+
+// tagLink adds a short key to the sets of its tags.
+func tagLink(shortKey string, tags []string) error {
+	redisClient := redisPool.Get()
+	defer redisClient.Close()
+
+	for _, tag := range tags {
+		if _, err := redisClient.Do("sadd", redisKey(defaultTagPrefix+tag), shortKey); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// untagLink removes a short key from the sets of the given tags.
+func untagLink(conn redis.Conn, shortKey string, tags []string) error {
+	for _, tag := range tags {
+		if _, err := conn.Do("srem", redisKey(defaultTagPrefix+tag), shortKey); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 按标签列出短链接，顺带清理已过期或删除的成员
+func tagLinksHandler(context *gin.Context) {
+	tag := strings.TrimSpace(context.Query("tag"))
+	res := &TagResponse{Code: 1, Tag: tag, Links: []TaggedLink{}}
+
+	if !validTag(tag) {
+		res.Code = 0
+		res.Message = "tag不能为空"
+		context.JSON(http.StatusBadRequest, *res)
+		return
+	}
+
+	redisClient := redisPool.Get()
+	defer redisClient.Close()
+
+	keys, err := redis.Strings(redisClient.Do("smembers", redisKey(defaultTagPrefix+tag)))
+	if err != nil {
+		res.Code = 0
+		res.Message = storageError(err).Error()
+		context.JSON(http.StatusInternalServerError, *res)
+		return
+	}
+
+	for _, shortKey := range keys {
+		longUrl, _ := redis.String(redisClient.Do("get", redisKey(shortKey)))
+		if longUrl == "" {
+			_, _ = redisClient.Do("srem", redisKey(defaultTagPrefix+tag), shortKey)
+			continue
+		}
+
+		ttl, _ := redis.Int(redisClient.Do("ttl", redisKey(shortKey)))
+		meta, _ := redis.StringMap(redisClient.Do("hgetall", redisKey(defaultMetaPrefix+shortKey)))
+		if meta["unlisted"] == "1" {
+			continue
+		}
+		res.Links = append(res.Links, TaggedLink{ShortKey: shortKey, LongUrl: longUrl, TTL: ttl, CreatedAt: linkCreatedAt(meta)})
+	}
+
+	context.JSON(http.StatusOK, *res)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"testing"
+)
+
+func TestTagLinks(t *testing.T) {
+	router, _ := newTestRouter(t)
+	setFlag(t, &adminToken, "secret-admin")
+
+	first := mustShorten(t, router, "https://example.com/1", url.Values{"tags": {"docs", "go"}})
+	second := mustShorten(t, router, "https://example.com/2", url.Values{"tags": {`["docs"]`}})
+	mustShorten(t, router, "https://example.com/3", url.Values{"tags": {"other"}})
+	mustShorten(t, router, "https://example.com/4", url.Values{"tags": {"docs"}, "private": {"true"}})
+
+	w := serve(router, http.MethodGet, "/admin/links?tag=docs", nil, bearer(adminToken))
+	var res TagResponse
+	decode(t, w, &res)
+	var keys []string
+	for _, link := range res.Links {
+		keys = append(keys, link.ShortKey)
+	}
+	sort.Strings(keys)
+	want := []string{first, second}
+	sort.Strings(want)
+	if w.Code != http.StatusOK || len(keys) != 2 || keys[0] != want[0] || keys[1] != want[1] {
+		t.Fatalf("status %d, links %v, want %v", w.Code, keys, want)
+	}
+
+	_, stats := linkStats(t, router, first, nil)
+	if len(stats.Tags) != 2 || stats.Tags[0] != "docs" || stats.Tags[1] != "go" {
+		t.Fatalf("tags %v", stats.Tags)
+	}
+
+	if w := serve(router, http.MethodGet, "/admin/links?tag=", nil, bearer(adminToken)); w.Code != http.StatusBadRequest {
+		t.Fatalf("empty tag: status %d, want 400", w.Code)
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	tags, err := parseTags([]string{"a", " b ", "a"})
+	if err != nil || len(tags) != 2 || tags[0] != "a" || tags[1] != "b" {
+		t.Fatalf("parseTags = %v, %v", tags, err)
+	}
+	for _, values := range [][]string{
+		{"has space"},
+		{""},
+		{`["a",`},
+		{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k"},
+		{"abcdefghijklmnopqrstuvwxyzabcdefg"},
+	} {
+		if _, err := parseTags(values); err != errInvalidTags {
+			t.Errorf("parseTags(%q) = %v", values, err)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+)
+
+// titleFetchTimeout bounds the whole title fetch, including redirects and reading the body.
+const titleFetchTimeout = time.Second
+
+// maxTitleBodySize is the maximum number of bytes read from the destination when looking for its title.
+const maxTitleBodySize = 64 * 1024
+
+// maxTitleRedirects is the maximum number of redirects followed when fetching a title.
+const maxTitleRedirects = 3
+
+// maxTitleLen is the maximum length in characters of a stored title.
+const maxTitleLen = 256
+
+// fetchTitles enables fetching the page title of long URLs on creation.
+var fetchTitles bool
+
+// titlePattern matches the title element of an HTML document.
+var titlePattern = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// errPrivateAddress is returned when a title fetch would connect to a non-public address.
+var errPrivateAddress = errors.New("refusing to fetch title from a non-public address")
+
+// titleClient fetches page titles, refusing to connect to loopback, private and link-local addresses
+// so user-supplied long URLs can't probe the internal network. It never uses a proxy: the dialer
+// would only see the proxy address, and the proxy would connect to any destination on its behalf.
+var titleClient = &http.Client{
+	Timeout: titleFetchTimeout,
+	Transport: &http.Transport{
+		Proxy: nil,
+		DialContext: (&net.Dialer{
+			Timeout: titleFetchTimeout,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				ip := net.ParseIP(host)
+				if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+					return errPrivateAddress
+				}
+				return nil
+			},
+		}).DialContext,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     30 * time.Second,
+		TLSHandshakeTimeout: titleFetchTimeout,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxTitleRedirects {
+			return http.ErrUseLastResponse
+		}
+		return nil
+	},
+}
+
+// fetchTitle returns the title of the HTML page at longUrl, empty when it can't be fetched in time.
+func fetchTitle(longUrl string) string {
+	if !strings.HasPrefix(longUrl, "http://") && !strings.HasPrefix(longUrl, "https://") {
+		return ""
+	}
+
+	req, err := http.NewRequest(http.MethodGet, longUrl, nil)
+	if err != nil {
+		return ""
+	}
+	req.Header.Set("Accept", "text/html")
+	req.Header.Set("User-Agent", "MyUrls/"+version)
+
+	resp, err := titleClient.Do(req)
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || !strings.Contains(resp.Header.Get("Content-Type"), "html") {
+		return ""
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxTitleBodySize))
+
+	return extractTitle(body)
+}
+
+// extractTitle extracts the unescaped, whitespace-collapsed title of an HTML document.
+func extractTitle(body []byte) string {
+	match := titlePattern.FindSubmatch(body)
+	if match == nil || !utf8.Valid(match[1]) {
+		return ""
+	}
+
+	title := strings.Join(strings.Fields(html.UnescapeString(string(match[1]))), " ")
+	if utf8.RuneCountInString(title) > maxTitleLen {
+		title = string([]rune(title)[:maxTitleLen])
+	}
+
+	return title
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFetchTitle(t *testing.T) {
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			time.Sleep(2 * titleFetchTimeout)
+		case "/text":
+			w.Header().Set("Content-Type", "text/plain")
+		case "/error":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		}
+		_, _ = w.Write([]byte("<html><head><title>\n  Tom &amp; Jerry\n</title></head></html>"))
+	}))
+	defer page.Close()
+
+	// 测试服务器监听在回环地址，使用不限制地址的客户端
+	client := page.Client()
+	client.Timeout = titleFetchTimeout
+	setFlag(t, &titleClient, client)
+
+	if got := fetchTitle(page.URL + "/page"); got != "Tom & Jerry" {
+		t.Fatalf("title %q", got)
+	}
+	for _, path := range []string{"/slow", "/text", "/error"} {
+		if got := fetchTitle(page.URL + path); got != "" {
+			t.Errorf("%s: title %q, want none", path, got)
+		}
+	}
+}
+
+func TestFetchTitleOnCreate(t *testing.T) {
+	router, mr := newTestRouter(t)
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("<title>Landing</title>"))
+	}))
+	defer page.Close()
+	setFlag(t, &titleClient, page.Client())
+	setFlag(t, &fetchTitles, true)
+
+	code, res := shorten(t, router, page.URL+"/landing", nil)
+	if code != http.StatusOK || res.Title != "Landing" {
+		t.Fatalf("status %d, %+v", code, res)
+	}
+	key := strings.TrimPrefix(res.ShortUrl, "https://s.test/")
+	if got := mr.HGet(defaultMetaPrefix+key, "title"); got != "Landing" {
+		t.Fatalf("stored title %q", got)
+	}
+}
+
+func TestTitleClientRefusesPrivateAddresses(t *testing.T) {
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("title fetched from a loopback address")
+	}))
+	defer page.Close()
+
+	if _, err := titleClient.Get(page.URL); !errors.Is(err, errPrivateAddress) {
+		t.Fatalf("loopback fetch: %v", err)
+	}
+
+	// 经代理访问时拨号检查只能看到代理地址，不得使用 HTTP_PROXY 等环境变量
+	if proxy := titleClient.Transport.(*http.Transport).Proxy; proxy != nil {
+		t.Fatal("title client uses a proxy")
+	}
+}
+
+func TestExtractTitle(t *testing.T) {
+	long := strings.Repeat("x", maxTitleLen+10)
+	for body, want := range map[string]string{
+		`<TITLE lang="en">Hi</TITLE>`: "Hi",
+		"<title>a\n\tb</title>":       "a b",
+		"<p>no title</p>":             "",
+		"<title>\xff</title>":         "",
+		"<title>" + long + "</title>": long[:maxTitleLen],
+	} {
+		if got := extractTitle([]byte(body)); got != want {
+			t.Errorf("extractTitle(%q) = %q, want %q", body, got, want)
+		}
+	}
+}
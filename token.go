@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// defaultTokenTTL is the default lifetime of signed admin tokens.
+const defaultTokenTTL = 24 * time.Hour
+
+// 生成带有效期的管理令牌：myurls token -admin-token <token> [-ttl 24h]
+func runTokenCommand(args []string) int {
+	fs := flag.NewFlagSet("token", flag.ContinueOnError)
+	secret := fs.String("admin-token", os.Getenv("MYURLS_ADMIN_TOKEN"), "服务端配置的 -admin-token，默认读取环境变量 MYURLS_ADMIN_TOKEN")
+	ttl := fs.Duration("ttl", defaultTokenTTL, "令牌有效期")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *secret == "" {
+		fmt.Fprintln(os.Stderr, "缺少 -admin-token")
+		return 2
+	}
+	if *ttl <= 0 {
+		fmt.Fprintln(os.Stderr, "ttl 必须大于0")
+		return 2
+	}
+
+	token, err := signJWT(jwtClaims{Exp: time.Now().Add(*ttl).Unix(), Scope: adminScope}, *secret)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "生成令牌失败:", err)
+		return 1
+	}
+	fmt.Println(token)
+
+	return 0
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenCommand(t *testing.T) {
+	code, out := runCommand(t, runTokenCommand, "-admin-token", "secret-admin", "-ttl", "1m")
+	if code != 0 {
+		t.Fatalf("exit code %d", code)
+	}
+	claims, err := parseJWT(strings.TrimSpace(out), "secret-admin")
+	if err != nil || claims.Scope != adminScope || claims.Exp > time.Now().Add(time.Minute).Unix() {
+		t.Fatalf("token claims %+v, %v", claims, err)
+	}
+
+	t.Setenv("MYURLS_ADMIN_TOKEN", "")
+	for _, args := range [][]string{{}, {"-admin-token", "x", "-ttl", "0s"}, {"-bogus"}} {
+		if code, _ := runCommand(t, runTokenCommand, args...); code != 2 {
+			t.Errorf("args %v: exit code %d, want 2", args, code)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gomodule/redigo/redis"
+)
+
+// 修改短链接目标地址，保留剩余有效期
+func updateHandler(context *gin.Context) {
+	shortKey := normalizeShortKey(context.Param("shortKey"))
+
+	if err := writesSuspended(); err != nil {
+		respondError(context, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	longUrl, err := decodeLongUrl(context.PostForm("longUrl"))
+	if err == nil {
+		err = validateLongUrl(longUrl)
+	}
+	if err != nil {
+		respondError(context, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	redisClient := redisPool.Get()
+	defer redisClient.Close()
+
+	oldLongUrl, err := redis.String(redisClient.Do("get", redisKey(shortKey)))
+	if err == redis.ErrNil {
+		respondError(context, http.StatusNotFound, "短链接不存在")
+		return
+	} else if err != nil {
+		respondError(context, http.StatusInternalServerError, storageError(err).Error())
+		return
+	}
+
+	if err := replaceLongUrl(redisClient, shortKey, oldLongUrl, longUrl); err != nil {
+		respondError(context, http.StatusInternalServerError, storageError(err).Error())
+		return
+	}
+
+	context.JSON(http.StatusOK, Response{
+		Code:     1,
+		LongUrl:  longUrl,
+		ShortUrl: buildShortUrl(context, shortKey),
+	})
+}
+
+// replaceLongUrl points shortKey at longUrl keeping its ttl, and moves the md5 reverse mapping to the new URL.
+func replaceLongUrl(conn redis.Conn, shortKey string, oldLongUrl string, longUrl string) error {
+	ttl, err := redis.Int64(conn.Do("pttl", redisKey(shortKey)))
+	if err != nil {
+		return err
+	}
+	if ttl > 0 {
+		_, err = conn.Do("set", redisKey(shortKey), longUrl, "px", ttl)
+	} else {
+		_, err = conn.Do("set", redisKey(shortKey), longUrl)
+	}
+	if err != nil {
+		return err
+	}
+	linkCache.remove(shortKey)
+	if err := signLink(conn, shortKey, longUrl); err != nil {
+		return err
+	}
+
+	// 仅删除指向该短链接的旧 md5 映射
+	oldMd5Key := redisKey(defaultMd5Prefix + longUrlHash(oldLongUrl))
+	if mapped, _ := redis.String(conn.Do("get", oldMd5Key)); mapped == shortKey {
+		if _, err := conn.Do("del", oldMd5Key); err != nil {
+			return err
+		}
+	}
+
+	// 与创建时一致，带有创建者设置的链接不建立 md5 映射
+	meta, err := redis.StringMap(conn.Do("hgetall", redisKey(defaultMetaPrefix+shortKey)))
+	if err != nil {
+		return err
+	}
+	if !shareable(metaLinkRequest(meta)) || noDedup {
+		return nil
+	}
+	_, err = conn.Do("set", redisKey(defaultMd5Prefix+longUrlHash(longUrl)), shortKey, "ex", secondsPerDay)
+
+	return err
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// updateForm is the form of a PUT updating a link to longUrl.
+func updateForm(longUrl string) url.Values {
+	return url.Values{"longUrl": {base64.StdEncoding.EncodeToString([]byte(longUrl))}}
+}
+
+func TestUpdateDestination(t *testing.T) {
+	router, mr := newTestRouter(t)
+	setFlag(t, &authToken, "create-token")
+	auth := bearer(authToken)
+
+	key := mustShortenWith(t, router, "https://example.com/old", nil, auth)
+	mr.SetTTL(key, 48*time.Hour)
+
+	if w := serve(router, http.MethodPut, "/"+key, updateForm("https://example.com/new"), auth); w.Code != http.StatusOK {
+		t.Fatalf("update status %d: %s", w.Code, w.Body)
+	}
+	if got := mr.TTL(key); got != 48*time.Hour {
+		t.Fatalf("update lost the ttl: %v", got)
+	}
+	if got := serve(router, http.MethodGet, "/"+key, nil, nil).Header().Get("Location"); got != "https://example.com/new" {
+		t.Fatalf("Location %q after update", got)
+	}
+
+	lookup := func(longUrl string) int {
+		return serve(router, http.MethodGet, "/lookup?longUrl="+url.QueryEscape(base64.StdEncoding.EncodeToString([]byte(longUrl))), nil, nil).Code
+	}
+	if code := lookup("https://example.com/old"); code != http.StatusNotFound {
+		t.Fatalf("old lookup status %d, want 404", code)
+	}
+	if code := lookup("https://example.com/new"); code != http.StatusOK {
+		t.Fatalf("new lookup status %d, want 200", code)
+	}
+
+	if w := serve(router, http.MethodPut, "/missing", updateForm("https://example.com/x"), auth); w.Code != http.StatusNotFound {
+		t.Fatalf("update missing key: status %d, want 404", w.Code)
+	}
+	if w := serve(router, http.MethodPut, "/"+key, updateForm("javascript:alert(1)"), auth); w.Code != http.StatusBadRequest {
+		t.Fatalf("update to invalid url: status %d, want 400", w.Code)
+	}
+}
+
+func TestUpdateLinkWithSettings(t *testing.T) {
+	router, mr := newTestRouter(t)
+	setFlag(t, &adminToken, "secret-admin")
+
+	for name, fields := range map[string]url.Values{
+		"languages":      {"languages": {`{"de":"https://example.com/de"}`}},
+		"platforms":      {"platforms": {`{"ios":"https://apps.example.com/ios"}`}},
+		"redirectStatus": {"redirectStatus": {"302"}},
+		"maxClicks":      {"maxClicks": {"3"}},
+		"utm":            {"utm": {`{"utm_source":"mail"}`}},
+		"tags":           {"tags": {"promo"}},
+		"ttl":            {"ttl": {"1h"}},
+		"private":        {"private": {"1"}},
+	} {
+		key := mustShorten(t, router, "https://example.com/before/"+name, fields)
+		moved := "https://example.com/moved/" + name
+		serve(router, http.MethodPut, "/"+key, updateForm(moved), bearer(adminToken))
+
+		// 带有创建者设置的链接更新后同样不能被其他创建者复用
+		if mr.Exists(defaultMd5Prefix + longUrlHash(moved)) {
+			t.Errorf("%s: update mapped the link for dedup", name)
+		}
+		if other := mustShorten(t, router, moved, nil); other == key {
+			t.Errorf("%s: plain creator got the updated link", name)
+		}
+	}
+}
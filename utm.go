@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+)
+
+// errInvalidUtm is returned when the utm parameter is not a JSON object of strings.
+var errInvalidUtm = errors.New("utm格式错误，应为字符串键值对的JSON对象")
+
+// parseUtm parses the utm parameter of /short into its canonical JSON encoding.
+func parseUtm(raw string) (string, error) {
+	params := map[string]string{}
+	if err := json.Unmarshal([]byte(raw), &params); err != nil {
+		return "", errInvalidUtm
+	}
+	for key := range params {
+		if key == "" {
+			return "", errInvalidUtm
+		}
+	}
+	if len(params) == 0 {
+		return "", nil
+	}
+
+	// map 按键排序编码，相同参数得到相同的存储值
+	encoded, _ := json.Marshal(params)
+
+	return string(encoded), nil
+}
+
+// linkUtm decodes the utm metadata field, nil when the link has none.
+func linkUtm(meta map[string]string) map[string]string {
+	if meta["utm"] == "" {
+		return nil
+	}
+
+	params := map[string]string{}
+	if err := json.Unmarshal([]byte(meta["utm"]), &params); err != nil {
+		return nil
+	}
+
+	return params
+}
+
+// appendUtm appends the stored campaign parameters of a resolved short key to longUrl,
+// keeping any parameter already present in the destination.
+func appendUtm(link resolvedLink, longUrl string) string {
+	params := linkUtm(link.meta)
+	if len(params) == 0 {
+		return longUrl
+	}
+
+	values := url.Values{}
+	for key, value := range params {
+		values.Set(key, value)
+	}
+
+	return mergeQuery(longUrl, values)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestRedirectAppendsUtm(t *testing.T) {
+	router, _ := newTestRouter(t)
+	utm := `{"utm_source":"mail","utm_campaign":"spring sale"}`
+
+	plain := mustShorten(t, router, "https://example.com/plain", url.Values{"utm": {utm}})
+	query := mustShorten(t, router, "https://example.com/query?a=1&utm_source=site", url.Values{"utm": {utm}})
+
+	for _, tc := range []struct {
+		key  string
+		want string
+	}{
+		{plain, "https://example.com/plain?utm_campaign=spring+sale&utm_source=mail"},
+		{query, "https://example.com/query?a=1&utm_source=site&utm_campaign=spring+sale"},
+	} {
+		if got := serve(router, http.MethodGet, "/"+tc.key, nil, nil).Header().Get("Location"); got != tc.want {
+			t.Errorf("Location %q, want %q", got, tc.want)
+		}
+	}
+}
+
+func TestParseUtm(t *testing.T) {
+	if got, err := parseUtm(`{"b":"2","a":"1"}`); err != nil || got != `{"a":"1","b":"2"}` {
+		t.Fatalf("parseUtm = %q, %v", got, err)
+	}
+	for _, raw := range []string{`[]`, `{"a":1}`, `{"":"x"}`, `nope`} {
+		if _, err := parseUtm(raw); err != errInvalidUtm {
+			t.Errorf("parseUtm(%q) = %v", raw, err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// webhookTimeout bounds each webhook delivery attempt.
+const webhookTimeout = 5 * time.Second
+
+// webhookAttempts is the number of delivery attempts of a webhook event.
+const webhookAttempts = 3
+
+// webhookRetryInterval is the delay before retrying a failed webhook delivery.
+const webhookRetryInterval = 2 * time.Second
+
+// webhookURL receives a POST for every created short link, disabled when empty.
+var webhookURL string
+
+// webhookClient is the HTTP client used for webhook deliveries.
+var webhookClient = &http.Client{Timeout: webhookTimeout}
+
+// LinkEvent is the webhook payload sent when a short link is created.
+type LinkEvent struct {
+	ShortUrl  string `json:"shortUrl"`
+	LongUrl   string `json:"longUrl"`
+	ShortKey  string `json:"shortKey"`
+	TTL       int    `json:"ttl"`
+	CreatedAt int64  `json:"createdAt"`
+}
+
+// notifyLinkCreated delivers event to the webhook in the background, never blocking the caller.
+func notifyLinkCreated(event LinkEvent) {
+	go func() {
+		if err := deliverWebhook(webhookURL, event); err != nil {
+			log.Println("Webhook delivery failed:", err)
+		}
+	}()
+}
+
+// deliverWebhook posts payload to the webhook at target, retrying failed attempts.
+func deliverWebhook(target string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	for i := 1; ; i++ {
+		err = postWebhook(target, body)
+		if err == nil || i >= webhookAttempts {
+			return err
+		}
+		time.Sleep(webhookRetryInterval)
+	}
+}
+
+// postWebhook sends a single webhook request, treating non-2xx responses as failures.
+func postWebhook(target string, body []byte) error {
+	resp, err := webhookClient.Post(target, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+
+	return nil
+}
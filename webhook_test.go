@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWebhookOnCreate(t *testing.T) {
+	router, _ := newTestRouter(t)
+	events := make(chan LinkEvent, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event LinkEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Error(err)
+		}
+		events <- event
+	}))
+	defer receiver.Close()
+	setFlag(t, &webhookURL, receiver.URL)
+
+	key := mustShorten(t, router, "https://example.com/hooked", nil)
+	select {
+	case event := <-events:
+		if event.ShortKey != key || event.LongUrl != "https://example.com/hooked" || event.ShortUrl != "https://s.test/"+key || event.TTL != linkTTL || event.CreatedAt == 0 {
+			t.Fatalf("event %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("webhook not delivered")
+	}
+}
+
+func TestDeliverWebhookRetries(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits for the retry interval")
+	}
+	var attempts int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer receiver.Close()
+
+	if err := deliverWebhook(receiver.URL, LinkEvent{ShortKey: "abc"}); err != nil || attempts != 2 {
+		t.Fatalf("deliver after a failure: %v, %d attempts", err, attempts)
+	}
+}
+
+func TestPostWebhookFailure(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	if err := postWebhook(receiver.URL, []byte("{}")); err == nil {
+		t.Fatal("non-2xx response accepted")
+	}
+}